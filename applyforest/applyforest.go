@@ -17,7 +17,9 @@ func main() {
 	predfn := flag.String("preds",
 		"", "The name of a file to write the predictions into.")
 	votefn := flag.String("votes",
-		"", "The name of a file to write catagorical vote totals to.")
+		"", "The name of a file to write catagorical vote totals (or class probabilities for boosted forests) to.")
+	contribfn := flag.String("contributions",
+		"", "The name of a file to write decision path feature contributions to.")
 	var num bool
 	flag.BoolVar(&num, "mean", false, "Force numeric (mean) voting.")
 	var cat bool
@@ -51,8 +53,85 @@ func main() {
 		defer predfile.Close()
 	}
 
+	if forest.Target == CloudForest.IsolationTarget {
+		scores, err := CloudForest.IsolationScores(forest, data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *predfn != "" {
+			fmt.Printf("Outputting label anomaly score tsv to %v\n", *predfn)
+			for i, l := range data.CaseLabels {
+				fmt.Fprintf(predfile, "%v\t%v\n", l, scores[i])
+			}
+		}
+		return
+	}
+
+	if timename, eventname, ok := CloudForest.ParseSurvivalTargetName(forest.Target); ok {
+		events := make([]bool, data.Data[0].Length())
+		eventi, hasEvent := data.Map[eventname]
+		if hasEvent {
+			events, _ = CloudForest.ParseEvents(data.Data[eventi])
+		}
+		bb := CloudForest.NewSurvivalBallotBox(events)
+		for _, tree := range forest.Trees {
+			tree.Vote(data, bb)
+		}
+		timei, hasTime := data.Map[timename]
+		if hasTime && hasEvent {
+			fmt.Printf("Concordance: %v\n", 1.0-bb.TallyError(data.Data[timei]))
+		}
+		if *predfn != "" {
+			fmt.Printf("Outputting label mortality cumulative hazard actual tsv to %v\n", *predfn)
+			mortality := bb.Mortality()
+			for i, l := range data.CaseLabels {
+				actual := "NA"
+				if hasTime {
+					actual = data.Data[timei].GetStr(i)
+				}
+				fmt.Fprintf(predfile, "%v\t%v\t%v\t%v\n", l, mortality[i], bb.Tally(i), actual)
+			}
+		}
+		return
+	}
+
+	if names := CloudForest.ParseMultiTargetName(forest.Target); names != nil {
+		mt, err := CloudForest.NewMultiTarget(data, names, false)
+		hasTargets := err == nil
+		bb := &CloudForest.MultiBallotBox{Boxes: make([]CloudForest.VoteTallyer, 0, len(names))}
+		for _, name := range names {
+			if !cat && (num || strings.HasPrefix(name, "N")) {
+				bb.Boxes = append(bb.Boxes, CloudForest.NewNumBallotBox(data.Data[0].Length()))
+			} else {
+				bb.Boxes = append(bb.Boxes, CloudForest.NewCatBallotBox(data.Data[0].Length()))
+			}
+		}
+		for _, tree := range forest.Trees {
+			tree.Vote(data, bb)
+		}
+		if hasTargets {
+			fmt.Printf("Targets are %v\n", names)
+			fmt.Printf("%v\n", bb.TallyErrors(mt))
+		}
+		if *predfn != "" {
+			fmt.Printf("Outputting label predicted actual tsv with a predicted and actual column per target to %v\n", *predfn)
+			for i, l := range data.CaseLabels {
+				actual := strings.TrimSuffix(strings.Repeat("NA\t", len(names)), "\t")
+				if hasTargets {
+					actual = mt.GetStr(i)
+				}
+				fmt.Fprintf(predfile, "%v\t%v\t%v\n", l, bb.Tally(i), actual)
+			}
+		}
+		return
+	}
+
 	var bb CloudForest.VoteTallyer
-	if !cat && (num || strings.HasPrefix(forest.Target, "N")) {
+	if forest.Type == CloudForest.GBTForest {
+		bb = CloudForest.NewSumBallotBox(data.Data[0].Length(), forest.Intercept)
+	} else if forest.Type == CloudForest.GBTClassForest {
+		bb = CloudForest.NewSoftmaxBallotBox(data.Data[0].Length())
+	} else if !cat && (num || strings.HasPrefix(forest.Target, "N")) {
 		bb = CloudForest.NewNumBallotBox(data.Data[0].Length())
 	} else {
 		bb = CloudForest.NewCatBallotBox(data.Data[0].Length())
@@ -80,6 +159,51 @@ func main() {
 		}
 	}
 
+	if *contribfn != "" {
+		fmt.Printf("Outputting decision path feature contributions to %v\n", *contribfn)
+		var contribs *CloudForest.Contributions
+		switch forest.Type {
+		case CloudForest.GBTForest:
+			contribs = CloudForest.NewSumContributions(data.Data[0].Length(), false, forest.Intercept)
+		case CloudForest.GBTClassForest:
+			contribs = CloudForest.NewSumContributions(data.Data[0].Length(), true, 0.0)
+		default:
+			_, categorical := bb.(*CloudForest.CatBallotBox)
+			contribs = CloudForest.NewContributions(data.Data[0].Length(), categorical)
+		}
+		for _, tree := range forest.Trees {
+			if err = tree.Contribute(data, contribs); err != nil {
+				log.Fatal(err)
+			}
+		}
+		contribfile, err := os.Create(*contribfn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer contribfile.Close()
+		if err = contribs.WriteTsv(contribfile, data); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if sbb, ok := bb.(*CloudForest.SoftmaxBallotBox); ok && *votefn != "" {
+		fmt.Printf("Outputting class probabilities to %v\n", *votefn)
+		votefile, err := os.Create(*votefn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer votefile.Close()
+		fmt.Fprintf(votefile, ".\t%v\n", strings.Join(sbb.Back, "\t"))
+		for i, l := range data.CaseLabels {
+			fmt.Fprintf(votefile, "%v", l)
+			for _, p := range sbb.TallyProbabilities(i) {
+				fmt.Fprintf(votefile, "\t%v", p)
+			}
+			fmt.Fprintf(votefile, "\n")
+		}
+		return
+	}
+
 	//Not thread safe code!
 	if *votefn != "" {
 		fmt.Printf("Outputting vote totals to %v\n", *votefn)
@@ -56,6 +56,64 @@ func BenchmarkBoston(b *testing.B) {
 	}
 }
 
+func BenchmarkBostonPresorted(b *testing.B) {
+
+	boston := strings.NewReader(boston_housing)
+
+	fm := ParseARFF(boston)
+
+	candidates := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+
+	target := fm.Data[fm.Map["class"]]
+
+	cases := make([]int, 0, fm.Data[0].Length())
+	for i := 0; i < fm.Data[0].Length(); i++ {
+		cases = append(cases, i)
+	}
+	allocs := NewBestSplitAllocs(len(cases), target)
+	allocs.Presort = NewPresortedCases(fm, NewPresortedIndex(fm, candidates))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := NewTree()
+		tree.Grow(fm, target, cases, candidates, nil, 2, 1, false, false, false, false, nil, nil, allocs)
+
+	}
+}
+
+//benchmarkLargeGrow grows trees on 20000 cases of binningTestData trying every feature at
+//each node with or without presorting.
+func benchmarkLargeGrow(b *testing.B, presort bool) {
+	n := 20000
+	fm := binningTestData(n)
+	candidates := []int{2, 3}
+	target := fm.Data[0].(Target)
+
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+	allocs := NewBestSplitAllocs(len(cases), target)
+	if presort {
+		allocs.Presort = NewPresortedCases(fm, NewPresortedIndex(fm, candidates))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree := NewTree()
+		tree.Grow(fm, target, cases, candidates, nil, 2, 1, false, false, false, false, nil, nil, allocs)
+
+	}
+}
+
+func BenchmarkLargeSorted(b *testing.B) {
+	benchmarkLargeGrow(b, false)
+}
+
+func BenchmarkLargePresorted(b *testing.B) {
+	benchmarkLargeGrow(b, true)
+}
+
 func BenchmarkBestNumSplit(b *testing.B) {
 
 	// irisreader := strings.NewReader(irisarff)
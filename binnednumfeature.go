@@ -0,0 +1,438 @@
+package CloudForest
+
+import (
+	"sort"
+)
+
+//MaxBins is the largest number of bins a BinnedNumFeature can have.
+const MaxBins = 255
+
+/*
+BinnedNumFeature wraps a DenseNumFeature whose values have been quantized into at most
+MaxBins bins for histogram based split searching as in LightGBM and XGBoost's hist method.
+
+Instead of sorting a node's cases for each candidate feature BestSplit accumulates the
+statistics of each bin (see HistogramTarget) in one pass over the cases and only evaluates
+splits between bins. The histograms are kept in allocs so the histogram of a right child
+can be found by subtracting its left sibling's histogram from its parent's without looking
+at its cases. Targets that aren't HistogramTargets are split by counting sorting the cases
+by bin and evaluating the same splits with SplitImpurity and UpdateSImpFromAllocs.
+
+Splits are made at Edges, the midpoints between the values of adjacent bins, so trees
+grown on a binned feature can be applied to unbinned data. Bins must be recalculated (with
+NewBinnedNumFeature) if the feature's values change.
+*/
+type BinnedNumFeature struct {
+	*DenseNumFeature
+	Bins  []uint8
+	Edges []float64
+}
+
+/*
+NewBinnedNumFeature quantizes the non missing values of f into at most maxBins bins (which
+is limited to MaxBins). If f has no more distinct values than maxBins each value gets its
+own bin otherwise the bins are chosen to hold roughly equal numbers of cases.
+*/
+func NewBinnedNumFeature(f *DenseNumFeature, maxBins int) (bf *BinnedNumFeature) {
+	if maxBins <= 0 || maxBins > MaxBins {
+		maxBins = MaxBins
+	}
+	vals := make([]float64, 0, len(f.NumData))
+	for i, v := range f.NumData {
+		if !f.IsMissing(i) {
+			vals = append(vals, v)
+		}
+	}
+	sort.Float64s(vals)
+
+	//distinct values and their counts
+	distinct := make([]float64, 0)
+	counts := make([]int, 0)
+	for i, v := range vals {
+		if i == 0 || v != vals[i-1] {
+			distinct = append(distinct, v)
+			counts = append(counts, 0)
+		}
+		counts[len(counts)-1]++
+	}
+
+	bf = &BinnedNumFeature{f, make([]uint8, len(f.NumData)), make([]float64, 0, maxBins-1)}
+	n := len(vals)
+	m := len(distinct)
+	cum := 0
+	next := 1
+	for j := 0; j < m-1 && len(bf.Edges) < maxBins-1; j++ {
+		cum += counts[j]
+		//cut after this value if the bin has reached its share of the cases or there are
+		//few enough distinct values left for each to have its own bin
+		if cum*maxBins >= next*n || m-1-j <= maxBins-1-len(bf.Edges) {
+			bf.Edges = append(bf.Edges, (distinct[j]+distinct[j+1])/2.0)
+			for cum*maxBins >= next*n {
+				next++
+			}
+		}
+	}
+
+	for i, v := range f.NumData {
+		if !f.IsMissing(i) {
+			bf.Bins[i] = uint8(sort.SearchFloat64s(bf.Edges, v))
+		}
+	}
+	return
+}
+
+/*
+BinNumFeatures replaces the numerical features specified (by index) with BinnedNumFeatures
+with at most maxBins bins. Features that aren't DenseNumFeatures are left alone. It should
+be called after any imputation and the target shouldn't be binned.
+*/
+func (fm *FeatureMatrix) BinNumFeatures(maxBins int, features []int) {
+	for _, i := range features {
+		if f, ok := fm.Data[i].(*DenseNumFeature); ok {
+			fm.Data[i] = NewBinnedNumFeature(f, maxBins)
+		}
+	}
+}
+
+//NBins returns the number of bins.
+func (f *BinnedNumFeature) NBins() int {
+	return len(f.Edges) + 1
+}
+
+/*
+BestSplit finds the best split between the bins of the feature for the specified target and
+cases. It returns the split value and the decrease in impurity as DenseNumFeature.BestSplit.
+Random splits (see BestSplitAllocs.ExtraTrees) are drawn from the feature's values as for
+unbinned features.
+*/
+func (f *BinnedNumFeature) BestSplit(target Target,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool) {
+	if allocs.ExtraTrees {
+		return f.bestSplitWithMissing(target, cases, parentImp, leafSize, allocs, f.BestRandomNumSplit)
+	}
+	if ht, ok := target.(HistogramTarget); ok {
+		return f.BestHistogramSplit(ht, cases, parentImp, leafSize, allocs)
+	}
+	return f.bestSplitWithMissing(target, cases, parentImp, leafSize, allocs, f.BestBinnedSplit)
+}
+
+/*
+BestHistogramSplit searches the splits between bins using the sums of the target's
+statistics for the cases in each bin. Missing cases are accounted for as in
+DenseNumFeature.BestSplit. If the feature is constrained by allocs.Monotone the mean target
+values of the children are taken from the summed statistics (the number of cases and the
+sum of the numerical target's values) and splits in the wrong order are skipped.
+*/
+func (f *BinnedNumFeature) BestHistogramSplit(target HistogramTarget,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool) {
+
+	nbins := f.NBins()
+	nstats := target.NHistStats()
+	hc := allocs.Histograms
+	hist := hc.histogram(f, target, *cases, nbins, nstats, target != allocs.ContrastTarget)
+	missing := hist[nbins*nstats:]
+
+	total := len(*cases)
+	nmissing := int(missing[0])
+	nonmissing := total - nmissing
+	if nonmissing == 0 {
+		return
+	}
+
+	nonmissingStats := hc.scratch(0, nstats)
+	nonempty := 0
+	for b := 0; b < nbins; b++ {
+		stats := hist[b*nstats : (b+1)*nstats]
+		if stats[0] > 0.0 {
+			nonempty++
+			for k, v := range stats {
+				nonmissingStats[k] += v
+			}
+		}
+	}
+
+	nonmissingparentImp := parentImp
+	missingimp := 0.0
+	if nmissing > 0 {
+		nonmissingparentImp = target.HistImpurity(nonmissingStats)
+		missingimp = target.HistImpurity(missing)
+	}
+
+	impurityDecrease = minImp
+	codedSplit = 0.0
+	if nonmissing < 2*leafSize {
+		return
+	}
+	if nonempty <= 1 {
+		constant = true
+		return
+	}
+
+	dir := allocs.Monotone.searching()
+	if _, ok := target.(NumFeature); !ok {
+		dir = 0
+	}
+
+	l := hc.scratch(1, nstats)
+	r := hc.scratch(2, nstats)
+	copy(r, nonmissingStats)
+	fleafSize := float64(leafSize)
+	for b := 0; b < nbins-1; b++ {
+		stats := hist[b*nstats : (b+1)*nstats]
+		if stats[0] == 0.0 {
+			continue
+		}
+		for k, v := range stats {
+			l[k] += v
+			r[k] -= v
+		}
+		if l[0] < fleafSize {
+			continue
+		}
+		if r[0] < fleafSize || r[0] <= 0.0 {
+			break
+		}
+		if dir != 0 && !allocs.Monotone.Allows(dir, l[1]/l[0], r[1]/r[0]) {
+			continue
+		}
+		innerimp := nonmissingparentImp - target.HistSplitImpurity(l, r, nil)
+		if innerimp > impurityDecrease {
+			impurityDecrease = innerimp
+			codedSplit = f.Edges[b]
+		}
+	}
+
+	if nmissing > 0 && impurityDecrease > minImp {
+		impurityDecrease = parentImp + ((float64(nonmissing)*(impurityDecrease-nonmissingparentImp) - float64(nmissing)*missingimp) / float64(total))
+	}
+	return
+}
+
+/*
+BestBinnedSplit searches the splits between bins of the non missing cases for targets that
+aren't HistogramTargets. It orders the cases by bin with a counting sort and evaluates each
+split with SplitImpurity or UpdateSImpFromAllocs as in BestNumSplit. Splits that violate a
+monotone constraint are skipped as in BestSortedSplit.
+*/
+func (f *BinnedNumFeature) BestBinnedSplit(target Target,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool) {
+
+	impurityDecrease = minImp
+	codedSplit = 0.0
+	n := len(*cases)
+	if n < 2*leafSize || n == 0 {
+		return
+	}
+
+	nbins := f.NBins()
+	hc := allocs.Histograms
+	if cap(hc.counts) < nbins+1 {
+		hc.counts = make([]int, nbins+1)
+	}
+	ends := hc.counts[:nbins+1]
+	for b := range ends {
+		ends[b] = 0
+	}
+	for _, i := range *cases {
+		ends[int(f.Bins[i])+1]++
+	}
+	nonempty := 0
+	for b := 1; b <= nbins; b++ {
+		if ends[b] > 0 {
+			nonempty++
+		}
+		ends[b] += ends[b-1]
+	}
+	if nonempty <= 1 {
+		constant = true
+		return
+	}
+
+	if cap(hc.sorted) < n {
+		hc.sorted = make([]int, n)
+	}
+	sorted := hc.sorted[:n]
+	for _, i := range *cases {
+		b := f.Bins[i]
+		sorted[ends[b]] = i
+		ends[b]++
+	}
+	//ends[b] is now the end of bin b
+	sums := newMonotoneSums(target, sorted, allocs.Monotone.searching())
+
+	lastsplit := 0
+	innerimp := 0.0
+	for b := 0; b < nbins-1; b++ {
+		i := ends[b]
+		if i == lastsplit || i < leafSize {
+			continue
+		}
+		if n-i < leafSize || i == n {
+			break
+		}
+		allocs.LM = sorted[:i]
+		allocs.RM = sorted[i:]
+		if lastsplit == 0 {
+			innerimp = parentImp - target.SplitImpurity(&allocs.LM, &allocs.RM, nil, allocs)
+		} else {
+			allocs.MM = sorted[lastsplit:i]
+			innerimp = parentImp - target.UpdateSImpFromAllocs(&allocs.LM, &allocs.RM, nil, allocs, &allocs.MM)
+		}
+		lastsplit = i
+
+		if sums != nil && !sums.allows(allocs.Monotone, sorted, i) {
+			continue
+		}
+		if innerimp > impurityDecrease {
+			impurityDecrease = innerimp
+			codedSplit = f.Edges[b]
+		}
+	}
+	return
+}
+
+/*
+HistogramCache holds the histograms of the nodes of the tree being grown that may still be
+needed to find the histograms of other nodes by subtraction as well as reusable buffers for
+binned split searching. It is part of BestSplitAllocs and must be Reset before each tree is
+grown (as Tree.Grow does) since nodes are identified by the position of their cases in the
+tree's case slice.
+*/
+type HistogramCache struct {
+	entries []*histEntry
+	free    [][]float64
+	stats   [4][]float64
+	counts  []int
+	sorted  []int
+}
+
+//histEntry is the histogram of a feature for the cases of a node. Cases are identified by
+//the last element of the backing array of their slice and their offsets from it.
+type histEntry struct {
+	feature  *BinnedNumFeature
+	target   HistogramTarget
+	array    *int
+	startOff int
+	endOff   int
+	hist     []float64
+}
+
+//NewHistogramCache returns an empty HistogramCache.
+func NewHistogramCache() *HistogramCache {
+	return &HistogramCache{}
+}
+
+//Reset removes all of the histograms for use with a new tree.
+func (hc *HistogramCache) Reset() {
+	for _, e := range hc.entries {
+		hc.free = append(hc.free, e.hist)
+	}
+	hc.entries = hc.entries[:0]
+}
+
+//scratch returns the k'th zeroed reusable buffer of length n.
+func (hc *HistogramCache) scratch(k int, n int) []float64 {
+	if cap(hc.stats[k]) < n {
+		hc.stats[k] = make([]float64, n)
+	}
+	buf := hc.stats[k][:n]
+	for i := range buf {
+		buf[i] = 0.0
+	}
+	return buf
+}
+
+//alloc returns a zeroed histogram of length n reusing freed histograms if possible.
+func (hc *HistogramCache) alloc(n int) (hist []float64) {
+	for len(hc.free) > 0 {
+		hist = hc.free[len(hc.free)-1]
+		hc.free = hc.free[:len(hc.free)-1]
+		if cap(hist) >= n {
+			hist = hist[:n]
+			for i := range hist {
+				hist[i] = 0.0
+			}
+			return
+		}
+	}
+	return make([]float64, n)
+}
+
+/*
+histogram returns the histogram of the target's statistics for the cases in each bin of f
+with the statistics of missing cases in an extra last bin. If cache is true histograms are
+looked up and stored so the histogram of a right child can be found from its parent's and
+its left sibling's. Since nodes are searched depth first only the histograms of the
+current node's ancestors and of the node to its immediate left are kept.
+*/
+func (hc *HistogramCache) histogram(f *BinnedNumFeature, target HistogramTarget, cases []int, nbins int, nstats int, cache bool) (hist []float64) {
+	size := (nbins + 1) * nstats
+	if !cache || cap(cases) == 0 {
+		hist = hc.scratch(3, size)
+		f.fillHistogram(target, cases, nbins, nstats, hist)
+		return
+	}
+
+	full := cases[:cap(cases)]
+	array := &full[len(full)-1]
+	startOff := cap(cases)
+	endOff := cap(cases) - len(cases)
+
+	kept := hc.entries[:0]
+	var parent, sibling *histEntry
+	for _, e := range hc.entries {
+		contains := e.array == array && e.startOff >= startOff && e.endOff <= endOff
+		left := e.array == array && e.endOff == startOff
+		if !contains && !left {
+			hc.free = append(hc.free, e.hist)
+			continue
+		}
+		kept = append(kept, e)
+		if contains && e.feature == f && e.target == target && e.endOff == endOff && e.startOff > startOff {
+			if parent == nil || e.startOff < parent.startOff {
+				parent = e
+			}
+		}
+	}
+	hc.entries = kept
+	if parent != nil {
+		for _, e := range hc.entries {
+			if e.feature == f && e.target == target && e.startOff == parent.startOff && e.endOff == startOff {
+				sibling = e
+				break
+			}
+		}
+	}
+
+	hist = hc.alloc(size)
+	if parent != nil && sibling != nil && len(parent.hist) == size {
+		for k := range hist {
+			hist[k] = parent.hist[k] - sibling.hist[k]
+		}
+	} else {
+		f.fillHistogram(target, cases, nbins, nstats, hist)
+	}
+	hc.entries = append(hc.entries, &histEntry{f, target, array, startOff, endOff, hist})
+	return
+}
+
+//fillHistogram adds the target's statistics for each case to the histogram of its bin or
+//the last bin if the case is missing.
+func (f *BinnedNumFeature) fillHistogram(target HistogramTarget, cases []int, nbins int, nstats int, hist []float64) {
+	for _, i := range cases {
+		b := nbins
+		if !f.HasMissing || !f.Missing[i] {
+			b = int(f.Bins[i])
+		}
+		target.AddHistStats(i, hist[b*nstats:(b+1)*nstats])
+	}
+}
@@ -0,0 +1,139 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+//binningTestData returns a feature matrix with numerical and categorical targets that
+//depend on a feature with 10 values (and some missing) and a continuous feature. It draws
+//them from a fixed seed so the data is the same in every run.
+func binningTestData(n int) *FeatureMatrix {
+	rng := rand.New(rand.NewSource(45))
+	x := make([]string, 0, n)
+	z := make([]string, 0, n)
+	y := make([]string, 0, n)
+	c := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		xv := float64(rng.Intn(10)) / 10.0
+		zv := rng.Float64()
+		if rng.Float64() < 0.1 {
+			x = append(x, "NA")
+		} else {
+			x = append(x, fmt.Sprintf("%v", xv))
+		}
+		z = append(z, fmt.Sprintf("%v", zv))
+		y = append(y, fmt.Sprintf("%v", 3.0*xv+math.Sin(6.0*zv)+0.1*rng.NormFloat64()))
+		if xv+0.5*zv+0.1*rng.NormFloat64() > 0.7 {
+			c = append(c, "a")
+		} else {
+			c = append(c, "b")
+		}
+	}
+	return parseRows([]string{"N:Y", "C:C", "N:X", "N:Z"}, [][]string{y, c, x, z})
+}
+
+func TestBinnedNumFeature(t *testing.T) {
+	n := 1000
+	fm := binningTestData(n)
+	dense := []*DenseNumFeature{fm.Data[2].(*DenseNumFeature), fm.Data[3].(*DenseNumFeature)}
+	fm.BinNumFeatures(32, []int{2, 3})
+	x := fm.Data[2].(*BinnedNumFeature)
+	z := fm.Data[3].(*BinnedNumFeature)
+
+	if x.NBins() != 10 {
+		t.Errorf("Feature with 10 values has %v bins.", x.NBins())
+	}
+	if z.NBins() > 32 || z.NBins() < 30 {
+		t.Errorf("Continuous feature has %v bins not about 32.", z.NBins())
+	}
+	for _, f := range []*BinnedNumFeature{x, z} {
+		for i := 0; i < n; i++ {
+			if f.IsMissing(i) {
+				continue
+			}
+			b := int(f.Bins[i])
+			if (b > 0 && f.NumData[i] <= f.Edges[b-1]) || (b < len(f.Edges) && f.NumData[i] > f.Edges[b]) {
+				t.Fatalf("Value %v is outside of bin %v.", f.NumData[i], b)
+			}
+		}
+	}
+
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+	for _, target := range []Target{fm.Data[0].(Target), fm.Data[1].(Target)} {
+		allocs := NewBestSplitAllocs(n, target)
+		parentImp := target.Impurity(&cases, allocs.Counter)
+
+		//with one bin per value binned splitting should match sorting
+		split, imp, _ := x.BestSplit(target, &cases, parentImp, 5, allocs)
+		dsplit, dimp, _ := dense[0].BestSplit(target, &cases, parentImp, 5, allocs)
+		if split.(float64) != dsplit.(float64) || math.Abs(imp-dimp) > 1e-9 {
+			t.Errorf("Binned split %v with decrease %v doesn't match %v with %v.", split, imp, dsplit, dimp)
+		}
+
+		//counting sort search should match the histogram search
+		for _, f := range []*BinnedNumFeature{x, z} {
+			split, imp, _ = f.BestSplit(target, &cases, parentImp, 5, allocs)
+			csplit, cimp, _ := f.bestSplitWithMissing(target, &cases, parentImp, 5, allocs, f.BestBinnedSplit)
+			if split.(float64) != csplit.(float64) || math.Abs(imp-cimp) > 1e-9 {
+				t.Errorf("Histogram split %v with decrease %v doesn't match %v with %v.", split, imp, csplit, cimp)
+			}
+		}
+	}
+}
+
+func TestHistogramSubtraction(t *testing.T) {
+	n := 1000
+	fm := binningTestData(n)
+	fm.BinNumFeatures(64, []int{2, 3})
+	for _, target := range []Target{fm.Data[0].(Target), fm.Data[1].(Target)} {
+		cases := make([]int, 0, n)
+		for i := 0; i < n; i++ {
+			cases = append(cases, i)
+		}
+		allocs := NewBestSplitAllocs(n, target)
+		tree := NewTree()
+		tree.Grow(fm, target, cases, []int{2, 3}, nil, 2, 20, false, false, false, false, nil, nil, allocs)
+
+		//each split found with histograms from subtraction should decrease impurity as much
+		//as the best split of the node's cases found without them (features and values can
+		//tie so only the decrease is compared)
+		nsplits := 0
+		tree.Root.Recurse(func(node *Node, nodecases []int, depth int) {
+			if node.Splitter == nil {
+				return
+			}
+			nsplits++
+			fresh := NewBestSplitAllocs(n, target)
+			parentImp := target.Impurity(&nodecases, fresh.Counter)
+			bestImp := minImp
+			for _, fi := range []int{2, 3} {
+				if _, imp, _ := fm.Data[fi].BestSplit(target, &nodecases, parentImp, 20, fresh); imp > bestImp {
+					bestImp = imp
+				}
+			}
+
+			//the node's split is the only split of a feature indicating which side of it
+			//each case falls on
+			f := fm.Data[node.Featurei].(*BinnedNumFeature)
+			side := &DenseNumFeature{make([]float64, n), f.Missing, "side", f.HasMissing}
+			for i, v := range f.NumData {
+				if v > node.Splitter.Value {
+					side.NumData[i] = 1.0
+				}
+			}
+			_, imp, _ := side.BestSplit(target, &nodecases, parentImp, 1, fresh)
+			if math.Abs(imp-bestImp) > 1e-9 {
+				t.Errorf("Node at depth %v split on %v at %v with decrease %v not %v.", depth, node.Splitter.Feature, node.Splitter.Value, imp, bestImp)
+			}
+		}, fm, cases, 0)
+		if nsplits < 3 {
+			t.Errorf("Tree only had %v splits.", nsplits)
+		}
+	}
+}
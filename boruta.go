@@ -0,0 +1,217 @@
+package CloudForest
+
+import (
+	"fmt"
+	"github.com/ryanbressler/CloudForest/stats"
+	"io"
+	"math"
+)
+
+//Decisions made about features by Boruta.
+const (
+	Tentative = iota
+	Confirmed
+	Rejected
+)
+
+//BorutaDecisionNames maps decisions to the strings used in output.
+var BorutaDecisionNames = []string{"Tentative", "Confirmed", "Rejected"}
+
+/*
+Boruta implements Kursa and Rudnicki's all relevant feature selection. In each round
+a forest is grown on the features that have not been rejected and a shuffled shadow
+copy of each of them. A feature scores a hit if its importance exceeds the maximum
+importance of any shadow feature. After each round two one sided binomial tests (with
+Bonferroni correction for the number of features) of the hits against the expected
+rate of 0.5 confirm features that win significantly often (upper tail) and reject
+features that lose significantly often (lower tail).
+
+Features holds the feature matrix indexes of the candidate features and Decisions,
+Hits and History (the importance in each round a feature was tested) are parallel to
+it. ShadowMax records the maximum shadow importance in each round.
+*/
+type Boruta struct {
+	Target    int
+	Features  []int
+	Decisions []int
+	Hits      []int
+	History   [][]float64
+	ShadowMax []float64
+	Rounds    int
+}
+
+/*
+NewBoruta sets up feature selection to predict the feature at index targeti in fm
+using all other features not marked in blacklist (which may be nil).
+*/
+func NewBoruta(fm *FeatureMatrix, targeti int, blacklist []bool) (b *Boruta) {
+	b = &Boruta{targeti,
+		make([]int, 0, len(fm.Data)),
+		make([]int, 0, len(fm.Data)),
+		make([]int, 0, len(fm.Data)),
+		make([][]float64, 0, len(fm.Data)),
+		make([]float64, 0),
+		0}
+	for i := range fm.Data {
+		if i != targeti && (blacklist == nil || !blacklist[i]) {
+			b.Features = append(b.Features, i)
+			b.Decisions = append(b.Decisions, Tentative)
+			b.Hits = append(b.Hits, 0)
+			b.History = append(b.History, make([]float64, 0))
+		}
+	}
+	return
+}
+
+//Undecided returns the number of features that are still tentative.
+func (b *Boruta) Undecided() (n int) {
+	for _, d := range b.Decisions {
+		if d == Tentative {
+			n++
+		}
+	}
+	return
+}
+
+/*
+Round grows a forest of nTrees on the features that haven't been rejected and their
+shadows and updates the decisions using the specified p-value threshold. mTry and
+leafSize are as in GrowRandomForest; if mTry is <= 0 the square root of the number of
+features (including shadows) is used.
+*/
+func (b *Boruta) Round(fm *FeatureMatrix, nTrees int, mTry int, leafSize int, pValue float64) {
+	//build a matrix with the target, the features still in play and their shadows
+	target := fm.Data[b.Target]
+	round := &FeatureMatrix{make([]Feature, 0, 2*len(b.Features)+1),
+		make(map[string]int),
+		fm.CaseLabels}
+	round.Map[target.GetName()] = 0
+	round.Data = append(round.Data, target)
+
+	active := make([]int, 0, len(b.Features))
+	for k, fi := range b.Features {
+		if b.Decisions[k] != Rejected {
+			active = append(active, k)
+			round.Map[fm.Data[fi].GetName()] = len(round.Data)
+			round.Data = append(round.Data, fm.Data[fi])
+		}
+	}
+	for _, k := range active {
+		shadow := fm.Data[b.Features[k]].ShuffledCopy()
+		round.Map[shadow.GetName()] = len(round.Data)
+		round.Data = append(round.Data, shadow)
+	}
+
+	candidates := make([]int, 0, len(round.Data)-1)
+	for i := 1; i < len(round.Data); i++ {
+		candidates = append(candidates, i)
+	}
+	if mTry <= 0 {
+		mTry = int(math.Ceil(math.Sqrt(float64(len(candidates)))))
+	}
+
+	importance := NewRunningMeans(len(round.Data))
+	GrowRandomForest(round, target.(Target), candidates, target.Length(), mTry, nTrees, leafSize, false, false, false, false, importance)
+
+	//total decrease in impurity per tree
+	imp := func(i int) float64 {
+		mean, count := (*importance)[i].Read()
+		return mean * count / float64(nTrees)
+	}
+	shadowMax := 0.0
+	for i := len(active) + 1; i < len(round.Data); i++ {
+		shadowMax = math.Max(shadowMax, imp(i))
+	}
+	b.ShadowMax = append(b.ShadowMax, shadowMax)
+	b.Rounds++
+
+	for j, k := range active {
+		v := imp(j + 1)
+		b.History[k] = append(b.History[k], v)
+		if v > shadowMax {
+			b.Hits[k]++
+		}
+	}
+
+	threshold := pValue / float64(len(b.Features))
+	for k := range b.Features {
+		if b.Decisions[k] != Tentative {
+			continue
+		}
+		upper, lower := stats.BinomialTest(b.Hits[k], b.Rounds, 0.5)
+		switch {
+		case upper < threshold:
+			b.Decisions[k] = Confirmed
+		case lower < threshold:
+			b.Decisions[k] = Rejected
+		}
+	}
+}
+
+/*
+TtestVsShadow returns the p-value of a Welch's t-test of the hypothesis that the mean
+importance of the kth feature is greater then the mean maximum shadow importance over
+the rounds it was tested in. It can be used to settle features that are still tentative
+after the final round. It returns NaN if the feature was tested in fewer then two rounds.
+*/
+func (b *Boruta) TtestVsShadow(k int) float64 {
+	hist := b.History[k]
+	if len(hist) < 2 {
+		return math.NaN()
+	}
+	//features are only tested in the first len(hist) rounds
+	shadow := b.ShadowMax[:len(hist)]
+	p, _, _ := stats.Ttest(&shadow, &hist)
+	return p
+}
+
+/*
+WriteDecisions writes the final decision for each feature as a tsv with a header row
+and columns:
+
+	Feature	Decision	Hits	Rounds	MeanImportance	TtestP
+*/
+func (b *Boruta) WriteDecisions(w io.Writer, fm *FeatureMatrix) (err error) {
+	if _, err = fmt.Fprintln(w, "Feature\tDecision\tHits\tRounds\tMeanImportance\tTtestP"); err != nil {
+		return
+	}
+	for k, fi := range b.Features {
+		mean := 0.0
+		for _, v := range b.History[k] {
+			mean += v
+		}
+		mean /= float64(len(b.History[k]))
+		if _, err = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", fm.Data[fi].GetName(), BorutaDecisionNames[b.Decisions[k]], b.Hits[k], len(b.History[k]), mean, b.TtestVsShadow(k)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+/*
+WriteHistory writes the importance of each feature in each round it was tested as a
+tsv with a header row and columns:
+
+	Round	Feature	Importance
+
+The maximum shadow importance for each round is written with the feature name
+"SHADOWMAX".
+*/
+func (b *Boruta) WriteHistory(w io.Writer, fm *FeatureMatrix) (err error) {
+	if _, err = fmt.Fprintln(w, "Round\tFeature\tImportance"); err != nil {
+		return
+	}
+	for r, max := range b.ShadowMax {
+		if _, err = fmt.Fprintf(w, "%v\tSHADOWMAX\t%v\n", r, max); err != nil {
+			return
+		}
+		for k, fi := range b.Features {
+			if r < len(b.History[k]) {
+				if _, err = fmt.Fprintf(w, "%v\t%v\t%v\n", r, fm.Data[fi].GetName(), b.History[k][r]); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return
+}
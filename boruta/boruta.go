@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ryanbressler/CloudForest"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+func main() {
+	fm := flag.String("train",
+		"featurematrix.afm", "AFM formated feature matrix containing training data.")
+	targetname := flag.String("target",
+		"", "The row header of the target in the feature matrix.")
+	outf := flag.String("out",
+		"boruta.tsv", "The name of a file to write the final feature decisions into.")
+	historyf := flag.String("history",
+		"", "The name of a file to write the per round importance history into.")
+	var nRounds int
+	flag.IntVar(&nRounds, "rounds", 100, "Maximum number of rounds of selection.")
+	var nTrees int
+	flag.IntVar(&nTrees, "nTrees", 100, "Number of trees to grow in each round.")
+	var mTry int
+	flag.IntVar(&mTry, "mTry", 0, "Number of candidate features for each split. Ceil(sqrt(nFeatures)) (including shadows) if <=0.")
+	var leafSize int
+	flag.IntVar(&leafSize, "leafSize", 0, "The minimum number of cases on a leaf node. If <=0 will be inferred to 1 for classification 4 for regression.")
+	var pValue float64
+	flag.Float64Var(&pValue, "pvalue", 0.01, "P-value threshold for confirming or rejecting a feature (Bonferroni corrected).")
+
+	flag.Parse()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	//Parse Data
+	fmt.Printf("Loading data from: %v\n", *fm)
+	data, err := CloudForest.LoadAFM(*fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Target : %v\n", *targetname)
+	targeti, ok := data.Map[*targetname]
+	if !ok {
+		log.Fatal("Target not found in data.")
+	}
+
+	if leafSize <= 0 {
+		if data.Data[targeti].NCats() == 0 {
+			//regression
+			leafSize = 4
+		} else {
+			//classification
+			leafSize = 1
+		}
+	}
+
+	boruta := CloudForest.NewBoruta(data, targeti, nil)
+	for round := 0; round < nRounds && boruta.Undecided() > 0; round++ {
+		boruta.Round(data, nTrees, mTry, leafSize, pValue)
+		confirmed, rejected := 0, 0
+		for _, d := range boruta.Decisions {
+			switch d {
+			case CloudForest.Confirmed:
+				confirmed++
+			case CloudForest.Rejected:
+				rejected++
+			}
+		}
+		fmt.Printf("Round %v : %v confirmed, %v rejected, %v tentative\n", round, confirmed, rejected, boruta.Undecided())
+	}
+
+	fmt.Printf("Outputting feature decisions to %v\n", *outf)
+	outfile, err := os.Create(*outf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer outfile.Close()
+	if err = boruta.WriteDecisions(outfile, data); err != nil {
+		log.Fatal(err)
+	}
+
+	if *historyf != "" {
+		fmt.Printf("Outputting importance history to %v\n", *historyf)
+		historyfile, err := os.Create(*historyf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer historyfile.Close()
+		if err = boruta.WriteHistory(historyfile, data); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
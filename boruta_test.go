@@ -0,0 +1,45 @@
+package CloudForest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBoruta(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping boruta feature selection on iris data set.")
+	}
+	fm := ParseLibSVM(strings.NewReader(irislibsvm))
+	fm.AddContrasts(2)
+
+	boruta := NewBoruta(fm, 0, nil)
+	if len(boruta.Features) != 6 {
+		t.Fatalf("Boruta selecting from %v features not 6.", len(boruta.Features))
+	}
+	for round := 0; round < 20 && boruta.Undecided() > 0; round++ {
+		boruta.Round(fm, 20, 0, 1, 0.01)
+	}
+
+	for k, fi := range boruta.Features {
+		name := fm.Data[fi].GetName()
+		switch {
+		case strings.HasSuffix(name, ":SHUFFLED") && boruta.Decisions[k] == Confirmed:
+			t.Errorf("Shuffled contrast %v confirmed.", name)
+		case (name == "3" || name == "4") && boruta.Decisions[k] != Confirmed:
+			t.Errorf("Petal feature %v not confirmed after %v rounds.", name, boruta.Rounds)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := boruta.WriteHistory(&out, fm); err != nil {
+		t.Errorf("Error writing boruta history: %v", err)
+	}
+	out.Reset()
+	if err := boruta.WriteDecisions(&out, fm); err != nil {
+		t.Errorf("Error writing boruta decisions: %v", err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 7 {
+		t.Errorf("Boruta decisions have %v lines not 7.", lines)
+	}
+}
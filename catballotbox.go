@@ -1,6 +1,7 @@
 package CloudForest
 
 import (
+	"math"
 	"sync"
 )
 
@@ -74,6 +75,26 @@ func (bb *CatBallotBox) Tally(i int) (predicted string) {
 
 }
 
+//TallyFraction returns the fraction of the (weighted) votes for case i that were
+//cast for the category "cat". It returns NaN if no votes have been cast for the case.
+func (bb *CatBallotBox) TallyFraction(i int, cat string) (frac float64) {
+	catn, ok := bb.Map[cat]
+	total := 0.0
+	bb.Box[i].Mutex.Lock()
+	for k, v := range bb.Box[i].Map {
+		total += v
+		if ok && k == catn {
+			frac = v
+		}
+	}
+	bb.Box[i].Mutex.Unlock()
+	if total == 0.0 {
+		return math.NaN()
+	}
+	frac /= total
+	return
+}
+
 /*
 TallyError returns the balanced classification error for categorical features.
 
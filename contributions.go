@@ -0,0 +1,241 @@
+package CloudForest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Contributions tallies Saabas style decision path feature contributions. As a case
+travels from the root of a tree to a leaf, the change in the node prediction at each
+step is attributed to the feature used to split the parent node (or shared equally by
+the features of an oblique splitter). The prediction of a
+tree for a case is then the prediction at the root (the bias) plus the sum of the
+contributions.
+
+For regression the node prediction is the node mean. For classification it is the
+one hot encoding of the node's predicted class so that contributions decompose the
+fraction of votes for each class.
+
+Contributions are averaged over trees using the tree weights. Additive forests like those
+grown by gradient boosting (see NewSumContributions) instead sum the weighted contributions
+of their trees and include the forest's Intercept in the bias. For boosted classification,
+node predictions are "class:score", and the contributions decompose each class's summed
+score (the input to the softmax function) rather than its probability.
+
+Like the ballot boxes, Contributions is not thread safe so trees should not contribute to
+the same Contributions in parallel.
+*/
+type Contributions struct {
+	*CatMap
+	Categorical bool
+	Additive    bool
+	Bias        []map[int]float64
+	Features    []map[int]map[int]float64
+	Weights     []float64
+}
+
+//NewContributions initializes Contributions for the number of cases specified by size.
+func NewContributions(size int, categorical bool) (c *Contributions) {
+	c = &Contributions{
+		&CatMap{make(map[string]int),
+			make([]string, 0, 0)},
+		categorical,
+		false,
+		make([]map[int]float64, size),
+		make([]map[int]map[int]float64, size),
+		make([]float64, size)}
+	for i := 0; i < size; i++ {
+		c.Bias[i] = make(map[int]float64)
+		c.Features[i] = make(map[int]map[int]float64)
+	}
+	return
+}
+
+//NewSumContributions initializes Contributions for the number of cases specified by size
+//for additive forests whose predictions are intercept plus the weighted sum of the votes
+//of their trees (GBTForest) or whose trees vote "class:score" (GBTClassForest, whose
+//intercepts are trees of their own).
+func NewSumContributions(size int, categorical bool, intercept float64) (c *Contributions) {
+	c = NewContributions(size, categorical)
+	c.Additive = true
+	if !categorical {
+		for i := 0; i < size; i++ {
+			c.Bias[i][0] = intercept
+		}
+	}
+	return
+}
+
+//nodeValue parses the prediction of a node into a map from class index (0 for
+//regression) to value.
+func (c *Contributions) nodeValue(pred string) (value map[int]float64, err error) {
+	if pred == "" {
+		return nil, errors.New("Node has no prediction. Contributions require interior node predictions.")
+	}
+	if c.Categorical && c.Additive {
+		sep := strings.LastIndex(pred, ":")
+		if sep < 0 {
+			return nil, fmt.Errorf("Node prediction %v isn't a class:score pair.", pred)
+		}
+		v, err := strconv.ParseFloat(pred[sep+1:], 64)
+		if err != nil {
+			return nil, err
+		}
+		return map[int]float64{c.CatToNum(pred[:sep]): v}, nil
+	}
+	if c.Categorical {
+		return map[int]float64{c.CatToNum(pred): 1.0}, nil
+	}
+	v, err := strconv.ParseFloat(pred, 64)
+	if err != nil {
+		return
+	}
+	return map[int]float64{0: v}, nil
+}
+
+/*
+Contribute tallies the decision path contributions for every case in fm as it
+passes through the tree. It returns an error if a node on the path doesn't have a
+prediction (as in forests grown before interior predictions were stored) or if the
+tree splits on a feature that is not in fm.
+*/
+func (t *Tree) Contribute(fm *FeatureMatrix, c *Contributions) (err error) {
+	weight := 1.0
+	if t.Weight >= 0.0 {
+		weight = t.Weight
+	}
+
+	ncases := fm.Data[0].Length()
+	cases := make([]int, 0, ncases)
+	for i := 0; i < ncases; i++ {
+		cases = append(cases, i)
+	}
+
+	parents := make(map[*Node]*Node)
+	values := make(map[*Node]map[int]float64)
+
+	t.Root.Recurse(func(n *Node, cases []int, depth int) {
+		if err != nil {
+			return
+		}
+		val, e := c.nodeValue(n.Pred)
+		if e != nil {
+			err = e
+			return
+		}
+		values[n] = val
+		for _, child := range append([]*Node{n.Left, n.Right, n.Missing}, n.Branches...) {
+			if child != nil {
+				parents[child] = n
+			}
+		}
+
+		parent, ok := parents[n]
+		if !ok {
+			//root node
+			for _, i := range cases {
+				c.Weights[i] += weight
+				for k, v := range val {
+					c.Bias[i][k] += weight * v
+				}
+			}
+			return
+		}
+
+		names := []string{parent.Splitter.Feature}
+		if parent.Splitter.Oblique() {
+			names = parent.Splitter.Features
+		}
+		fis := make([]int, 0, len(names))
+		for _, name := range names {
+			fi, ok := fm.Map[name]
+			if !ok {
+				err = fmt.Errorf("Splitting feature %v not found in data.", name)
+				return
+			}
+			fis = append(fis, fi)
+		}
+		//the contributions of oblique splits are shared equally by their features
+		share := weight / float64(len(fis))
+		diff := make(map[int]float64)
+		for k, v := range val {
+			diff[k] += v
+		}
+		for k, v := range values[parent] {
+			diff[k] -= v
+		}
+		for _, i := range cases {
+			for _, fi := range fis {
+				fc, ok := c.Features[i][fi]
+				if !ok {
+					fc = make(map[int]float64)
+					c.Features[i][fi] = fc
+				}
+				for k, v := range diff {
+					fc[k] += share * v
+				}
+			}
+		}
+
+	}, fm, cases, 0)
+	return
+}
+
+/*
+WriteTsv writes the weighted mean (or for additive forests summed) contributions out as a
+tsv with one row per case, feature (and class for classification). The bias for each case is
+reported with the feature name "BIAS". Rows are:
+
+	Case	Feature	Contribution
+
+for regression and:
+
+	Case	Feature	Class	Contribution
+
+for classification.
+*/
+func (c *Contributions) WriteTsv(w io.Writer, fm *FeatureMatrix) (err error) {
+	for i, label := range fm.CaseLabels {
+		if c.Weights[i] == 0.0 {
+			continue
+		}
+		weight := c.Weights[i]
+		if c.Additive {
+			weight = 1.0
+		}
+		if err = c.writeRows(w, label, "BIAS", c.Bias[i], weight); err != nil {
+			return
+		}
+		fis := make([]int, 0, len(c.Features[i]))
+		for fi := range c.Features[i] {
+			fis = append(fis, fi)
+		}
+		sort.Ints(fis)
+		for _, fi := range fis {
+			if err = c.writeRows(w, label, fm.Data[fi].GetName(), c.Features[i][fi], weight); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (c *Contributions) writeRows(w io.Writer, label string, feature string, vals map[int]float64, weight float64) (err error) {
+	if !c.Categorical {
+		_, err = fmt.Fprintf(w, "%v\t%v\t%v\n", label, feature, vals[0]/weight)
+		return
+	}
+	for k, class := range c.Back {
+		if v, ok := vals[k]; ok && v != 0.0 {
+			if _, err = fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", label, feature, class, v/weight); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
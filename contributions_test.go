@@ -0,0 +1,131 @@
+package CloudForest
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestContributions(t *testing.T) {
+	fm := ParseAFM(strings.NewReader(fm))
+	candidates := []int{2, 3, 4}
+	nCases := fm.Data[0].Length()
+
+	numtarget := fm.Data[0]
+	forest := GrowRandomForest(fm, numtarget.(Feature), candidates, nCases, 3, 10, 1, false, false, false, false, nil)
+
+	bb := NewNumBallotBox(nCases)
+	contribs := NewContributions(nCases, false)
+	for _, tree := range forest.Trees {
+		tree.Vote(fm, bb)
+		if err := tree.Contribute(fm, contribs); err != nil {
+			t.Fatalf("Error calculating contributions: %v", err)
+		}
+	}
+
+	for i := 0; i < nCases; i++ {
+		sum := contribs.Bias[i][0]
+		for _, fc := range contribs.Features[i] {
+			sum += fc[0]
+		}
+		sum /= contribs.Weights[i]
+		if pred := bb.TallyNum(i); math.Abs(sum-pred) > 1e-9 {
+			t.Errorf("Bias plus contributions %v doesn't equal prediction %v for case %v.", sum, pred, i)
+		}
+	}
+
+	cattarget := fm.Data[1]
+	forest = GrowRandomForest(fm, cattarget.(Feature), candidates, nCases, 3, 10, 1, false, false, false, false, nil)
+	cbb := NewCatBallotBox(nCases)
+	contribs = NewContributions(nCases, true)
+	for _, tree := range forest.Trees {
+		tree.Vote(fm, cbb)
+		if err := tree.Contribute(fm, contribs); err != nil {
+			t.Fatalf("Error calculating contributions: %v", err)
+		}
+	}
+	for i := 0; i < nCases; i++ {
+		pred := cbb.Tally(i)
+		k := contribs.CatToNum(pred)
+		sum := contribs.Bias[i][k]
+		for _, fc := range contribs.Features[i] {
+			sum += fc[k]
+		}
+		sum /= contribs.Weights[i]
+		if frac := cbb.TallyFraction(i, pred); math.Abs(sum-frac) > 1e-9 {
+			t.Errorf("Bias plus contributions %v doesn't equal vote fraction %v for case %v.", sum, frac, i)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := contribs.WriteTsv(&out, fm); err != nil {
+		t.Errorf("Error writing contributions: %v", err)
+	}
+	if !strings.Contains(out.String(), "BIAS") {
+		t.Errorf("Contributions output doesn't include the bias.")
+	}
+
+	tree := new(Tree)
+	tree.AddNode("*", "", &Splitter{Feature: "C:QuadVar", Left: map[string]bool{"0": true}})
+	tree.AddNode("*L", "0", nil)
+	tree.AddNode("*R", "1", nil)
+	if err := tree.Contribute(fm, NewContributions(nCases, true)); err == nil {
+		t.Errorf("Contributions calculated for a tree without interior predictions.")
+	}
+}
+
+func TestBoostedContributions(t *testing.T) {
+	fm := ParseAFM(strings.NewReader(fm))
+	candidates := []int{2, 3, 4}
+	nCases := fm.Data[0].Length()
+
+	//boosted regression contributions are summed over trees and the bias includes the intercept
+	gbt := NewGradBoostTarget(fm.Data[0].(NumFeature), SquaredLoss{}, 0.1)
+	forest := GrowRandomForest(fm, gbt, candidates, nCases, 3, 10, 1, false, false, false, false, nil)
+	bb := NewSumBallotBox(nCases, forest.Intercept)
+	contribs := NewSumContributions(nCases, false, forest.Intercept)
+	for _, tree := range forest.Trees {
+		tree.Vote(fm, bb)
+		if err := tree.Contribute(fm, contribs); err != nil {
+			t.Fatalf("Error calculating boosted contributions: %v", err)
+		}
+	}
+	for i := 0; i < nCases; i++ {
+		sum := contribs.Bias[i][0]
+		for _, fc := range contribs.Features[i] {
+			sum += fc[0]
+		}
+		if pred := bb.TallyNum(i); math.Abs(sum-pred) > 1e-9 {
+			t.Errorf("Bias plus boosted contributions %v doesn't equal prediction %v for case %v.", sum, pred, i)
+		}
+	}
+
+	//boosted classification contributions decompose the score of each class
+	gbc := NewGradBoostClassTarget(fm.Data[1].(CatFeature), 0.2)
+	forest = GrowRandomForest(fm, gbc, candidates, nCases, 3, 10, 1, false, false, false, false, nil)
+	sbb := NewSoftmaxBallotBox(nCases)
+	contribs = NewSumContributions(nCases, true, 0.0)
+	for _, tree := range forest.Trees {
+		tree.Vote(fm, sbb)
+		if err := tree.Contribute(fm, contribs); err != nil {
+			t.Fatalf("Error calculating boosted class contributions: %v", err)
+		}
+	}
+	for i := 0; i < nCases; i++ {
+		scores := make([]float64, len(sbb.Back))
+		for k, class := range sbb.Back {
+			ck := contribs.CatToNum(class)
+			scores[k] = contribs.Bias[i][ck]
+			for _, fc := range contribs.Features[i] {
+				scores[k] += fc[ck]
+			}
+		}
+		probs := sbb.TallyProbabilities(i)
+		for k, p := range softmax(scores) {
+			if math.Abs(p-probs[k]) > 1e-9 {
+				t.Errorf("Softmax of bias plus contributions %v doesn't equal probability %v of class %v for case %v.", p, probs[k], sbb.Back[k], i)
+			}
+		}
+	}
+}
@@ -24,6 +24,18 @@ C:QuadVar	NA	0	1	2	3	4	3	0
 C:BoolVar	0 	0	1	1	NA	1	1	1
 N:FloatVar	.9	NA	.9	.9	.2	.9	.9	.9`
 
+//parseRows returns a feature matrix with a row for each of the named features holding
+//the corresponding values, one per case.
+func parseRows(names []string, values [][]string) *FeatureMatrix {
+	n := len(values[0])
+	rows := make([]string, 0, len(names)+1)
+	rows = append(rows, ".\t"+strings.Repeat("c\t", n-1)+"c")
+	for k, name := range names {
+		rows = append(rows, name+"\t"+strings.Join(values[k], "\t"))
+	}
+	return ParseAFM(strings.NewReader(strings.Join(rows, "\n") + "\n"))
+}
+
 //Note: Iris and Boston Housing Data in string literals at end of File.
 
 func GetAllClassificationTargets(f CatFeature) []Target {
@@ -47,7 +59,7 @@ func GetAllRegressionTargets(f NumFeature) []Target {
 	return []Target{f,
 		&L1Target{f},
 		//NewOrdinalTarget(f),
-		//&GradBoostTarget{f.Copy().(NumFeature), .1},
+		//NewGradBoostTarget(f, SquaredLoss{}, .1),
 		//NewNumAdaBoostTarget(f.Copy().(NumFeature)),
 	}
 }
@@ -80,7 +92,7 @@ func TestWierdTargets(t *testing.T) {
 			//&DensityTarget{&fm.Data, fm.Data[0].Length()},
 			regret,
 			NewOrdinalTarget(numtarget),
-			&GradBoostTarget{numtarget.Copy().(NumFeature), .1},
+			NewGradBoostTarget(numtarget, SquaredLoss{}, .1),
 			NewNumAdaBoostTarget(numtarget.Copy().(NumFeature)),
 		}
 
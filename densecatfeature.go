@@ -167,10 +167,10 @@ func (f *DenseCatFeature) BestSplit(target Target,
 		total = len(*cases)
 		nonmissing = total - nmissing
 
-		nonmissingparentImp = target.Impurity(allocs.NonMissing, allocs.Counter)
+		nonmissingparentImp = allocs.Impurity(target, allocs.NonMissing)
 
 		if nmissing > 0 {
-			missingimp = target.Impurity(allocs.Right, allocs.Counter)
+			missingimp = allocs.Impurity(target, allocs.Right)
 		}
 		tosplit = allocs.NonMissing
 	} else {
@@ -180,7 +180,18 @@ func (f *DenseCatFeature) BestSplit(target Target,
 
 	//TODO: reverse this list, common cases first and maybe make it a switch statement
 	nCats := f.NCats()
-	if f.RandomSearch == false && nCats > maxNonBigCats {
+	var multiway *MultiwaySplit
+	splitInfo := 1.0
+	if allocs.Multiway > 0 {
+		multiway, impurityDecrease, splitInfo, constant = f.BestMultiwaySplit(target, tosplit, nonmissingparentImp, leafSize, allocs)
+	}
+	if multiway != nil {
+		codedSplit = multiway
+	} else if constant {
+		return
+	} else if allocs.ExtraTrees {
+		codedSplit, impurityDecrease, constant = f.BestRandomCatSplit(target, tosplit, nonmissingparentImp, leafSize, allocs)
+	} else if f.RandomSearch == false && nCats > maxNonBigCats {
 		codedSplit, impurityDecrease, constant = f.BestCatSplitIterBig(target, tosplit, nonmissingparentImp, leafSize, allocs)
 	} else if f.RandomSearch == false && nCats > maxExhaustiveCats {
 		codedSplit, impurityDecrease, constant = f.BestCatSplitIter(target, tosplit, nonmissingparentImp, leafSize, allocs)
@@ -195,6 +206,10 @@ func (f *DenseCatFeature) BestSplit(target Target,
 	if f.HasMissing && nmissing > 0 && impurityDecrease > minImp {
 		impurityDecrease = parentImp + ((float64(nonmissing)*(impurityDecrease-nonmissingparentImp) - float64(nmissing)*missingimp) / float64(total))
 	}
+	if multiway != nil {
+		//gain ratio of the decrease including the missing cases
+		impurityDecrease /= splitInfo
+	}
 	return
 
 }
@@ -331,12 +346,19 @@ func (f *DenseCatFeature) SplitPoints(codedSplit interface{}, cs *[]int) (int, i
 //DecodeSplit builds a splitter from the numeric values returned by BestNumSplit or
 //BestCatSplit. Numeric splitters are decoded to send values <= num left. Categorical
 //splitters are decoded to send categorical values for which the bit in cat is 1 left.
+//A *MultiwaySplit is decoded to a multiway splitter with the categories of its branches.
 func (f *DenseCatFeature) DecodeSplit(codedSplit interface{}) (s *Splitter) {
 
 	nCats := f.NCats()
-	s = &Splitter{f.Name, false, 0.0, make(map[string]bool, nCats)}
+	s = &Splitter{Feature: f.Name, Left: make(map[string]bool, nCats)}
 
 	switch codedSplit.(type) {
+	case *MultiwaySplit:
+		s.Left = nil
+		for _, cat := range codedSplit.(*MultiwaySplit).Cats {
+			s.Levels = append(s.Levels, f.Back[cat])
+		}
+		s.Fallback = codedSplit.(*MultiwaySplit).Fallback
 	case int:
 		cat := codedSplit.(int)
 		for j := 0; j < nCats; j++ {
@@ -806,6 +828,99 @@ func (f *DenseCatFeature) BestCatSplitBig(target Target, cases *[]int, parentImp
 	return
 }
 
+/*
+BestRandomCatSplit sends a random non empty subset of the categories present in cases left,
+as in Geurts et al.'s extremely randomized trees, and returns it (coded as an int or big.Int
+for features with more than 30 categories like the other searches) and the resulting
+impurity decrease. Subsets that leave less than leafSize cases on either side yield no
+impurity decrease.
+
+It expects to be provided cases for which the feature is not missing.
+*/
+func (f *DenseCatFeature) BestRandomCatSplit(target Target,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool) {
+
+	impurityDecrease = minImp
+	codedSplit = 0
+
+	if len(*cases) == 0 || len(*cases) < 2*leafSize {
+		return
+	}
+
+	left := (*allocs.Left)[0:0]
+	right := (*allocs.Right)[0:0]
+
+	if f.NCats() > maxNonBigCats {
+		present := big.NewInt(0)
+		npresent := 0
+		for _, c := range *cases {
+			if present.Bit(f.CatData[c]) == 0 {
+				present.SetBit(present, f.CatData[c], 1)
+				npresent++
+			}
+		}
+		constant = npresent < 2
+		if constant {
+			return
+		}
+
+		bits := big.NewInt(0)
+		for nleft := 0; nleft == 0 || nleft == npresent; {
+			nleft = 0
+			for j := 0; j < present.BitLen(); j++ {
+				b := uint(0)
+				if present.Bit(j) == 1 && rand.Intn(2) == 1 {
+					b = 1
+					nleft++
+				}
+				bits.SetBit(bits, j, b)
+			}
+		}
+		for _, c := range *cases {
+			if bits.Bit(f.CatData[c]) != 0 {
+				left = append(left, c)
+			} else {
+				right = append(right, c)
+			}
+		}
+		codedSplit = bits
+	} else {
+		present := 0
+		for _, c := range *cases {
+			present |= 1 << uint(f.CatData[c])
+		}
+		//more than one category is present if clearing the lowest bit leaves any
+		constant = present&(present-1) == 0
+		if constant {
+			return
+		}
+
+		bits := 0
+		for bits == 0 || bits == present {
+			bits = rand.Int() & present
+		}
+		for _, c := range *cases {
+			if 0 != (bits & (1 << uint(f.CatData[c]))) {
+				left = append(left, c)
+			} else {
+				right = append(right, c)
+			}
+		}
+		codedSplit = bits
+	}
+
+	if len(left) < leafSize || len(right) < leafSize {
+		codedSplit = 0
+		return
+	}
+
+	impurityDecrease = parentImp - target.SplitImpurity(&left, &right, nil, allocs)
+	return
+}
+
 /*
 FilterMissing loops over the cases and appends them into filtered.
 For most use cases filtered should have zero length before you begin as it is not reset
@@ -1115,3 +1230,41 @@ func (f *DenseCatFeature) ImputeMissing() {
 	}
 	f.HasMissing = false
 }
+
+//NHistStats returns the number of statistics, the count and the count of each category,
+//used by DenseCatFeature as a HistogramTarget.
+func (target *DenseCatFeature) NHistStats() int {
+	return 1 + target.NCats()
+}
+
+//AddHistStats adds case i to the total and category counts in stats.
+func (target *DenseCatFeature) AddHistStats(i int, stats []float64) {
+	stats[0]++
+	stats[1+target.CatData[i]]++
+}
+
+//HistImpurity returns the gini impurity of cases with the summed stats as Impurity would.
+func (target *DenseCatFeature) HistImpurity(stats []float64) (e float64) {
+	if stats[0] == 0.0 {
+		return 0.0
+	}
+	e++
+	t := stats[0] * stats[0]
+	for _, c := range stats[1:] {
+		e -= c * c / t
+	}
+	return
+}
+
+//HistSplitImpurity returns the impurity of a split with the summed left, right and missing
+//(which may be nil) stats as SplitImpurity would.
+func (target *DenseCatFeature) HistSplitImpurity(l []float64, r []float64, m []float64) (impurityDecrease float64) {
+	n := l[0] + r[0]
+	impurityDecrease = l[0]*target.HistImpurity(l) + r[0]*target.HistImpurity(r)
+	if m != nil && m[0] > 0.0 {
+		n += m[0]
+		impurityDecrease += m[0] * target.HistImpurity(m)
+	}
+	impurityDecrease /= n
+	return
+}
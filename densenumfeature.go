@@ -210,7 +210,7 @@ func (f *DenseNumFeature) SplitPoints(codedSplit interface{}, cs *[]int) (int, i
 //splitters are decoded to send categorical values for which the bit in cat is 1 left.
 func (f *DenseNumFeature) DecodeSplit(codedSplit interface{}) (s *Splitter) {
 
-	s = &Splitter{f.Name, true, codedSplit.(float64), nil}
+	s = &Splitter{Feature: f.Name, Numerical: true, Value: codedSplit.(float64)}
 
 	return
 }
@@ -227,6 +227,35 @@ func (f *DenseNumFeature) BestSplit(target Target,
 	parentImp float64,
 	leafSize int,
 	allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool) {
+	if allocs.ExtraTrees {
+		return f.bestSplitWithMissing(target, cases, parentImp, leafSize, allocs, f.BestRandomNumSplit)
+	}
+	if sorted := allocs.Presort.Sorted(f, *cases); sorted != nil {
+		//missing cases are at the end of the presorted cases
+		nonmissing := len(sorted)
+		for f.HasMissing && nonmissing > 0 && f.Missing[sorted[nonmissing-1]] {
+			nonmissing--
+		}
+		sorted = sorted[:nonmissing]
+		return f.bestSplitWithMissing(target, cases, parentImp, leafSize, allocs, func(target Target, cases *[]int, parentImp float64, leafSize int, allocs *BestSplitAllocs) (interface{}, float64, bool) {
+			return f.BestSortedSplit(target, sorted, parentImp, leafSize, allocs)
+		})
+	}
+	return f.bestSplitWithMissing(target, cases, parentImp, leafSize, allocs, f.BestNumSplit)
+}
+
+//numSplitSearcher searches for the best split of cases for which a feature isn't missing
+//like BestNumSplit.
+type numSplitSearcher func(target Target, cases *[]int, parentImp float64, leafSize int, allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool)
+
+//bestSplitWithMissing implements BestSplit using search to find the best split of the non
+//missing cases and accounting for the impurity of the missing cases.
+func (f *DenseNumFeature) bestSplitWithMissing(target Target,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs,
+	search numSplitSearcher) (codedSplit interface{}, impurityDecrease float64, constant bool) {
 
 	var nmissing, nonmissing, total int
 	var nonmissingparentImp, missingimp float64
@@ -249,10 +278,10 @@ func (f *DenseNumFeature) BestSplit(target Target,
 		total = len(*cases)
 		nonmissing = total - nmissing
 
-		nonmissingparentImp = target.Impurity(allocs.NonMissing, allocs.Counter)
+		nonmissingparentImp = allocs.Impurity(target, allocs.NonMissing)
 
 		if nmissing > 0 {
-			missingimp = target.Impurity(allocs.Right, allocs.Counter)
+			missingimp = allocs.Impurity(target, allocs.Right)
 		}
 		tosplit = allocs.NonMissing
 	} else {
@@ -260,7 +289,7 @@ func (f *DenseNumFeature) BestSplit(target Target,
 		tosplit = cases
 	}
 
-	codedSplit, impurityDecrease, constant = f.BestNumSplit(target, tosplit, nonmissingparentImp, leafSize, allocs)
+	codedSplit, impurityDecrease, constant = search(target, tosplit, nonmissingparentImp, leafSize, allocs)
 
 	if f.HasMissing && nmissing > 0 && impurityDecrease > minImp {
 		impurityDecrease = parentImp + ((float64(nonmissing)*(impurityDecrease-nonmissingparentImp) - float64(nmissing)*missingimp) / float64(total))
@@ -298,44 +327,76 @@ func (f *DenseNumFeature) BestNumSplit(target Target,
 		//sort.Sort(sorter)
 		sorter.Sort()
 
-		lastsplit := 0
-		innerimp := 0.0
-		stop := (len(sorter.Cases) - leafSize)
-		constant = (f.NumData[sorter.Cases[0]] + constant_cutoff) >= f.NumData[sorter.Cases[len(sorter.Cases)-1]]
-		if constant {
-			impurityDecrease = minImp
-			return
-		}
-		for i := leafSize; i < stop; i++ {
-			c := sorter.Cases[i]
-			//skip cases where the next sorted case has the same value as these can't be split on
-			if f.NumData[c] <= (f.NumData[sorter.Cases[i-1]] + constant_cutoff) {
-				continue
-			}
+		codedSplit, impurityDecrease, constant = f.BestSortedSplit(target, sorter.Cases, parentImp, leafSize, allocs)
+	}
 
-			/*		BUG there is a reallocation of a slice (not the underlying array) happening here in
-					BestNumSplit accounting for a chunk of runtime. Tried copying data between *l and *r
-					but it was slower.  */
-			if lastsplit == 0 {
-				allocs.LM = sorter.Cases[:i]
-				allocs.RM = sorter.Cases[i:]
-				innerimp = parentImp - target.SplitImpurity(&allocs.LM, &allocs.RM, nil, allocs)
-				lastsplit = i
-			} else {
-				allocs.LM = sorter.Cases[:i]
-				allocs.RM = sorter.Cases[i:]
-				allocs.MM = sorter.Cases[lastsplit:i]
-				innerimp = parentImp - target.UpdateSImpFromAllocs(&allocs.LM, &allocs.RM, nil, allocs, &allocs.MM)
-				lastsplit = i
-			}
+	return
+}
 
-			if innerimp > impurityDecrease {
-				impurityDecrease = innerimp
-				codedSplit = (f.NumData[sorter.Cases[i-1]] + f.NumData[c]) / 2.0
-				//fmt.Println(len(sorter.Cases), sorter.Vals, allocs.LM, allocs.RM, codedSplit, impurityDecrease)
+/*
+BestSortedSplit evaluates each "gap" between cases with non equal value in sorted, which
+should hold non missing cases sorted by f's value, as a potential split and returns the
+one that minimizes the impurity of the target and the impurity decrease. It is used by
+BestNumSplit after sorting and directly on presorted cases (see PresortedCases).
 
-			}
+If f is constrained by allocs.Monotone splits whose children's mean target values are in
+the wrong order are skipped.
+*/
+func (f *DenseNumFeature) BestSortedSplit(target Target,
+	sorted []int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool) {
+
+	impurityDecrease = minImp
+	codedSplit = 0.0
+
+	if len(sorted) < 2*leafSize || len(sorted) == 0 {
+		return
+	}
+
+	lastsplit := 0
+	innerimp := 0.0
+	stop := (len(sorted) - leafSize)
+	constant = (f.NumData[sorted[0]] + constant_cutoff) >= f.NumData[sorted[len(sorted)-1]]
+	if constant {
+		impurityDecrease = minImp
+		return
+	}
 
+	//running sums of the target for checking monotone constraints
+	sums := newMonotoneSums(target, sorted, allocs.Monotone.searching())
+
+	for i := leafSize; i < stop; i++ {
+		c := sorted[i]
+		//skip cases where the next sorted case has the same value as these can't be split on
+		if f.NumData[c] <= (f.NumData[sorted[i-1]] + constant_cutoff) {
+			continue
+		}
+
+		/*		BUG there is a reallocation of a slice (not the underlying array) happening here in
+				BestNumSplit accounting for a chunk of runtime. Tried copying data between *l and *r
+				but it was slower.  */
+		if lastsplit == 0 {
+			allocs.LM = sorted[:i]
+			allocs.RM = sorted[i:]
+			innerimp = parentImp - target.SplitImpurity(&allocs.LM, &allocs.RM, nil, allocs)
+			lastsplit = i
+		} else {
+			allocs.LM = sorted[:i]
+			allocs.RM = sorted[i:]
+			allocs.MM = sorted[lastsplit:i]
+			innerimp = parentImp - target.UpdateSImpFromAllocs(&allocs.LM, &allocs.RM, nil, allocs, &allocs.MM)
+			lastsplit = i
+		}
+
+		if sums != nil && !sums.allows(allocs.Monotone, sorted, i) {
+			continue
+		}
+
+		if innerimp > impurityDecrease {
+			impurityDecrease = innerimp
+			codedSplit = (f.NumData[sorted[i-1]] + f.NumData[c]) / 2.0
 		}
 
 	}
@@ -343,6 +404,66 @@ func (f *DenseNumFeature) BestNumSplit(target Target,
 	return
 }
 
+/*
+BestRandomNumSplit draws a single threshold uniformly between the smallest and largest value
+of f in cases, as in Geurts et al.'s extremely randomized trees, and returns it and the
+resulting impurity decrease. Thresholds that leave less than leafSize cases on either side
+or violate a monotone constraint on f yield no impurity decrease.
+
+It expects to be provided cases for which the feature is not missing.
+*/
+func (f *DenseNumFeature) BestRandomNumSplit(target Target,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (codedSplit interface{}, impurityDecrease float64, constant bool) {
+
+	impurityDecrease = minImp
+	codedSplit = 0.0
+
+	if len(*cases) == 0 || len(*cases) < 2*leafSize {
+		return
+	}
+
+	min := f.NumData[(*cases)[0]]
+	max := min
+	for _, c := range *cases {
+		min = math.Min(min, f.NumData[c])
+		max = math.Max(max, f.NumData[c])
+	}
+	constant = (min + constant_cutoff) >= max
+	if constant {
+		return
+	}
+
+	split := min + rand.Float64()*(max-min)
+	left := (*allocs.Left)[0:0]
+	right := (*allocs.Right)[0:0]
+	for _, c := range *cases {
+		if f.NumData[c] <= split {
+			left = append(left, c)
+		} else {
+			right = append(right, c)
+		}
+	}
+	if len(left) < leafSize || len(right) < leafSize || len(right) == 0 {
+		return
+	}
+	if dir := allocs.Monotone.searching(); dir != 0 {
+		if num, ok := target.(NumFeature); ok {
+			l, lok := meanValue(num, left)
+			r, rok := meanValue(num, right)
+			if !lok || !rok || !allocs.Monotone.Allows(dir, l, r) {
+				return
+			}
+		}
+	}
+
+	codedSplit = split
+	impurityDecrease = parentImp - target.SplitImpurity(&left, &right, nil, allocs)
+	return
+}
+
 /*
 FilterMissing loops over the cases and appends them into filtered.
 For most use cases filtered should have zero length before you begin as it is not reset
@@ -654,3 +775,38 @@ func (f *DenseNumFeature) ImputeMissing() {
 	}
 	f.HasMissing = false
 }
+
+//NHistStats returns the number of statistics, the count, sum and sum of squares, used by
+//DenseNumFeature as a HistogramTarget.
+func (target *DenseNumFeature) NHistStats() int {
+	return 3
+}
+
+//AddHistStats adds 1, the value and the square of the value of case i to stats.
+func (target *DenseNumFeature) AddHistStats(i int, stats []float64) {
+	x := target.NumData[i]
+	stats[0]++
+	stats[1] += x
+	stats[2] += x * x
+}
+
+//HistImpurity returns the impurity of cases with the summed stats as Impurity would.
+func (target *DenseNumFeature) HistImpurity(stats []float64) (e float64) {
+	if stats[0] == 0.0 {
+		return 0.0
+	}
+	return stats[2] - stats[1]*stats[1]/stats[0]
+}
+
+//HistSplitImpurity returns the impurity of a split with the summed left, right and missing
+//(which may be nil) stats as SplitImpurity would.
+func (target *DenseNumFeature) HistSplitImpurity(l []float64, r []float64, m []float64) (impurityDecrease float64) {
+	n := l[0] + r[0]
+	impurityDecrease = l[0]*target.HistImpurity(l) + r[0]*target.HistImpurity(r)
+	if m != nil && m[0] > 0.0 {
+		n += m[0]
+		impurityDecrease += m[0] * target.HistImpurity(m)
+	}
+	impurityDecrease /= n
+	return
+}
@@ -0,0 +1,250 @@
+package CloudForest
+
+import (
+	"math"
+)
+
+/*
+devianceFamily abstracts the exponential dispersion families used by PoissonTarget,
+GammaTarget and TweedieTarget. For these families the deviance of a set of cases vs their
+mean depends only on the number of cases, the sum of the values and the sum of a
+per case statistic so split impurities can be updated incrementally as cases are moved
+from right to left.
+*/
+type devianceFamily interface {
+	stat(y float64) float64
+	deviance(n float64, sum float64, statsum float64) float64
+}
+
+//devianceSums returns the sum of the target and the family's statistic over the cases.
+func devianceSums(target NumFeature, fam devianceFamily, cases *[]int) (sum float64, statsum float64) {
+	for _, i := range *cases {
+		y := target.Get(i)
+		sum += y
+		statsum += fam.stat(y)
+	}
+	return
+}
+
+//devianceImpurity returns the mean deviance of the cases vs their mean.
+func devianceImpurity(target NumFeature, fam devianceFamily, cases *[]int) float64 {
+	n := float64(len(*cases))
+	if n == 0.0 {
+		return 0.0
+	}
+	sum, statsum := devianceSums(target, fam, cases)
+	return fam.deviance(n, sum, statsum) / n
+}
+
+/*
+devianceSplitImpurity returns the total deviance of the left, right and missing cases
+(each vs their own mean) divided by the total number of cases. The sums of the target
+and the family's statistic are stored in allocs (in Lsum and Lsum_sqr etc. in place of
+the sums of squares used by DenseNumFeature) for use by devianceUpdateSImp.
+*/
+func devianceSplitImpurity(target NumFeature, fam devianceFamily, l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	nl := float64(len(*l))
+	nr := float64(len(*r))
+	nm := 0.0
+
+	allocs.Lsum, allocs.Lsum_sqr = devianceSums(target, fam, l)
+	impurityDecrease = fam.deviance(nl, allocs.Lsum, allocs.Lsum_sqr)
+
+	allocs.Rsum, allocs.Rsum_sqr = devianceSums(target, fam, r)
+	impurityDecrease += fam.deviance(nr, allocs.Rsum, allocs.Rsum_sqr)
+
+	if m != nil && len(*m) > 0 {
+		nm = float64(len(*m))
+		allocs.Msum, allocs.Msum_sqr = devianceSums(target, fam, m)
+		impurityDecrease += fam.deviance(nm, allocs.Msum, allocs.Msum_sqr)
+	}
+
+	impurityDecrease /= nl + nr + nm
+	return
+}
+
+//devianceUpdateSImp moves the sums of the cases in movedRtoL from right to left in allocs
+//and recalculates the split impurity.
+func devianceUpdateSImp(target NumFeature, fam devianceFamily, l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	sum, statsum := devianceSums(target, fam, movedRtoL)
+	allocs.Lsum += sum
+	allocs.Rsum -= sum
+	allocs.Lsum_sqr += statsum
+	allocs.Rsum_sqr -= statsum
+
+	nl := float64(len(*l))
+	nr := float64(len(*r))
+	nm := 0.0
+
+	impurityDecrease = fam.deviance(nl, allocs.Lsum, allocs.Lsum_sqr)
+	impurityDecrease += fam.deviance(nr, allocs.Rsum, allocs.Rsum_sqr)
+	if m != nil && len(*m) > 0 {
+		nm = float64(len(*m))
+		impurityDecrease += fam.deviance(nm, allocs.Msum, allocs.Msum_sqr)
+	}
+
+	impurityDecrease /= nl + nr + nm
+	return
+}
+
+//xlogx returns x*log(x) with 0*log(0) = 0.
+func xlogx(x float64) float64 {
+	if x <= 0.0 {
+		return 0.0
+	}
+	return x * math.Log(x)
+}
+
+/*
+PoissonTarget wraps a numerical feature of non negative counts as a target for Poisson
+regression. Impurity is the mean Poisson deviance vs the mean:
+
+	2/n * sum(y*log(y/mu) - (y-mu)) = 2/n * (sum(y*log(y)) - sum(y)*log(mu))
+
+and nodes predict the mean (the maximum likelihood rate). Cases are expected to have
+non missing targets.
+*/
+type PoissonTarget struct {
+	NumFeature
+}
+
+type poissonFamily struct{}
+
+func (fam poissonFamily) stat(y float64) float64 {
+	return xlogx(y)
+}
+
+func (fam poissonFamily) deviance(n float64, sum float64, statsum float64) float64 {
+	if n == 0.0 {
+		return 0.0
+	}
+	//sum(y)*log(mu) = n*mu*log(mu)
+	return 2.0 * (statsum - n*xlogx(sum/n))
+}
+
+//Impurity returns the mean Poisson deviance of the cases.
+func (target *PoissonTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	return devianceImpurity(target, poissonFamily{}, cases)
+}
+
+//SplitImpurity returns the total Poisson deviance of the split divided by the number of cases.
+func (target *PoissonTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	return devianceSplitImpurity(target, poissonFamily{}, l, r, m, allocs)
+}
+
+//UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l as in learning from numerical variables.
+//It moves the sums stored in allocs and recalculates the deviance from them.
+func (target *PoissonTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	return devianceUpdateSImp(target, poissonFamily{}, l, r, m, allocs, movedRtoL)
+}
+
+/*
+GammaTarget wraps a numerical feature of positive values (ie claim severities) as a
+target for Gamma regression. Impurity is the mean Gamma deviance vs the mean:
+
+	2/n * sum(-log(y/mu) + (y-mu)/mu) = 2/n * (n*log(mu) - sum(log(y)))
+
+and nodes predict the mean. Cases are expected to have non missing targets.
+*/
+type GammaTarget struct {
+	NumFeature
+}
+
+type gammaFamily struct{}
+
+func (fam gammaFamily) stat(y float64) float64 {
+	return math.Log(y)
+}
+
+func (fam gammaFamily) deviance(n float64, sum float64, statsum float64) float64 {
+	if n == 0.0 {
+		return 0.0
+	}
+	return 2.0 * (n*math.Log(sum/n) - statsum)
+}
+
+//Impurity returns the mean Gamma deviance of the cases.
+func (target *GammaTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	return devianceImpurity(target, gammaFamily{}, cases)
+}
+
+//SplitImpurity returns the total Gamma deviance of the split divided by the number of cases.
+func (target *GammaTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	return devianceSplitImpurity(target, gammaFamily{}, l, r, m, allocs)
+}
+
+//UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l as in learning from numerical variables.
+//It moves the sums stored in allocs and recalculates the deviance from them.
+func (target *GammaTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	return devianceUpdateSImp(target, gammaFamily{}, l, r, m, allocs, movedRtoL)
+}
+
+/*
+TweedieTarget wraps a numerical feature of non negative values (ie insurance losses,
+which are often exactly zero) as a target for Tweedie regression with a power between 1
+(Poisson) and 2 (Gamma). Impurity is the mean Tweedie deviance vs the mean:
+
+	2/n * sum(y^(2-p)/((1-p)(2-p)) - y*mu^(1-p)/(1-p) + mu^(2-p)/(2-p))
+	= 2/(n*(1-p)(2-p)) * (sum(y^(2-p)) - n*mu^(2-p))
+
+and nodes predict the mean. Cases are expected to have non missing targets.
+*/
+type TweedieTarget struct {
+	NumFeature
+	Power float64
+}
+
+type tweedieFamily struct {
+	power float64
+}
+
+func (fam tweedieFamily) stat(y float64) float64 {
+	return math.Pow(y, 2.0-fam.power)
+}
+
+func (fam tweedieFamily) deviance(n float64, sum float64, statsum float64) float64 {
+	if n == 0.0 {
+		return 0.0
+	}
+	p := fam.power
+	return 2.0 * (statsum - n*math.Pow(sum/n, 2.0-p)) / ((1.0 - p) * (2.0 - p))
+}
+
+//Impurity returns the mean Tweedie deviance of the cases.
+func (target *TweedieTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	return devianceImpurity(target, tweedieFamily{target.Power}, cases)
+}
+
+//SplitImpurity returns the total Tweedie deviance of the split divided by the number of cases.
+func (target *TweedieTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	return devianceSplitImpurity(target, tweedieFamily{target.Power}, l, r, m, allocs)
+}
+
+//UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l as in learning from numerical variables.
+//It moves the sums stored in allocs and recalculates the deviance from them.
+func (target *TweedieTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	return devianceUpdateSImp(target, tweedieFamily{target.Power}, l, r, m, allocs, movedRtoL)
+}
+
+/*
+CheckDevianceTarget returns the first non missing case whose value is outside the
+support of the target's family (negative values for PoissonTarget and TweedieTarget and
+non positive values for GammaTarget) or -1 if all are valid.
+*/
+func CheckDevianceTarget(target NumFeature) int {
+	_, positive := target.(*GammaTarget)
+	switch target.(type) {
+	case *PoissonTarget, *TweedieTarget, *GammaTarget:
+	default:
+		return -1
+	}
+	for i := 0; i < target.Length(); i++ {
+		if target.IsMissing(i) {
+			continue
+		}
+		if y := target.Get(i); y < 0.0 || (positive && y == 0.0) {
+			return i
+		}
+	}
+	return -1
+}
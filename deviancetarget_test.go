@@ -0,0 +1,133 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDevianceTargets(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 40
+	values := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		//counts with some zeros for poisson and tweedie
+		values = append(values, fmt.Sprintf("%v", rng.Intn(6)))
+	}
+	positive := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		positive = append(positive, fmt.Sprintf("%v", 0.5+rng.ExpFloat64()))
+	}
+	fm := parseRows([]string{"N:Counts", "N:Positive"}, [][]string{values, positive})
+	counts := fm.Data[0].(NumFeature)
+	pos := fm.Data[1].(NumFeature)
+
+	//unit deviances to check the closed forms against
+	poisson := func(y, mu float64) float64 { return 2.0 * (xlogx(y) - y*math.Log(mu) - (y - mu)) }
+	gamma := func(y, mu float64) float64 { return 2.0 * (-math.Log(y/mu) + (y-mu)/mu) }
+	tweedie := func(y, mu float64) float64 {
+		p := 1.5
+		return 2.0 * (math.Pow(y, 2.0-p)/((1.0-p)*(2.0-p)) - y*math.Pow(mu, 1.0-p)/(1.0-p) + math.Pow(mu, 2.0-p)/(2.0-p))
+	}
+
+	targets := []struct {
+		target Target
+		f      NumFeature
+		unit   func(y, mu float64) float64
+	}{
+		{&PoissonTarget{counts}, counts, poisson},
+		{&GammaTarget{pos}, pos, gamma},
+		{&TweedieTarget{counts, 1.5}, counts, tweedie},
+	}
+
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+	for _, tc := range targets {
+		mu := tc.f.Mean(&cases)
+		expected := 0.0
+		for _, i := range cases {
+			expected += tc.unit(tc.f.Get(i), mu)
+		}
+		expected /= float64(n)
+		if imp := tc.target.Impurity(&cases, nil); math.Abs(imp-expected) > 1e-9 {
+			t.Errorf("%T impurity %v not %v.", tc.target, imp, expected)
+		}
+
+		//incremental updates should match recalculation
+		allocs := NewBestSplitAllocs(n, tc.target)
+		l, r := cases[:1], cases[1:]
+		tc.target.SplitImpurity(&l, &r, nil, allocs)
+		for k := 2; k < n-1; k++ {
+			l, r = cases[:k], cases[k:]
+			moved := cases[k-1 : k]
+			updated := tc.target.UpdateSImpFromAllocs(&l, &r, nil, allocs, &moved)
+			full := tc.target.SplitImpurity(&l, &r, nil, NewBestSplitAllocs(n, tc.target))
+			if math.Abs(updated-full) > 1e-9 {
+				t.Errorf("%T updated split impurity %v not %v.", tc.target, updated, full)
+				break
+			}
+		}
+	}
+
+	counts.Put(0, 1.0)
+	counts.Put(1, 1.0)
+	counts.Put(2, 0.0)
+	if i := CheckDevianceTarget(&GammaTarget{counts}); i != 2 {
+		t.Errorf("Zero value for gamma target found in case %v not 2.", i)
+	}
+	if i := CheckDevianceTarget(&PoissonTarget{counts}); i != -1 {
+		t.Errorf("Valid counts found invalid in case %v.", i)
+	}
+	counts.Put(3, -1.0)
+	if i := CheckDevianceTarget(&PoissonTarget{counts}); i != 3 {
+		t.Errorf("Negative count in case %v not 3.", i)
+	}
+}
+
+func TestPoissonForest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	//counts with a rate that depends on the first feature
+	n := 300
+	x := make([]string, 0, n)
+	noise := make([]string, 0, n)
+	y := make([]string, 0, n)
+	rates := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		v := rng.Float64()
+		rate := math.Exp(3.0 * v)
+		//poisson sample by inversion
+		k, p, u := 0, math.Exp(-rate), rng.Float64()
+		for cdf := p; u > cdf; cdf += p {
+			k++
+			p *= rate / float64(k)
+		}
+		x = append(x, fmt.Sprintf("%v", v))
+		noise = append(noise, fmt.Sprintf("%v", rng.Float64()))
+		y = append(y, fmt.Sprintf("%v", k))
+		rates = append(rates, rate)
+	}
+	fm := parseRows([]string{"N:Y", "N:X", "N:Noise"}, [][]string{y, x, noise})
+
+	target := &PoissonTarget{fm.Data[0].(NumFeature)}
+	importance := NewRunningMeans(len(fm.Data))
+	forest := GrowRandomForest(fm, target, []int{1, 2}, n, 1, 30, 10, false, false, false, false, importance)
+	bb := NewNumBallotBox(n)
+	for _, tree := range forest.Trees {
+		tree.Vote(fm, bb)
+	}
+	e := 0.0
+	for i, rate := range rates {
+		e += math.Abs(bb.TallyNum(i)-rate) / rate
+	}
+	if e /= float64(n); e > 0.35 {
+		t.Errorf("Poisson forest mean relative error vs the true rate is %v.", e)
+	}
+	xImp, _ := (*importance)[1].Read()
+	noiseImp, _ := (*importance)[2].Read()
+	if !(xImp > noiseImp) {
+		t.Errorf("Importance of X %v not greater then noise's %v.", xImp, noiseImp)
+	}
+}
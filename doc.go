@@ -6,6 +6,8 @@ numerical/categorical data with missing values. These include:
 
 	* Breiman and Cutler's Random Forest for Classification and Regression
 
+	* Extremely Randomized Trees (ExtraTrees)
+
 	* Adaptive Boosting (AdaBoost) Classification
 
 	* Gradiant Boosting Tree Regression
@@ -89,9 +91,13 @@ includes several alternative targets:
  RegretTarget  : For use in classification driven by differing costs in mis-categorization.
  L1Target      : For use in L1 norm error regression (which may be less sensitive to outliers).
  OrdinalTarget : For ordinal regression
+ HuberTarget   : For robust regression with the Huber loss.
+ QuantileTarget: For quantile regression with the quantile (pinball) loss.
 
 Additional targets can be stacked on top of these target to add boosting functionality:
  GradBoostTarget : For Gradient Boosting Regression
+ NewtonBoostTarget : For second order (Newton) Gradient Boosting Regression with regularized leaves
+ GradBoostClassTarget : For Gradient Boosting Classification with the logistic or softmax loss
  AdaBoostTarget  : For Adaptive Boosting Classification
 
 
@@ -136,6 +142,34 @@ BestCatSplitBig and BestCatSplitIterBig.
 All numerical predictors are handled by BestNumSplit which
 relies on go's sorting package.
 
+For large data sets numerical predictors can be quantized into at most 255 bins with
+FeatureMatrix.BinNumFeatures. BinnedNumFeature.BestSplit then sums the statistics of a
+HistogramTarget for the cases in each bin instead of sorting them and finds the histograms
+of right children by subtracting their left siblings' histograms from their parents'.
+
+Alternatively numerical predictors can be sorted once per FeatureMatrix with NewPresortedIndex.
+If a PresortedCases is set as BestSplitAllocs.Presort, Tree.Grow keeps each feature's sorted cases
+partitioned as it descends and BestSplit searches them directly with BestSortedSplit.
+
+If BestSplitAllocs.ExtraTrees is set BestSplit doesn't search at all but draws a single random
+threshold (BestRandomNumSplit) or subset of categories (BestRandomCatSplit) as in extremely
+randomized trees.
+
+Oblique splits on random linear combinations of numerical features (as in Forest-RC) can be
+searched at each node by setting BestSplitAllocs.Oblique to an ObliqueSplits. The resulting
+Splitters have Features and Weights and are applied by Splitter.Split like other splitters.
+
+If BestSplitAllocs.Multiway is set DenseCatFeature.BestSplit tries a C4.5 style split of
+features with few categories into one branch per category (BestMultiwaySplit) scored by gain
+ratio. Such Nodes have Branches instead of Left and Right and Splitters with Levels which are
+applied with Splitter.SplitMultiway. Categories not in Levels go down the Fallback branch.
+
+Predictions can be constrained to be monotone in numerical features by setting
+BestSplitAllocs.Monotone to MonotoneConstraints. The BestSplit methods of DenseNumFeature and
+BinnedNumFeature reject splits on constrained features whose children's mean target values
+are out of order and Tree.Grow propagates the resulting Bounds down the tree and clips
+predictions to them.
+
 Parallelism and Scaling
 
 Training a Random forest is an inherently parallel process and CloudForest is designed
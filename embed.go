@@ -0,0 +1,619 @@
+package CloudForest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Dense returns the proximities as a symmetric dense matrix, (P+P')/2, with ones on the
+diagonal for use in embedding and clustering. It requires that the proximity rows
+are the same cases as the columns (as when it is calculated with subset nil).
+*/
+func (p *SparseProximity) Dense() (dense [][]float64, err error) {
+	if len(p.Rows) != p.NCols {
+		return nil, errors.New("Dense proximities require a row for every column case.")
+	}
+	dense = make([][]float64, p.NCols)
+	for i := range dense {
+		dense[i] = make([]float64, p.NCols)
+	}
+	for k, i := range p.Rows {
+		cols, vals := p.Row(k)
+		for n, j := range cols {
+			dense[i][j] += vals[n] / 2.0
+			dense[j][i] += vals[n] / 2.0
+		}
+	}
+	for i := range dense {
+		dense[i][i] = 1.0
+	}
+	return
+}
+
+/*
+ReadProximityPairs reads a sparse tsv of case pairs and proximities (as written by the
+proximity utility or growforest -unsupervised) into a symmetric dense matrix. Cases are
+indexed in order of first appearance and their labels returned. If any value is greater
+then 1 (as in leafcount's raw co-occurrence counts) all values are divided by the
+maximum.
+*/
+func ReadProximityPairs(r io.Reader) (labels []string, dense [][]float64, err error) {
+	index := make(map[string]int)
+	type entry struct {
+		i, j int
+		v    float64
+	}
+	entries := make([]entry, 0)
+	max := 0.0
+	getIndex := func(label string) int {
+		i, ok := index[label]
+		if !ok {
+			i = len(labels)
+			index[label] = i
+			labels = append(labels, label)
+		}
+		return i
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		v, e := strconv.ParseFloat(fields[2], 64)
+		if e != nil {
+			return nil, nil, e
+		}
+		entries = append(entries, entry{getIndex(fields[0]), getIndex(fields[1]), v})
+		max = math.Max(max, v)
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	scale := 1.0
+	if max > 1.0 {
+		scale = 1.0 / max
+	}
+	dense = make([][]float64, len(labels))
+	for i := range dense {
+		dense[i] = make([]float64, len(labels))
+	}
+	for _, e := range entries {
+		dense[e.i][e.j] = math.Max(dense[e.i][e.j], e.v*scale)
+		dense[e.j][e.i] = dense[e.i][e.j]
+	}
+	for i := range dense {
+		dense[i][i] = 1.0
+	}
+	return
+}
+
+/*
+ClassicalMDS embeds cases in k dimensions by classical (Torgerson) multidimensional
+scaling of the distances 1-proximity as in the MDSplot function of R's randomForest.
+The double centered matrix of squared distances is eigendecomposed and the coordinates
+are the top k eigenvectors scaled by the square roots of their eigenvalues. It returns
+coordinates indexed [case][dimension] and the eigenvalues or an error unless 0 < k <= the
+number of cases.
+*/
+func ClassicalMDS(prox [][]float64, k int) (coords [][]float64, eigenvalues []float64, err error) {
+	n := len(prox)
+	if k <= 0 || k > n {
+		return nil, nil, fmt.Errorf("Can't embed %v cases in %v dimensions.", n, k)
+	}
+	b := make([][]float64, n)
+	rowMeans := make([]float64, n)
+	grandMean := 0.0
+	for i := range b {
+		b[i] = make([]float64, n)
+		for j := range b[i] {
+			d := 1.0 - prox[i][j]
+			b[i][j] = d * d
+			rowMeans[i] += b[i][j]
+		}
+		rowMeans[i] /= float64(n)
+		grandMean += rowMeans[i]
+	}
+	grandMean /= float64(n)
+	for i := range b {
+		for j := range b[i] {
+			b[i][j] = -0.5 * (b[i][j] - rowMeans[i] - rowMeans[j] + grandMean)
+		}
+	}
+
+	eigenvalues, vectors := TopEigen(b, k)
+	coords = make([][]float64, n)
+	for i := range coords {
+		coords[i] = make([]float64, k)
+		for d := 0; d < k; d++ {
+			coords[i][d] = vectors[d][i] * math.Sqrt(math.Max(eigenvalues[d], 0.0))
+		}
+	}
+	return
+}
+
+/*
+SpectralEmbedding embeds cases in k dimensions using the proximities as an affinity
+matrix W. The top k+1 eigenvectors of the normalized affinity D^-1/2 W D^-1/2 (where D is
+the diagonal matrix of row sums) are found and, skipping the first (trivial) one,
+rescaled by D^-1/2 to give the coordinates of the random walk Laplacian eigenmap. It
+returns an error unless 0 < k < the number of cases.
+*/
+func SpectralEmbedding(prox [][]float64, k int) (coords [][]float64, err error) {
+	n := len(prox)
+	if k <= 0 || k >= n {
+		return nil, fmt.Errorf("Can't spectrally embed %v cases in %v dimensions.", n, k)
+	}
+	invsqrt := make([]float64, n)
+	for i := range prox {
+		deg := 0.0
+		for _, v := range prox[i] {
+			deg += v
+		}
+		if deg > 0.0 {
+			invsqrt[i] = 1.0 / math.Sqrt(deg)
+		}
+	}
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n)
+		for j := range a[i] {
+			a[i][j] = invsqrt[i] * prox[i][j] * invsqrt[j]
+		}
+	}
+
+	_, vectors := TopEigen(a, k+1)
+	coords = make([][]float64, n)
+	for i := range coords {
+		coords[i] = make([]float64, k)
+		for d := 0; d < k; d++ {
+			coords[i][d] = vectors[d+1][i] * invsqrt[i]
+		}
+	}
+	return
+}
+
+/*
+TopEigen finds the k algebraically largest eigenvalues and corresponding eigenvectors
+of the symmetric matrix a using subspace (orthogonal) iteration with Rayleigh-Ritz
+projection. The matrix is shifted by its Gershgorin bound so all eigenvalues are non
+negative while iterating. Eigenvectors are returned indexed [eigenvector][case] in order
+of decreasing eigenvalue. k is clamped to between 0 and the size of a so fewer than k
+may be returned.
+*/
+func TopEigen(a [][]float64, k int) (values []float64, vectors [][]float64) {
+	n := len(a)
+	if k > n {
+		k = n
+	}
+	if k < 0 {
+		k = 0
+	}
+	shift := 0.0
+	for i := range a {
+		sum := 0.0
+		for _, v := range a[i] {
+			sum += math.Abs(v)
+		}
+		shift = math.Max(shift, sum)
+	}
+
+	q := make([][]float64, k)
+	for d := range q {
+		q[d] = make([]float64, n)
+		for i := range q[d] {
+			q[d][i] = rand.NormFloat64()
+		}
+	}
+	orthonormalize(q)
+
+	z := make([][]float64, k)
+	for d := range z {
+		z[d] = make([]float64, n)
+	}
+	values = make([]float64, k)
+	for iter := 0; iter < 1000; iter++ {
+		for d := range q {
+			multiply(a, q[d], z[d])
+			for i := range z[d] {
+				z[d][i] += shift * q[d][i]
+			}
+		}
+		q, z = z, q
+		orthonormalize(q)
+
+		//Rayleigh-Ritz: rotate q to the eigenvectors of the projected matrix
+		t := make([][]float64, k)
+		for d := range q {
+			multiply(a, q[d], z[d])
+		}
+		for d := range t {
+			t[d] = make([]float64, k)
+			for e := range t[d] {
+				t[d][e] = dot(q[d], z[e])
+			}
+		}
+		tvals, tvecs := jacobiEigen(t)
+		rotated := make([][]float64, k)
+		for d := range rotated {
+			rotated[d] = make([]float64, n)
+			for e := 0; e < k; e++ {
+				for i := range rotated[d] {
+					rotated[d][i] += tvecs[d][e] * q[e][i]
+				}
+			}
+		}
+		q = rotated
+
+		change := 0.0
+		for d := range values {
+			change = math.Max(change, math.Abs(tvals[d]-values[d]))
+		}
+		copy(values, tvals)
+		if change < 1e-10*math.Max(shift, 1.0) && iter > 1 {
+			break
+		}
+	}
+	vectors = q
+	return
+}
+
+//multiply sets out to the product of the matrix a and the vector v.
+func multiply(a [][]float64, v []float64, out []float64) {
+	for i := range a {
+		out[i] = dot(a[i], v)
+	}
+}
+
+func dot(a []float64, b []float64) (sum float64) {
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return
+}
+
+//orthonormalize performs modified Gram-Schmidt orthonormalization of the vectors in place.
+func orthonormalize(vectors [][]float64) {
+	for d := range vectors {
+		for e := 0; e < d; e++ {
+			proj := dot(vectors[d], vectors[e])
+			for i := range vectors[d] {
+				vectors[d][i] -= proj * vectors[e][i]
+			}
+		}
+		norm := math.Sqrt(dot(vectors[d], vectors[d]))
+		if norm == 0.0 {
+			vectors[d][rand.Intn(len(vectors[d]))] = 1.0
+			norm = 1.0
+		}
+		for i := range vectors[d] {
+			vectors[d][i] /= norm
+		}
+	}
+}
+
+/*
+jacobiEigen diagonalizes a small symmetric matrix with cyclic Jacobi rotations. It
+returns the eigenvalues in decreasing order and the eigenvectors indexed
+[eigenvector][component].
+*/
+func jacobiEigen(t [][]float64) (values []float64, vectors [][]float64) {
+	k := len(t)
+	a := make([][]float64, k)
+	v := make([][]float64, k)
+	for i := range a {
+		a[i] = make([]float64, k)
+		copy(a[i], t[i])
+		v[i] = make([]float64, k)
+		v[i][i] = 1.0
+	}
+	for sweep := 0; sweep < 100; sweep++ {
+		off := 0.0
+		for p := 0; p < k; p++ {
+			for q := p + 1; q < k; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < k; p++ {
+			for q := p + 1; q < k; q++ {
+				if a[p][q] == 0.0 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2.0 * a[p][q])
+				tn := 1.0 / (math.Abs(theta) + math.Sqrt(theta*theta+1.0))
+				if theta < 0.0 {
+					tn = -tn
+				}
+				c := 1.0 / math.Sqrt(tn*tn+1.0)
+				s := tn * c
+				for r := 0; r < k; r++ {
+					arp, arq := a[r][p], a[r][q]
+					a[r][p] = c*arp - s*arq
+					a[r][q] = s*arp + c*arq
+				}
+				for r := 0; r < k; r++ {
+					apr, aqr := a[p][r], a[q][r]
+					a[p][r] = c*apr - s*aqr
+					a[q][r] = s*apr + c*aqr
+				}
+				for r := 0; r < k; r++ {
+					vrp, vrq := v[r][p], v[r][q]
+					v[r][p] = c*vrp - s*vrq
+					v[r][q] = s*vrp + c*vrq
+				}
+			}
+		}
+	}
+
+	order := make([]int, k)
+	diag := make([]float64, k)
+	for i := range order {
+		order[i] = i
+		diag[i] = a[i][i]
+	}
+	sort.Sort(&byScore{order, diag})
+	values = make([]float64, k)
+	vectors = make([][]float64, k)
+	for d, i := range order {
+		values[d] = diag[i]
+		vectors[d] = make([]float64, k)
+		for r := 0; r < k; r++ {
+			vectors[d][r] = v[r][i]
+		}
+	}
+	return
+}
+
+//ProximityDistances converts proximities to the distances 1-proximity.
+func ProximityDistances(prox [][]float64) (dist [][]float64) {
+	dist = make([][]float64, len(prox))
+	for i := range prox {
+		dist[i] = make([]float64, len(prox[i]))
+		for j, v := range prox[i] {
+			dist[i][j] = 1.0 - v
+		}
+	}
+	return
+}
+
+/*
+AverageLinkage performs agglomerative hierarchical clustering with average linkage
+(UPGMA) on the distance matrix using the nearest neighbor chain algorithm and cuts the
+resulting tree into k clusters. Cluster labels are numbered from 0 in order of the
+first case in each cluster. It returns an error unless 0 < k <= the number of cases.
+*/
+func AverageLinkage(dist [][]float64, k int) (labels []int, err error) {
+	n := len(dist)
+	if k <= 0 || k > n {
+		return nil, fmt.Errorf("Can't find %v clusters in %v cases.", k, n)
+	}
+	d := make([][]float64, n)
+	for i := range d {
+		d[i] = make([]float64, n)
+		copy(d[i], dist[i])
+	}
+	size := make([]int, n)
+	active := make([]bool, n)
+	for i := range size {
+		size[i] = 1
+		active[i] = true
+	}
+
+	type merge struct {
+		a, b   int
+		height float64
+	}
+	merges := make([]merge, 0, n-1)
+	chain := make([]int, 0, n)
+	for len(merges) < n-1 {
+		if len(chain) == 0 {
+			for i := range active {
+				if active[i] {
+					chain = append(chain, i)
+					break
+				}
+			}
+		}
+		a := chain[len(chain)-1]
+		prev := -1
+		if len(chain) > 1 {
+			prev = chain[len(chain)-2]
+		}
+		//nearest active neighbor of a, preferring the previous chain element on ties
+		b := prev
+		best := math.Inf(1)
+		if prev >= 0 {
+			best = d[a][prev]
+		}
+		for j := range active {
+			if active[j] && j != a && d[a][j] < best {
+				best = d[a][j]
+				b = j
+			}
+		}
+		if b != prev {
+			chain = append(chain, b)
+			continue
+		}
+		//a and b are reciprocal nearest neighbors; merge b into a
+		chain = chain[:len(chain)-2]
+		merges = append(merges, merge{a, b, best})
+		for j := range active {
+			if active[j] && j != a && j != b {
+				d[a][j] = (float64(size[a])*d[a][j] + float64(size[b])*d[b][j]) / float64(size[a]+size[b])
+				d[j][a] = d[a][j]
+			}
+		}
+		size[a] += size[b]
+		active[b] = false
+	}
+
+	sort.SliceStable(merges, func(i, j int) bool { return merges[i].height < merges[j].height })
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, m := range merges[:n-k] {
+		parent[find(m.b)] = find(m.a)
+	}
+	return relabel(n, find), nil
+}
+
+//relabel numbers clusters from 0 in order of first appearance.
+func relabel(n int, cluster func(i int) int) (labels []int) {
+	labels = make([]int, n)
+	ids := make(map[int]int)
+	for i := range labels {
+		c := cluster(i)
+		id, ok := ids[c]
+		if !ok {
+			id = len(ids)
+			ids[c] = id
+		}
+		labels[i] = id
+	}
+	return
+}
+
+/*
+PAM performs k-medoids clustering of the distance matrix with Kaufman and Rousseeuw's
+partitioning around medoids: a greedy BUILD phase chooses initial medoids and the SWAP
+phase repeatedly makes the medoid/non-medoid swap that most reduces the total distance
+of cases to their nearest medoid until no swap helps. It returns cluster labels (the
+index into medoids of each case's nearest medoid) and the medoids or an error unless
+0 < k <= the number of cases.
+*/
+func PAM(dist [][]float64, k int) (labels []int, medoids []int, err error) {
+	n := len(dist)
+	if k <= 0 || k > n {
+		return nil, nil, fmt.Errorf("Can't find %v clusters in %v cases.", k, n)
+	}
+	isMedoid := make([]bool, n)
+	nearest := make([]float64, n)
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+
+	//BUILD
+	for len(medoids) < k {
+		best, bestCost := -1, math.Inf(1)
+		for c := 0; c < n; c++ {
+			if isMedoid[c] {
+				continue
+			}
+			cost := 0.0
+			for i := 0; i < n; i++ {
+				cost += math.Min(nearest[i], dist[i][c])
+			}
+			if cost < bestCost {
+				best, bestCost = c, cost
+			}
+		}
+		medoids = append(medoids, best)
+		isMedoid[best] = true
+		for i := range nearest {
+			nearest[i] = math.Min(nearest[i], dist[i][best])
+		}
+	}
+
+	total := func(meds []int) (cost float64) {
+		for i := 0; i < n; i++ {
+			min := math.Inf(1)
+			for _, m := range meds {
+				min = math.Min(min, dist[i][m])
+			}
+			cost += min
+		}
+		return
+	}
+
+	//SWAP
+	cost := total(medoids)
+	for {
+		bestCost, bestM, bestC := cost, -1, -1
+		trial := make([]int, k)
+		for m := range medoids {
+			for c := 0; c < n; c++ {
+				if isMedoid[c] {
+					continue
+				}
+				copy(trial, medoids)
+				trial[m] = c
+				if tc := total(trial); tc < bestCost-1e-12 {
+					bestCost, bestM, bestC = tc, m, c
+				}
+			}
+		}
+		if bestM < 0 {
+			break
+		}
+		isMedoid[medoids[bestM]] = false
+		isMedoid[bestC] = true
+		medoids[bestM] = bestC
+		cost = bestCost
+	}
+
+	labels = make([]int, n)
+	for i := range labels {
+		for m := range medoids {
+			if dist[i][medoids[m]] < dist[i][medoids[labels[i]]] {
+				labels[i] = m
+			}
+		}
+	}
+	return
+}
+
+/*
+WriteEmbedding writes per case coordinates and cluster labels as a tsv:
+
+	Case	Dim1	Dim2	...	Cluster
+
+The Cluster column is omitted if clusters is nil.
+*/
+func WriteEmbedding(w io.Writer, labels []string, coords [][]float64, clusters []int) (err error) {
+	bw := bufio.NewWriter(w)
+	header := []string{"Case"}
+	if len(coords) > 0 {
+		for d := range coords[0] {
+			header = append(header, "Dim"+strconv.Itoa(d+1))
+		}
+	}
+	if clusters != nil {
+		header = append(header, "Cluster")
+	}
+	if _, err = bw.WriteString(strings.Join(header, "\t") + "\n"); err != nil {
+		return
+	}
+	for i, label := range labels {
+		row := []string{label}
+		for _, v := range coords[i] {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		if clusters != nil {
+			row = append(row, strconv.Itoa(clusters[i]))
+		}
+		if _, err = bw.WriteString(strings.Join(row, "\t") + "\n"); err != nil {
+			return
+		}
+	}
+	return bw.Flush()
+}
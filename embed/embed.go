@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ryanbressler/CloudForest"
+	"log"
+	"os"
+	"runtime"
+)
+
+func main() {
+	fm := flag.String("fm",
+		"featurematrix.afm", "AFM formated feature matrix containing the training data.")
+	rf := flag.String("rfpred",
+		"rface.sf", "A predictor forest.")
+	inbag := flag.String("inbag",
+		"", "In bag counts written by growforest -inbag. If provided only out of bag pairs are counted.")
+	proxf := flag.String("prox",
+		"", "A sparse tsv of case pairs and proximities (from proximity, leafcount or growforest -unsupervised) to use instead of -fm and -rfpred.")
+	outf := flag.String("out",
+		"embedding.tsv", "The name of a file to write the coordinates and clusters into.")
+	method := flag.String("method",
+		"mds", "Embedding method: mds (classical multidimensional scaling) or spectral.")
+	cluster := flag.String("cluster",
+		"hierarchical", "Clustering method: hierarchical (average linkage), pam or none.")
+	var dims int
+	flag.IntVar(&dims, "dims", 2, "The number of dimensions to embed in.")
+	var k int
+	flag.IntVar(&k, "k", 3, "The number of clusters.")
+	var nCores int
+	flag.IntVar(&nCores, "nCores", 1, "The number of cores to use.")
+
+	flag.Parse()
+
+	runtime.GOMAXPROCS(nCores)
+
+	var labels []string
+	var prox [][]float64
+	if *proxf != "" {
+		proxfile, err := os.Open(*proxf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer proxfile.Close()
+		labels, prox, err = CloudForest.ReadProximityPairs(proxfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		data, err := CloudForest.LoadAFM(*fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		forestfile, err := os.Open(*rf) // For read access.
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer forestfile.Close()
+		forestreader := CloudForest.NewForestReader(forestfile)
+		forest, err := forestreader.ReadForest()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var bags [][]bool
+		if *inbag != "" {
+			bagfile, err := os.Open(*inbag)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer bagfile.Close()
+			bags, err = CloudForest.ReadInBag(bagfile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(bags) != len(forest.Trees) {
+				log.Fatalf("In bag file has %v trees, forest has %v.", len(bags), len(forest.Trees))
+			}
+		}
+
+		leaves := CloudForest.NewLeafIndex(forest, data)
+		sparse := CloudForest.Proximity(leaves, leaves, bags, bags, nil, nCores)
+		prox, err = sparse.Dense()
+		if err != nil {
+			log.Fatal(err)
+		}
+		labels = data.CaseLabels
+	}
+	fmt.Printf("Embedding %v cases.\n", len(labels))
+
+	var err error
+	var coords [][]float64
+	switch *method {
+	case "mds":
+		var eigenvalues []float64
+		coords, eigenvalues, err = CloudForest.ClassicalMDS(prox, dims)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("MDS eigenvalues: %v\n", eigenvalues)
+	case "spectral":
+		coords, err = CloudForest.SpectralEmbedding(prox, dims)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unknown embedding method %v.", *method)
+	}
+
+	var clusters []int
+	switch *cluster {
+	case "hierarchical":
+		clusters, err = CloudForest.AverageLinkage(CloudForest.ProximityDistances(prox), k)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "pam":
+		var medoids []int
+		clusters, medoids, err = CloudForest.PAM(CloudForest.ProximityDistances(prox), k)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for c, m := range medoids {
+			fmt.Printf("Cluster %v medoid: %v\n", c, labels[m])
+		}
+	case "none":
+	default:
+		log.Fatalf("Unknown clustering method %v.", *cluster)
+	}
+
+	fmt.Printf("Outputting embedding to %v\n", *outf)
+	outfile, err := os.Create(*outf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer outfile.Close()
+	if err = CloudForest.WriteEmbedding(outfile, labels, coords, clusters); err != nil {
+		log.Fatal(err)
+	}
+}
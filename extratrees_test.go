@@ -0,0 +1,88 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+func TestExtraTrees(t *testing.T) {
+	n := 1000
+	fm := binningTestData(n)
+	target := fm.Data[0].(Target)
+	z := fm.Data[3].(*DenseNumFeature)
+
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+	allocs := NewBestSplitAllocs(n, target)
+	parentImp := target.Impurity(&cases, allocs.Counter)
+	_, bestImp, _ := z.BestSplit(target, &cases, parentImp, 1, allocs)
+
+	allocs.ExtraTrees = true
+	splits := make(map[float64]bool)
+	for i := 0; i < 20; i++ {
+		split, imp, constant := z.BestSplit(target, &cases, parentImp, 1, allocs)
+		s := split.(float64)
+		if constant || s < 0.0 || s > 1.0 {
+			t.Fatalf("Random split %v (constant %v) isn't between the feature's values.", s, constant)
+		}
+		if imp > bestImp+1e-9 {
+			t.Errorf("Random split decrease %v is better than the best %v.", imp, bestImp)
+		}
+		splits[s] = true
+	}
+	if len(splits) < 15 {
+		t.Errorf("Only %v distinct random splits drawn.", len(splits))
+	}
+
+	//random category subsets should send some of the present categories each way
+	for _, ncats := range []int{10, 40} {
+		f := &DenseCatFeature{
+			&CatMap{make(map[string]int, 0),
+				make([]string, 0, 0)},
+			make([]int, 0, 0),
+			make([]bool, 0, 0),
+			"cats",
+			false,
+			false}
+		for i := 0; i < n; i++ {
+			f.Append(fmt.Sprintf("%v", i%ncats))
+		}
+		//only even categories are present
+		even := make([]int, 0, n)
+		for _, i := range cases {
+			if f.CatData[i]%2 == 0 {
+				even = append(even, i)
+			}
+		}
+		for i := 0; i < 20; i++ {
+			split, _, constant := f.BestSplit(target, &even, parentImp, 1, allocs)
+			if constant {
+				t.Fatalf("%v categories found constant.", ncats)
+			}
+			if ncats > maxNonBigCats {
+				if _, ok := split.(*big.Int); !ok {
+					t.Fatalf("Split of %v categories is %T not *big.Int.", ncats, split)
+				}
+			}
+			l, r, m := f.Split(split, even)
+			if len(l) == 0 || len(r) == 0 || len(m) != 0 {
+				t.Fatalf("Random split of %v categories gave left, right, missing %v %v %v.", ncats, len(l), len(r), len(m))
+			}
+		}
+	}
+
+	//a forest of fully grown extra trees should fit the training data about as well as a
+	//fully grown tree (cases missing X aren't predicted by either)
+	bb := NewNumBallotBox(n)
+	for i := 0; i < 10; i++ {
+		tree := NewTree()
+		tree.Grow(fm, target, cases, []int{1, 2, 3}, nil, 3, 1, false, false, false, false, nil, nil, allocs)
+		tree.Vote(fm, bb)
+	}
+	if r2 := bb.TallyR2Score(fm.Data[0]); r2 < 0.6 {
+		t.Errorf("Extra trees R2 on training data is %v.", r2)
+	}
+}
@@ -92,3 +92,34 @@ type BoostingTarget interface {
 	Target
 	Boost(partition *[][]int) (weight float64)
 }
+
+//TreeBoostingTarget is implemented by targets like GradBoostTarget that set the predictions
+//of a tree's leaves when they are boosted. BoostTree will be called after each tree is
+//grown with the in bag cases it was grown on and should return the weight the tree should
+//be given.
+type TreeBoostingTarget interface {
+	Target
+	BoostTree(tree *Tree, fm *FeatureMatrix, inbag []int) (weight float64)
+}
+
+/*
+HistogramTarget is implemented by targets whose impurities can be calculated from the sums
+of a fixed number of statistics of each case which allows binned split searching (see
+BinnedNumFeature) to accumulate the statistics of each bin and derive the statistics of
+a node's right child by subtracting its left sibling's from its parent's. The first
+statistic of each case must be 1 so stats[0] is the number of cases.
+*/
+type HistogramTarget interface {
+	Target
+	NHistStats() int
+	AddHistStats(i int, stats []float64)
+	HistImpurity(stats []float64) float64
+	HistSplitImpurity(l []float64, r []float64, m []float64) float64
+}
+
+//LossTarget is implemented by boosting targets that can report the mean loss of their
+//current predictions for a set of cases, which is used to track the improvement on out of
+//bag cases made by each boosting iteration.
+type LossTarget interface {
+	MeanLoss(cases []int) float64
+}
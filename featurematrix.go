@@ -80,6 +80,12 @@ leafSize specifies the minimum leafSize that can be be produced by the split.
 
 Vet specifies weather feature splits should be penalized with a randomized version of themselves.
 
+Multiway splits (see BestMultiwaySplit) are scored by gain ratio and compared directly with
+the impurity decreases of binary splits. This is on purpose. Split information is at most
+one bit for a binary split, and gain ratio divides by at least one bit. So a binary split's
+gain ratio is just its impurity decrease, and both scores are in units of impurity. Multiway
+splits are penalized only when their split information is more than one bit.
+
 allocs contains pointers to reusable structures for use while searching for the best split and should
 be initialized to the proper size with NewBestSplitAlocs.
 */
@@ -107,7 +113,7 @@ func (fm *FeatureMatrix) BestSplitter(target Target,
 		target.(Feature).CopyInTo(allocs.ContrastTarget.(Feature))
 	}
 
-	parentImp := target.Impurity(cases, allocs.Counter)
+	parentImp := allocs.Impurity(target, cases)
 	nConstants = nConstantsBefore
 	if parentImp <= minImp {
 		return
@@ -146,6 +152,7 @@ func (fm *FeatureMatrix) BestSplitter(target Target,
 		i := cans[lastSample]
 
 		f = fm.Data[i]
+		allocs.Monotone.Search(i)
 		split, inerImp, constant = f.BestSplit(target, cases, parentImp, leafSize, allocs)
 		if constant {
 			nConstants++
@@ -156,9 +163,14 @@ func (fm *FeatureMatrix) BestSplitter(target Target,
 		}
 
 		if evaloob && inerImp > impurityDecrease {
-			//spliter := f.DecodeSplit(split)
-			l, r, m := f.Split(split, *oob) //spliter.Split(fm, *oob)
-			inerImp = target.Impurity(oob, allocs.Counter) - target.SplitImpurity(&l, &r, &m, allocs)
+			if multiway, ok := split.(*MultiwaySplit); ok {
+				branches, m := f.(*DenseCatFeature).SplitMultiway(multiway, *oob)
+				inerImp = multiwayGainRatio(target, allocs.Impurity(target, oob), branches, m, allocs)
+			} else {
+				//spliter := f.DecodeSplit(split)
+				l, r, m := f.Split(split, *oob) //spliter.Split(fm, *oob)
+				inerImp = allocs.Impurity(target, oob) - target.SplitImpurity(&l, &r, &m, allocs)
+			}
 		}
 
 		if vet && inerImp > impurityDecrease {
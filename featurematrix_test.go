@@ -66,3 +66,29 @@ func TestBestSplitter(t *testing.T) {
 		}
 	}
 }
+
+func TestSyntheticContrast(t *testing.T) {
+	fm := ParseAFM(strings.NewReader(fm))
+	combined := fm.SyntheticContrast([]int{2, 3, 4})
+	if len(combined.Data) != 4 || combined.Data[0].Length() != 16 || len(combined.CaseLabels) != 16 {
+		t.Fatalf("Synthetic contrast has %v features and %v cases not 4 and 16.", len(combined.Data), combined.Data[0].Length())
+	}
+	target := combined.Data[combined.Map[UnsupervisedTarget]]
+	for i := 0; i < 16; i++ {
+		if (i < 8) != (target.GetStr(i) == "real") {
+			t.Errorf("Case %v is mislabeled %v.", i, target.GetStr(i))
+		}
+	}
+	observed := make(map[string]bool)
+	for i := 0; i < 8; i++ {
+		observed[fm.Data[4].GetStr(i)] = true
+		if combined.Data[2].GetStr(i) != fm.Data[4].GetStr(i) {
+			t.Errorf("Real case %v changed.", i)
+		}
+	}
+	for i := 8; i < 16; i++ {
+		if !observed[combined.Data[2].GetStr(i)] {
+			t.Errorf("Synthetic value %v not drawn from the real values.", combined.Data[2].GetStr(i))
+		}
+	}
+}
@@ -91,6 +91,13 @@ func (fr *ForestReader) ReadTree() (tree *Tree, forest *Forest, err error) {
 		case strings.HasPrefix(line, "FOREST"):
 			forest = new(Forest)
 			forest.Target = parsed["TARGET"]
+			forest.Type = parsed["FOREST"]
+			if intercept, ok := parsed["INTERCEPT"]; ok {
+				forest.Intercept, err = strconv.ParseFloat(intercept, 64)
+				if err != nil {
+					return
+				}
+			}
 
 		case strings.HasPrefix(line, "TREE"):
 			intree = true
@@ -138,6 +145,32 @@ func (fr *ForestReader) ReadTree() (tree *Tree, forest *Forest, err error) {
 						log.Print("Error parsing lvalues value ", err)
 					}
 					splitter.Value = float64(lvalue)
+
+				case "MULTIWAY":
+					splitter.Numerical = false
+					splitter.Levels = strings.Split(parsed["LVALUES"], ":")
+					if fallbacks, ok := parsed["FALLBACK"]; ok {
+						fallback, err := strconv.Atoi(fallbacks)
+						if err != nil {
+							log.Print("Error parsing fallback value ", err)
+						}
+						splitter.Fallback = fallback
+					}
+
+				case "OBLIQUE":
+					lvalue, err := strconv.ParseFloat(parsed["LVALUES"], 64)
+					if err != nil {
+						log.Print("Error parsing lvalues value ", err)
+					}
+					weights := make([]float64, 0)
+					for _, w := range strings.Split(parsed["WEIGHTS"], ",") {
+						weight, err := strconv.ParseFloat(w, 64)
+						if err != nil {
+							log.Print("Error parsing weights value ", err)
+						}
+						weights = append(weights, weight)
+					}
+					splitter = NewObliqueSplitter(strings.Split(parsed["SPLITTER"], ","), weights, lvalue)
 				}
 			}
 
@@ -22,14 +22,23 @@ func NewForestWriter(w io.Writer) *ForestWriter {
 	return &ForestWriter{w}
 }
 
-//WriteForest writes an entire forest including all headers.
+//WriteForest writes an entire forest including all headers. The forest header is only
+//written for forests with a Type (like gradient boosting forests) since ordinary forests
+//don't need one.
 func (fw *ForestWriter) WriteForest(forest *Forest) {
-	//fw.WriteForestHeader(forest.Target, len(forest.Trees))
+	if forest.Type != "" {
+		fw.WriteForestHeader(forest.Type, forest.Target, len(forest.Trees), forest.Intercept)
+	}
 	for i, tree := range forest.Trees {
 		fw.WriteTree(tree, i)
 	}
 }
 
+//WriteForestHeader writes only the header line for a forest of the specified type.
+func (fw *ForestWriter) WriteForestHeader(forestType string, target string, ntrees int, intercept float64) {
+	fmt.Fprintf(fw.w, "FOREST=%v,TARGET=\"%v\",NTREES=%v,INTERCEPT=%v\n", forestType, target, ntrees, intercept)
+}
+
 //WriteTree writes an entire Tree including the header.
 func (fw *ForestWriter) WriteTree(tree *Tree, ntree int) {
 	fw.WriteTreeHeader(ntree, tree.Target, tree.Weight)
@@ -56,8 +65,13 @@ func (fw *ForestWriter) WriteNodeAndChildren(n *Node, path string) {
 	if n.Splitter != nil && n.Right != nil {
 		fw.WriteNodeAndChildren(n.Right, path+"R")
 	}
+	if n.Splitter != nil {
+		for i, b := range n.Branches {
+			fw.WriteNodeAndChildren(b, fmt.Sprintf("%vB%v", path, i))
+		}
+	}
 	if n.Splitter != nil && n.Missing != nil {
-		fw.WriteNodeAndChildren(n.Right, path+"M")
+		fw.WriteNodeAndChildren(n.Missing, path+"M")
 	}
 
 }
@@ -71,12 +85,21 @@ func (fw *ForestWriter) WriteNode(n *Node, path string) {
 		node += fmt.Sprintf(",PRED=%v", n.Pred)
 	}
 
-	if n.Splitter != nil {
+	if n.Splitter != nil && n.Splitter.Oblique() {
+		weights := make([]string, 0, len(n.Splitter.Weights))
+		for _, w := range n.Splitter.Weights {
+			weights = append(weights, fmt.Sprintf("%v", w))
+		}
+		node += fmt.Sprintf(",SPLITTER=\"%v\",SPLITTERTYPE=OBLIQUE,WEIGHTS=\"%v\",LVALUES=%v,RVALUES=%v",
+			strings.Join(n.Splitter.Features, ","), strings.Join(weights, ","), n.Splitter.Value, n.Splitter.Value)
+	} else if n.Splitter != nil {
 		node += fmt.Sprintf(",SPLITTER=%v", n.Splitter.Feature)
-		switch n.Splitter.Numerical {
-		case true:
+		switch {
+		case n.Splitter.Multiway():
+			node += fmt.Sprintf(",SPLITTERTYPE=MULTIWAY,LVALUES=\"%v\",FALLBACK=%v", strings.Join(n.Splitter.Levels, ":"), n.Splitter.Fallback)
+		case n.Splitter.Numerical:
 			node += fmt.Sprintf(",SPLITTERTYPE=NUMERICAL,LVALUES=%v,RVALUES=%v", n.Splitter.Value, n.Splitter.Value)
-		case false:
+		default:
 			left := fw.DescribeMap(n.Splitter.Left)
 			node += fmt.Sprintf(",SPLITTERTYPE=CATEGORICAL,LVALUES=%v", left)
 		}
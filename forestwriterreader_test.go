@@ -1,6 +1,7 @@
 package CloudForest
 
 import (
+	"bytes"
 	"io"
 	"strings"
 	"testing"
@@ -74,3 +75,21 @@ func TestFileFormats(t *testing.T) {
 	}
 
 }
+
+func TestMissingBranchFormat(t *testing.T) {
+	tree := new(Tree)
+	tree.AddNode("*", "", &Splitter{Feature: "N:FloatVar", Numerical: true, Value: 0.5})
+	tree.AddNode("*L", "0", nil)
+	tree.AddNode("*R", "1", nil)
+	tree.AddNode("*M", "2", nil)
+
+	var sf bytes.Buffer
+	NewForestWriter(&sf).WriteForest(&Forest{"", []*Tree{tree}, "", 0.0})
+	forest, err := NewForestReader(&sf).ReadForest()
+	if err != nil {
+		t.Fatalf("Error reading forest: %v", err)
+	}
+	if root := forest.Trees[0].Root; root.Missing == nil || root.Missing.Pred != "2" || root.Right.Pred != "1" {
+		t.Errorf("Missing branch not written and read correctly.")
+	}
+}
@@ -2,11 +2,22 @@ package CloudForest
 
 import ()
 
+//GBTForest is the Type of additive forests grown by gradient boosting whose predictions
+//are Intercept plus the weighted sum of the votes of their trees (see SumBallotBox).
+const GBTForest = "GBT"
+
+//GBTClassForest is the Type of gradient boosted classification forests whose trees vote
+//"class:score" and whose votes are converted into class probabilities by a SoftmaxBallotBox.
+const GBTClassForest = "GBTCLASS"
+
 //Forest represents a collection of decision trees grown to predict Target.
+//Type is empty for ordinary forests whose trees votes are averaged.
 type Forest struct {
 	//Forest string
-	Target string
-	Trees  []*Tree
+	Target    string
+	Trees     []*Tree
+	Type      string
+	Intercept float64
 }
 
 /*
@@ -41,7 +52,18 @@ func GrowRandomForest(fm *FeatureMatrix,
 	evaloob bool,
 	importance *[]*RunningMean) (f *Forest) {
 
-	f = &Forest{target.GetName(), make([]*Tree, 0, nTrees)}
+	f = &Forest{target.GetName(), make([]*Tree, 0, nTrees), "", 0.0}
+	switch t := target.(type) {
+	case *GradBoostTarget:
+		f.Type = GBTForest
+		f.Intercept = t.Intercept
+	case *NewtonBoostTarget:
+		f.Type = GBTForest
+		f.Intercept = t.Intercept
+	case *GradBoostClassTarget:
+		f.Type = GBTClassForest
+		f.Trees = append(f.Trees, t.InterceptTrees()...)
+	}
 
 	//Slices for reuse during search for best splitter.
 	allocs := NewBestSplitAllocs(nSamples, target)
@@ -50,11 +72,14 @@ func GrowRandomForest(fm *FeatureMatrix,
 		nCases := fm.Data[0].Length()
 		cases := SampleWithReplacment(nSamples, nCases)
 
-		f.Trees = append(f.Trees, NewTree())
-		f.Trees[i].Grow(fm, target, cases, candidates, nil, mTry, leafSize, splitmissing, force, vet, evaloob, importance, nil, allocs)
+		tree := NewTree()
+		f.Trees = append(f.Trees, tree)
+		tree.Grow(fm, target, cases, candidates, nil, mTry, leafSize, splitmissing, force, vet, evaloob, importance, nil, allocs)
 		switch target.(type) {
+		case TreeBoostingTarget:
+			tree.Weight = target.(TreeBoostingTarget).BoostTree(tree, fm, cases)
 		case BoostingTarget:
-			f.Trees[i].Weight = target.(BoostingTarget).Boost(f.Trees[i].Partition(fm))
+			tree.Weight = target.(BoostingTarget).Boost(tree.Partition(fm))
 		}
 	}
 	return
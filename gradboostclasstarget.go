@@ -0,0 +1,226 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+GradBoostClassTarget wraps a categorical feature as a target for gradient boosted
+classification with the binary logistic (for two classes) or multiclass softmax loss.
+
+Each class has a running score for each case starting at the log of the class's
+frequency and predicted class probabilities are the softmax of the scores. Each boosting
+iteration grows one regression tree for each class (or one tree for the second class of
+binary targets whose first class's score stays fixed) against the class's residuals,
+1 for cases of the class minus the predicted probability, which are embeded as the
+NumFeature for the class whose tree is being grown. After a tree is grown BoostTree sets
+the prediction of each leaf to the Newton step:
+
+	(K-1)/K * sum(r) / sum(|r|*(1-|r|))
+
+for its cases' residuals r (without the (K-1)/K for binary targets), adds LearnRate times
+it to the class's scores and, after the last class of the iteration, recalculates the
+probabilities and residuals.
+
+Leaf predictions are written as "class:step" so votes can be summed by class in a
+SoftmaxBallotBox and the initial scores are included in the forest as one leaf
+InterceptTrees.
+
+The original target Y is never modified. GradBoostClassTarget is not thread safe and
+its trees must be boosted in the order they are grown.
+*/
+type GradBoostClassTarget struct {
+	NumFeature
+	Y          CatFeature
+	LearnRate  float64
+	Intercepts []float64
+	Scores     [][]float64
+	boosted    []int
+	residuals  []NumFeature
+	next       int
+}
+
+/*
+NewGradBoostClassTarget creates a gradient boosting target for the categorical feature y
+with the specified learning rate and initializes the scores and residuals.
+*/
+func NewGradBoostClassTarget(y CatFeature, learnRate float64) (gbt *GradBoostClassTarget) {
+	n := y.Length()
+	ncats := y.NCats()
+	counts := make([]float64, ncats)
+	total := 0.0
+	for i := 0; i < n; i++ {
+		if !y.IsMissing(i) {
+			counts[y.Geti(i)]++
+			total++
+		}
+	}
+
+	gbt = &GradBoostClassTarget{nil, y, learnRate, make([]float64, ncats), make([][]float64, ncats), nil, nil, 0}
+	for k := range gbt.Intercepts {
+		gbt.Intercepts[k] = math.Log(counts[k] / total)
+		gbt.boosted = append(gbt.boosted, k)
+	}
+	if ncats == 2 {
+		//binary logistic with the log odds of the second class as its score
+		gbt.Intercepts[1] -= gbt.Intercepts[0]
+		gbt.Intercepts[0] = 0.0
+		gbt.boosted = gbt.boosted[1:]
+	}
+	for k := range gbt.Scores {
+		gbt.Scores[k] = make([]float64, n)
+		for i := range gbt.Scores[k] {
+			gbt.Scores[k][i] = gbt.Intercepts[k]
+		}
+	}
+
+	for range gbt.boosted {
+		r := &DenseNumFeature{make([]float64, n), make([]bool, n), y.GetName(), false}
+		for i := 0; i < n; i++ {
+			if y.IsMissing(i) {
+				r.PutMissing(i)
+			}
+		}
+		gbt.residuals = append(gbt.residuals, r)
+	}
+	gbt.updateResiduals()
+	gbt.NumFeature = gbt.residuals[0]
+	return
+}
+
+//Probabilities returns the predicted probability of each class for case i.
+func (f *GradBoostClassTarget) Probabilities(i int) []float64 {
+	scores := make([]float64, len(f.Scores))
+	for k := range f.Scores {
+		scores[k] = f.Scores[k][i]
+	}
+	return softmax(scores)
+}
+
+//updateResiduals recalculates the residuals of each boosted class from the current scores.
+func (f *GradBoostClassTarget) updateResiduals() {
+	for i := 0; i < f.Y.Length(); i++ {
+		if f.Y.IsMissing(i) {
+			continue
+		}
+		p := f.Probabilities(i)
+		y := f.Y.Geti(i)
+		for j, k := range f.boosted {
+			r := -p[k]
+			if y == k {
+				r += 1.0
+			}
+			f.residuals[j].Put(i, r)
+		}
+	}
+}
+
+//IsMissing returns weather case i of the original target is missing.
+func (f *GradBoostClassTarget) IsMissing(i int) bool {
+	return f.Y.IsMissing(i)
+}
+
+//TreesPerIteration returns the number of trees grown in each boosting iteration.
+func (f *GradBoostClassTarget) TreesPerIteration() int {
+	return len(f.boosted)
+}
+
+/*
+BoostTree sets the node predictions of a tree grown against the residuals of the
+current class to the Newton step for the in bag cases that fall in each node, updates
+the class's scores of all cases using the step of their leaf, moves on to the next class
+and returns the weight (LearnRate) the tree should be given.
+*/
+func (f *GradBoostClassTarget) BoostTree(tree *Tree, fm *FeatureMatrix, inbag []int) (weight float64) {
+	k := f.boosted[f.next]
+	residuals := f.residuals[f.next]
+	label := f.Y.NumToCat(k)
+	factor := 1.0
+	if len(f.boosted) > 1 {
+		factor = float64(len(f.boosted)-1) / float64(len(f.boosted))
+	}
+
+	boostNodes(tree, fm, inbag, func(n *Node, cases []int) (step float64, pred string) {
+		num := 0.0
+		den := 0.0
+		for _, i := range cases {
+			if !f.Y.IsMissing(i) {
+				r := residuals.Get(i)
+				num += r
+				den += math.Abs(r) * (1.0 - math.Abs(r))
+			}
+		}
+		if den > 0.0 {
+			step = factor * num / den
+		}
+		return step, fmt.Sprintf("%v:%v", label, step)
+	}, func(i int, step float64) {
+		f.Scores[k][i] += f.LearnRate * step
+	})
+
+	f.next++
+	if f.next == len(f.boosted) {
+		f.next = 0
+		f.updateResiduals()
+	}
+	f.NumFeature = f.residuals[f.next]
+	return f.LearnRate
+}
+
+//InterceptTrees returns a one leaf tree for each class that votes for the class's initial
+//score. They should be included in the forest before the boosted trees.
+func (f *GradBoostClassTarget) InterceptTrees() (trees []*Tree) {
+	for k, intercept := range f.Intercepts {
+		tree := NewTree()
+		tree.Target = f.Y.GetName()
+		tree.Root.Pred = fmt.Sprintf("%v:%v", f.Y.NumToCat(k), intercept)
+		trees = append(trees, tree)
+	}
+	return
+}
+
+//TrainingLoss returns the mean negative log likelihood of the current predictions over
+//the non missing cases.
+func (f *GradBoostClassTarget) TrainingLoss() float64 {
+	cases := make([]int, 0, f.Y.Length())
+	for i := 0; i < f.Y.Length(); i++ {
+		cases = append(cases, i)
+	}
+	return f.MeanLoss(cases)
+}
+
+//MeanLoss returns the mean negative log likelihood of the current predictions over the non
+//missing cases specified or 0 if there are none.
+func (f *GradBoostClassTarget) MeanLoss(cases []int) float64 {
+	l := 0.0
+	n := 0
+	for _, i := range cases {
+		if !f.Y.IsMissing(i) {
+			l -= math.Log(f.Probabilities(i)[f.Y.Geti(i)])
+			n++
+		}
+	}
+	if n == 0 {
+		return 0.0
+	}
+	return l / float64(n)
+}
+
+//softmax returns exp(scores) normalized to sum to one.
+func softmax(scores []float64) []float64 {
+	max := math.Inf(-1)
+	for _, s := range scores {
+		max = math.Max(max, s)
+	}
+	p := make([]float64, len(scores))
+	total := 0.0
+	for k, s := range scores {
+		p[k] = math.Exp(s - max)
+		total += p[k]
+	}
+	for k := range p {
+		p[k] /= total
+	}
+	return p
+}
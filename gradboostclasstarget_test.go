@@ -0,0 +1,90 @@
+package CloudForest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestGradBoostClass(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	//three classes determined by X plus some label noise
+	n := 300
+	x := make([]string, 0, n)
+	y := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		v := rng.Float64()
+		class := int(3.0 * v)
+		if rng.Float64() < 0.1 {
+			class = rng.Intn(3)
+		}
+		x = append(x, fmt.Sprintf("%v", v))
+		y = append(y, []string{"a", "b", "c"}[class])
+	}
+	for _, classes := range []int{2, 3} {
+		labels := make([]string, 0, n)
+		for _, l := range y {
+			if classes == 2 && l == "c" {
+				l = "b"
+			}
+			labels = append(labels, l)
+		}
+		fm := parseRows([]string{"C:Y", "N:X"}, [][]string{labels, x})
+		target := fm.Data[0].(CatFeature)
+
+		gbt := NewGradBoostClassTarget(target, 0.2)
+		//binary targets only boost the second class
+		expected := classes
+		if classes == 2 {
+			expected = 1
+		}
+		if gbt.TreesPerIteration() != expected {
+			t.Errorf("%v classes boosted with %v trees per iteration.", classes, gbt.TreesPerIteration())
+		}
+		//the initial probabilities are the class frequencies
+		p := gbt.Probabilities(0)
+		for k := range p {
+			freq := 0.0
+			for i := 0; i < n; i++ {
+				if target.Geti(i) == k {
+					freq++
+				}
+			}
+			if math.Abs(p[k]-freq/float64(n)) > 1e-9 {
+				t.Errorf("Initial probability %v of class %v isn't its frequency %v.", p[k], k, freq/float64(n))
+			}
+		}
+
+		initial := gbt.TrainingLoss()
+		forest := GrowRandomForest(fm, gbt, []int{1}, n, 1, 30*gbt.TreesPerIteration(), 10, false, false, false, false, nil)
+		if final := gbt.TrainingLoss(); !(final < initial/2.0) {
+			t.Errorf("Boosting %v classes reduced the training loss from %v only to %v.", classes, initial, final)
+		}
+
+		var sf bytes.Buffer
+		NewForestWriter(&sf).WriteForest(forest)
+		read, err := NewForestReader(&sf).ReadForest()
+		if err != nil {
+			t.Fatalf("Error reading boosted forest: %v", err)
+		}
+		if read.Type != GBTClassForest || len(read.Trees) != len(forest.Trees) {
+			t.Errorf("Boosted forest read with type %v and %v trees.", read.Type, len(read.Trees))
+		}
+		bb := NewSoftmaxBallotBox(n)
+		for _, tree := range read.Trees {
+			tree.Vote(fm, bb)
+		}
+		for i := 0; i < n; i++ {
+			for k, pk := range gbt.Probabilities(i) {
+				if math.Abs(bb.TallyProbability(i, target.NumToCat(k))-pk) > 1e-9 {
+					t.Fatalf("Applied probability of class %v for case %v doesn't match the training fit %v.", k, i, pk)
+				}
+			}
+		}
+		if e := bb.TallyError(target); e > 0.2 {
+			t.Errorf("Boosted classification of %v classes has error %v.", classes, e)
+		}
+	}
+}
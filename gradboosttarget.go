@@ -1,31 +1,188 @@
 package CloudForest
 
-import ()
+import (
+	"fmt"
+	"io"
+	"math"
+)
 
 /*
-GradBoostTarget wraps a numerical feature as a target for us in Gradiant Boosting Trees
+GradBoostTarget wraps a numerical feature as a target for us in Gradiant Boosting Trees.
+
+The original target Y is never modified. GradBoostTarget keeps a running prediction
+for each case, Pred, which starts at the constant Intercept minimizing the Loss, and
+the embeded NumFeature holds the current pseudo residuals (the negative gradient of the
+Loss at Pred) that each tree is grown against. After a tree is grown BoostTree sets the
+prediction of each of its leaves to the Loss minimizing step for the leaf's in bag cases,
+adds LearnRate times that step to Pred and recalculates the residuals.
+
+The forest's prediction for a case is then Intercept plus the sum over trees of the tree's
+weight (LearnRate) times the prediction of the leaf the case falls in which can be
+tallied with a SumBallotBox.
+
+GradBoostTarget is not thread safe; calls to BoostTree and Residuals should be serialized.
 */
 type GradBoostTarget struct {
 	NumFeature
+	Y         NumFeature
+	Loss      Loss
 	LearnRate float64
+	Intercept float64
+	Pred      []float64
 }
 
-//BUG(ryan) does GradBoostingTarget need seperate residuals and values?
-func (f *GradBoostTarget) Boost(leaves *[][]int) (weight float64) {
-	for _, cases := range *leaves {
-		f.Update(&cases)
+/*
+NewGradBoostTarget creates a gradient boosting target for y using the supplied loss and
+learning rate and initializes the predictions to the loss minimizing constant.
+*/
+func NewGradBoostTarget(y NumFeature, loss Loss, learnRate float64) (gbt *GradBoostTarget) {
+	n := y.Length()
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if !y.IsMissing(i) {
+			cases = append(cases, i)
+		}
+	}
+	gbt = &GradBoostTarget{y.Copy().(NumFeature), y, loss, learnRate, loss.Init(y, cases), make([]float64, n)}
+	for i := range gbt.Pred {
+		gbt.Pred[i] = gbt.Intercept
+	}
+	gbt.updateResiduals()
+	return
+}
+
+//updateResiduals sets the pseudo residual of each non missing case to the negative
+//gradient of the loss at its current prediction.
+func (f *GradBoostTarget) updateResiduals() {
+	for i, p := range f.Pred {
+		if !f.Y.IsMissing(i) {
+			f.Put(i, f.Loss.NegGradient(f.Y.Get(i), p))
+		}
 	}
+}
+
+//IsMissing returns weather case i of the original target is missing. It doesn't read the
+//residuals so it is safe to call while trees are being boosted.
+func (f *GradBoostTarget) IsMissing(i int) bool {
+	return f.Y.IsMissing(i)
+}
+
+//Residuals returns a copy of the current pseudo residuals for growing a tree while other
+//trees are being boosted (as in multiboost).
+func (f *GradBoostTarget) Residuals() NumFeature {
+	return f.NumFeature.Copy().(NumFeature)
+}
+
+/*
+BoostTree sets the prediction of each node of a tree grown against the residuals to the
+loss minimizing step for the in bag cases (those the tree was grown on) that fall in it,
+clipped to the node's Bounds if it was grown with monotone constraints. It then adds the
+step of the leaf each case falls in to the predictions of all cases, updates the residuals
+and returns the weight (LearnRate) the tree should be given.
+*/
+func (f *GradBoostTarget) BoostTree(tree *Tree, fm *FeatureMatrix, inbag []int) (weight float64) {
+	boostNodes(tree, fm, inbag, func(n *Node, cases []int) (step float64, pred string) {
+		step = f.Loss.LeafValue(f.Y, f.Pred, cases)
+		if math.IsNaN(step) {
+			//no non missing cases
+			step = 0.0
+		}
+		step = n.Bounds.Clip(step)
+		return step, fmt.Sprintf("%v", step)
+	}, func(i int, step float64) {
+		f.Pred[i] += f.LearnRate * step
+	})
+
+	f.updateResiduals()
 	return f.LearnRate
+}
 
+/*
+boostNodes calls fit with the in bag cases that fall in each node of tree to get the
+node's step and prediction, so the leaf steps of boosted trees are fit only on the cases
+the tree was grown on, and then calls update with each case and the step of the leaf it
+falls in.
+*/
+func boostNodes(tree *Tree, fm *FeatureMatrix, inbag []int, fit func(n *Node, cases []int) (step float64, pred string), update func(i int, step float64)) {
+	steps := make(map[*Node]float64)
+	tree.Root.Recurse(func(n *Node, cases []int, depth int) {
+		step, pred := fit(n, cases)
+		n.Pred = pred
+		if n.IsLeaf() {
+			steps[n] = step
+		}
+	}, fm, inbag, 0)
+
+	ncases := fm.Data[0].Length()
+	cases := make([]int, 0, ncases)
+	for i := 0; i < ncases; i++ {
+		cases = append(cases, i)
+	}
+	tree.Root.Recurse(func(n *Node, cases []int, depth int) {
+		if step, ok := steps[n]; ok {
+			for _, i := range cases {
+				update(i, step)
+			}
+		}
+	}, fm, cases, 0)
 }
 
-//Update updates the underlying numeric data by subtracting the mean*weight of the
-//specified cases from the value for those cases.
-func (f *GradBoostTarget) Update(cases *[]int) {
-	m := f.Predicted(cases)
-	for _, i := range *cases {
-		if !f.IsMissing(i) {
-			f.Put(i, f.Get(i)-f.LearnRate*m)
+//TrainingLoss returns the mean loss of the current predictions over the non missing cases.
+func (f *GradBoostTarget) TrainingLoss() float64 {
+	cases := make([]int, 0, len(f.Pred))
+	for i := range f.Pred {
+		cases = append(cases, i)
+	}
+	return f.MeanLoss(cases)
+}
+
+//MeanLoss returns the mean loss of the current predictions over the non missing cases
+//specified or 0 if there are none.
+func (f *GradBoostTarget) MeanLoss(cases []int) float64 {
+	l := 0.0
+	n := 0
+	for _, i := range cases {
+		if !f.Y.IsMissing(i) {
+			l += f.Loss.Eval(f.Y.Get(i), f.Pred[i])
+			n++
+		}
+	}
+	if n == 0 {
+		return 0.0
+	}
+	return l / float64(n)
+}
+
+/*
+BestIteration returns the number of boosting iterations that maximizes the cumulative out of
+bag improvement (the decrease in the loss of the cases left out of each iteration's sample)
+as in gbm's "OOB" method or 0 if no iteration improved the loss.
+*/
+func BestIteration(improvement []float64) (best int) {
+	total := 0.0
+	max := 0.0
+	for i, v := range improvement {
+		total += v
+		if total > max {
+			max = total
+			best = i + 1
+		}
+	}
+	return
+}
+
+//WriteOOBImprovement writes a tsv with the out of bag improvement made by each boosting
+//iteration and the cumulative improvement.
+func WriteOOBImprovement(w io.Writer, improvement []float64) (err error) {
+	if _, err = fmt.Fprintln(w, "Iteration\tOOBImprovement\tCumulative"); err != nil {
+		return
+	}
+	total := 0.0
+	for i, v := range improvement {
+		total += v
+		if _, err = fmt.Fprintf(w, "%v\t%v\t%v\n", i+1, v, total); err != nil {
+			return
 		}
 	}
+	return
 }
@@ -0,0 +1,124 @@
+package CloudForest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLosses(t *testing.T) {
+	losses := []Loss{SquaredLoss{}, AbsoluteLoss{}, HuberLoss{1.0}, QuantileLoss{0.8}}
+	h := 1e-6
+	for _, loss := range losses {
+		for _, y := range []float64{-2.0, 0.3, 5.0} {
+			for _, f := range []float64{-1.1, 0.7, 3.2} {
+				numeric := -(loss.Eval(y, f+h) - loss.Eval(y, f-h)) / (2.0 * h)
+				if g := loss.NegGradient(y, f); math.Abs(g-numeric) > 1e-4 {
+					t.Errorf("%T negative gradient %v at y=%v f=%v not %v.", loss, g, y, f, numeric)
+				}
+			}
+		}
+	}
+}
+
+func TestGradBoost(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 300
+	x := make([]string, 0, n)
+	y := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		v := rng.Float64()
+		x = append(x, fmt.Sprintf("%v", v))
+		y = append(y, fmt.Sprintf("%v", 10.0+math.Sin(6.0*v)+0.1*rng.NormFloat64()))
+	}
+	fm := parseRows([]string{"N:Y", "N:X"}, [][]string{y, x})
+	original := fm.Data[0].(NumFeature).Copy().(NumFeature)
+
+	for _, loss := range []Loss{SquaredLoss{}, AbsoluteLoss{}, HuberLoss{0.5}, QuantileLoss{0.8}} {
+		gbt := NewGradBoostTarget(fm.Data[0].(NumFeature), loss, 0.1)
+		initial := gbt.TrainingLoss()
+		forest := GrowRandomForest(fm, gbt, []int{1}, n, 1, 100, 10, false, false, false, false, nil)
+		if final := gbt.TrainingLoss(); !(final < initial/2.0) {
+			t.Errorf("%T boosting reduced the training loss from %v only to %v.", loss, initial, final)
+		}
+		for i := 0; i < n; i++ {
+			if fm.Data[0].(NumFeature).Get(i) != original.Get(i) {
+				t.Fatalf("%T boosting modified the target.", loss)
+			}
+		}
+
+		//predictions from a saved forest should match the training fit
+		var sf bytes.Buffer
+		NewForestWriter(&sf).WriteForest(forest)
+		read, err := NewForestReader(&sf).ReadForest()
+		if err != nil {
+			t.Fatalf("Error reading boosted forest: %v", err)
+		}
+		if read.Type != GBTForest || read.Intercept != gbt.Intercept || len(read.Trees) != 100 {
+			t.Errorf("Boosted forest read with type %v intercept %v and %v trees.", read.Type, read.Intercept, len(read.Trees))
+		}
+		bb := NewSumBallotBox(n, read.Intercept)
+		for _, tree := range read.Trees {
+			tree.Vote(fm, bb)
+		}
+		for i := 0; i < n; i++ {
+			if math.Abs(bb.TallyNum(i)-gbt.Pred[i]) > 1e-9 {
+				t.Errorf("%T applied prediction %v for case %v doesn't match the training fit %v.", loss, bb.TallyNum(i), i, gbt.Pred[i])
+				break
+			}
+		}
+	}
+
+	//node steps should be fit on the in bag cases only but applied to all cases
+	loss := AbsoluteLoss{}
+	gbt := NewGradBoostTarget(fm.Data[0].(NumFeature), loss, 0.1)
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+	inbag := cases[:n/2]
+	tree := NewTree()
+	tree.Grow(fm, gbt, inbag, []int{1}, nil, 1, 50, false, false, false, false, nil, nil, NewBestSplitAllocs(n, gbt))
+	before := append([]float64(nil), gbt.Pred...)
+	gbt.BoostTree(tree, fm, inbag)
+	tree.Root.Recurse(func(node *Node, nodecases []int, depth int) {
+		fit := make([]int, 0, len(nodecases))
+		for _, i := range nodecases {
+			if i < n/2 {
+				fit = append(fit, i)
+			}
+		}
+		step := loss.LeafValue(gbt.Y, before, fit)
+		if node.Pred != fmt.Sprintf("%v", step) {
+			t.Errorf("Node at depth %v predicts %v not the in bag step %v.", depth, node.Pred, step)
+		}
+		if node.IsLeaf() {
+			for _, i := range nodecases {
+				if math.Abs(gbt.Pred[i]-before[i]-0.1*step) > 1e-9 {
+					t.Fatalf("Prediction of case %v wasn't updated with its leaf's step.", i)
+				}
+			}
+		}
+	}, fm, cases, 0)
+}
+
+func TestOOBImprovement(t *testing.T) {
+	improvement := []float64{0.5, 0.2, -0.1, 0.05, -0.3}
+	if best := BestIteration(improvement); best != 2 {
+		t.Errorf("Best iteration %v not 2.", best)
+	}
+	if best := BestIteration([]float64{-0.1, -0.2}); best != 0 {
+		t.Errorf("Best iteration without improvement %v not 0.", best)
+	}
+	var out bytes.Buffer
+	if err := WriteOOBImprovement(&out, improvement); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 || lines[2] != "2\t0.2\t0.7" {
+		t.Errorf("Unexpected oob improvement output:\n%v", out.String())
+	}
+}
@@ -16,68 +16,128 @@ import (
 	"time"
 )
 
+/*
+GrowOpts holds the options used by Grow. They correspond to the command line options of
+growforest (see that utility's usage for details). SetDefaults should be used to
+initialize GrowOpts to growforest's defaults.
+*/
 type GrowOpts struct {
-	imp *string
-	costs *string
-	rfweights *string
-	blacklist *string
-	nCores int
-	StringnSamples string
-	StringmTry string
-	StringleafSize string
-	shuffleRE string
-	blockRE string
-	includeRE string
-	nTrees int
-	nContrasts int
-	cpuprofile *string
-	contrastAll bool
-	impute bool
-	splitmissing bool
-	l1 bool
-	density bool
-	vet bool
-	evaloob bool
-	force bool
-	entropy bool
-	oob bool
-	caseoob string
-	progress bool
-	adaboost bool
-	gradboost float64
-	multiboost bool
-	nobag bool
-	balance bool
-	balanceby string
-	ordinal bool
-	permutate bool
-	dotest bool
-	testfm string
-}
-
-func newEmpty() *string {
-	var s = ""
-	return &s
+	Imp             string
+	Costs           string
+	RfWeights       string
+	Blacklist       string
+	NCores          int
+	StringnSamples  string
+	StringmTry      string
+	StringleafSize  string
+	ShuffleRE       string
+	BlockRE         string
+	IncludeRE       string
+	NTrees          int
+	NContrasts      int
+	CpuProfile      string
+	ContrastAll     bool
+	Impute          bool
+	SplitMissing    bool
+	L1              bool
+	Density         bool
+	Vet             bool
+	EvalOOB         bool
+	Force           bool
+	Entropy         bool
+	OOB             bool
+	CaseOOB         string
+	Progress        bool
+	AdaBoost        bool
+	GradBoost       float64
+	MultiBoost      bool
+	NoBag           bool
+	Balance         bool
+	BalanceBy       string
+	Ordinal         bool
+	Permutate       bool
+	DoTest          bool
+	TestFm          string
+	InBag           string
+	RfImpute        int
+	Isolation       bool
+	Unsupervised    string
+	Survival        string
+	ScaleTargets    bool
+	Poisson         bool
+	Gamma           bool
+	Tweedie         float64
+	Huber           float64
+	Quantile        float64
+	Newton          bool
+	Lambda          float64
+	Alpha           float64
+	MinSplitLoss    float64
+	MinChildWeight  float64
+	Subsample       float64
+	ColSampleTree   float64
+	ColSampleLevel  float64
+	ColSampleNode   float64
+	OOBImprove      string
+	Bins            int
+	Presort         bool
+	ExtraTrees      bool
+	Oblique         int
+	ObliqueFeatures int
+	Multiway        int
+	Monotone        string
 }
 
+//SetDefaults sets the options that don't default to their zero value.
 func (me *GrowOpts) SetDefaults() {
-	me.imp = newEmpty()
-	me.costs = newEmpty()
-	me.rfweights = newEmpty()
-	me.blacklist = newEmpty()
-	me.cpuprofile = newEmpty()
-	me.nCores = 1
+	me.NCores = 1
 	me.StringnSamples = "0"
 	me.StringmTry = "0"
 	me.StringleafSize = "0"
-	me.nTrees = 100
+	me.NTrees = 100
+	me.Lambda = 1.0
 }
 
+/*
+LoadBlacklist reads a list of feature id's (one per line, as the first column of a tsv)
+from the named file and marks them in blacklistis, which should be parallel to
+data.Data. It returns the number of features newly blacklisted. Features not found in
+the data are ignored.
+*/
+func LoadBlacklist(filename string, data *FeatureMatrix, blacklistis []bool) (blacklisted int, err error) {
+	blackfile, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer blackfile.Close()
+	tsv := csv.NewReader(blackfile)
+	tsv.Comma = '\t'
+	tsv.FieldsPerRecord = -1
+	for {
+		id, e := tsv.Read()
+		if e == io.EOF {
+			break
+		} else if e != nil {
+			return blacklisted, e
+		}
+		i, ok := data.Map[id[0]]
+		if !ok {
+			fmt.Printf("Ignoring blacklist feature not found in data: %v\n", id[0])
+			continue
+		}
+		if !blacklistis[i] {
+			blacklisted += 1
+			blacklistis[i] = true
+		}
+
+	}
+	return
+}
 
 func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o GrowOpts) {
 
-	if *o.cpuprofile != "" {
-		f, err := os.Create(*o.cpuprofile)
+	if o.CpuProfile != "" {
+		f, err := os.Create(o.CpuProfile)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -87,76 +147,110 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	if o.testfm != "" {
-		o.dotest = true
+	nReal := data.Data[0].Length()
+	if o.Unsupervised != "" {
+		fmt.Println("Growing unsupervised forest to classify real vs synthetic cases.")
+		features := make([]int, 0, len(data.Data))
+		for i := range data.Data {
+			features = append(features, i)
+		}
+		data = data.SyntheticContrast(features)
+		unsupervised := UnsupervisedTarget
+		targetname = &unsupervised
+	}
+
+	if o.TestFm != "" {
+		o.DoTest = true
 	}
 
-	if o.multiboost {
+	if o.MultiBoost {
 		fmt.Println("MULTIBOOST!!!!1!!!!1!!11 (things may break).")
 	}
 	var boostMutex sync.Mutex
-	boost := (o.adaboost || o.gradboost != 0.0)
-	if boost && !o.multiboost {
-		o.nCores = 1
+	boost := (o.AdaBoost || o.GradBoost != 0.0)
+	if o.Newton && o.GradBoost == 0.0 {
+		log.Fatal("Newton boosting requires a gradiant boosting learning rate (-gbt).")
+	}
+	if boost && !o.MultiBoost && o.NCores > 1 {
+		//each tree is grown against the residuals left by the trees before it
+		fmt.Println("Boosted trees are grown one at a time, ignoring -nCores (see -multiboost).")
+		o.NCores = 1
 	}
 
-	if o.nCores > 1 {
+	if o.NCores > 1 {
 
-		runtime.GOMAXPROCS(o.nCores)
+		runtime.GOMAXPROCS(o.NCores)
 	}
-	fmt.Printf("Threads : %v\n", o.nCores)
-	fmt.Printf("nTrees : %v\n", o.nTrees)
+	fmt.Printf("Threads : %v\n", o.NCores)
+	fmt.Printf("nTrees : %v\n", o.NTrees)
 
-	if o.nContrasts > 0 {
-		fmt.Printf("Adding %v Random Contrasts\n", o.nContrasts)
-		data.AddContrasts(o.nContrasts)
+	if o.NContrasts > 0 {
+		fmt.Printf("Adding %v Random Contrasts\n", o.NContrasts)
+		data.AddContrasts(o.NContrasts)
 	}
-	if o.contrastAll {
+	if o.ContrastAll {
 		fmt.Printf("Adding Random Contrasts for All Features.\n")
 		data.ContrastAll()
 	}
 
 	blacklisted := 0
 	blacklistis := make([]bool, len(data.Data))
-	if *o.blacklist != "" {
-		fmt.Printf("Loading blacklist from: %v\n", *o.blacklist)
-		blackfile, err := os.Open(*o.blacklist)
+	if o.Blacklist != "" {
+		fmt.Printf("Loading blacklist from: %v\n", o.Blacklist)
+		n, err := LoadBlacklist(o.Blacklist, data, blacklistis)
 		if err != nil {
 			log.Fatal(err)
 		}
-		tsv := csv.NewReader(blackfile)
-		tsv.Comma = '\t'
-		for {
-			id, err := tsv.Read()
-			if err == io.EOF {
-				break
-			} else if err != nil {
-				log.Fatal(err)
-			}
-			i, ok := data.Map[id[0]]
-			if !ok {
-				fmt.Printf("Ignoring blacklist feature not found in data: %v\n", id[0])
-				continue
-			}
-			if !blacklistis[i] {
-				blacklisted += 1
-				blacklistis[i] = true
-			}
-
-		}
-		blackfile.Close()
-
+		blacklisted += n
 	}
 
 	//find the target feature
 	fmt.Printf("Target : %v\n", *targetname)
 	targeti, ok := data.Map[*targetname]
+	if names := ParseMultiTargetName(*targetname); !ok && names != nil {
+		fmt.Printf("Using %v targets.\n", len(names))
+		mt, err := NewMultiTarget(data, names, o.ScaleTargets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range names {
+			if i := data.Map[name]; !blacklistis[i] {
+				blacklisted += 1
+				blacklistis[i] = true
+			}
+		}
+		//the combined target is added to the data so it can be refered to by index
+		targeti, ok = len(data.Data), true
+		data.Data = append(data.Data, mt)
+		data.Map[*targetname] = targeti
+		blacklistis = append(blacklistis, false)
+	}
 	if !ok {
-		log.Fatal("Target not found in data.")
+		if !o.Isolation {
+			log.Fatal("Target not found in data.")
+		}
+		//isolation forests don't need a target
+		targeti = -1
 	}
 
-	if o.blockRE != "" {
-		re := regexp.MustCompile(o.blockRE)
+	treeTarget := *targetname
+	var survivalEvent Feature
+	if o.Survival != "" {
+		eventi, ok := data.Map[o.Survival]
+		if !ok {
+			log.Fatal("Survival event feature not found in data.")
+		}
+		fmt.Printf("Survival event : %v\n", o.Survival)
+		survivalEvent = data.Data[eventi]
+		treeTarget = SurvivalTargetName(*targetname, o.Survival)
+		if !blacklistis[eventi] {
+			blacklisted += 1
+			blacklistis[eventi] = true
+		}
+	}
+
+	if o.BlockRE != "" {
+		re := regexp.MustCompile(o.BlockRE)
 		for i, feature := range data.Data {
 			if targeti != i && re.MatchString(feature.GetName()) {
 				if blacklistis[i] == false {
@@ -170,8 +264,8 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 
 	}
 
-	if o.includeRE != "" {
-		re := regexp.MustCompile(o.includeRE)
+	if o.IncludeRE != "" {
+		re := regexp.MustCompile(o.IncludeRE)
 		for i, feature := range data.Data {
 			if targeti != i && !re.MatchString(feature.GetName()) {
 				if blacklistis[i] == false {
@@ -184,9 +278,34 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 		}
 	}
 
-	nFeatures := len(data.Data) - blacklisted - 1
+	nFeatures := len(data.Data) - blacklisted
+	if targeti >= 0 {
+		nFeatures--
+	}
 	fmt.Printf("Non Target Features : %v\n", nFeatures)
 
+	if o.Isolation {
+		canidates := make([]int, 0, len(data.Data))
+		for i := 0; i < len(data.Data); i++ {
+			if i != targeti && !blacklistis[i] {
+				canidates = append(canidates, i)
+			}
+		}
+		nSamples := ParseAsIntOrFractionOfTotal(o.StringnSamples, data.Data[0].Length())
+		if nSamples <= 0 {
+			nSamples = 256
+		}
+		if nSamples > data.Data[0].Length() {
+			nSamples = data.Data[0].Length()
+		}
+		fmt.Printf("Growing isolation forest with nSamples : %v\n", nSamples)
+		forest := GrowIsolationForest(data, canidates, nSamples, o.NTrees)
+		if forestwriter != nil {
+			forestwriter.WriteForest(forest)
+		}
+		return
+	}
+
 	mTry := ParseAsIntOrFractionOfTotal(o.StringmTry, nFeatures)
 	if mTry <= 0 {
 
@@ -194,18 +313,23 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 	}
 	fmt.Printf("mTry : %v\n", mTry)
 
-	if o.impute {
+	if o.Impute && o.RfImpute > 0 {
+		//mean/mode imputation would leave nothing for proximity imputation to fill in
+		log.Fatal("-impute and -rfimpute can't be used together.")
+	}
+
+	if o.Impute {
 		fmt.Println("Imputing missing values to feature mean/mode.")
 		data.ImputeMissing()
 	}
 
-	if o.permutate {
+	if o.Permutate {
 		fmt.Println("Permutating target feature.")
 		data.Data[targeti].Shuffle()
 	}
 
-	if o.shuffleRE != "" {
-		re := regexp.MustCompile(o.shuffleRE)
+	if o.ShuffleRE != "" {
+		re := regexp.MustCompile(o.ShuffleRE)
 		shuffled := 0
 		for i, feature := range data.Data {
 			if targeti != i && re.MatchString(feature.GetName()) {
@@ -215,20 +339,20 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 			}
 
 		}
-		fmt.Printf("Shuffled %v features matching %v\n", shuffled, o.shuffleRE)
+		fmt.Printf("Shuffled %v features matching %v\n", shuffled, o.ShuffleRE)
 	}
 
 	targetf := data.Data[targeti]
 	unboostedTarget := targetf.Copy()
 
 	var bSampler Bagger
-	if o.balance {
+	if o.Balance {
 		bSampler = NewBalancedSampler(targetf.(*DenseCatFeature))
 	}
 
-	if o.balanceby != "" {
-		bSampler = NewSecondaryBalancedSampler(targetf.(*DenseCatFeature), data.Data[data.Map[o.balanceby]].(*DenseCatFeature))
-		o.balance = true
+	if o.BalanceBy != "" {
+		bSampler = NewSecondaryBalancedSampler(targetf.(*DenseCatFeature), data.Data[data.Map[o.BalanceBy]].(*DenseCatFeature))
+		o.Balance = true
 
 	}
 
@@ -245,7 +369,13 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 	leafSize := ParseAsIntOrFractionOfTotal(o.StringleafSize, nNonMissing)
 
 	if leafSize <= 0 {
-		if boost {
+		if o.Newton && o.MinChildWeight > 0.0 {
+			//the min child weight limits the size of leaves instead
+			leafSize = 1
+		} else if boost && o.Subsample > 0.0 {
+			//relative to the cases in each iteration's subsample
+			leafSize = sampleCount(o.Subsample, nNonMissing) / 3
+		} else if boost {
 			leafSize = nNonMissing / 3
 		} else if targetf.NCats() == 0 {
 			//regression
@@ -257,6 +387,17 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 	}
 	fmt.Printf("leafSize : %v\n", leafSize)
 
+	if o.RfImpute > 0 {
+		fmt.Printf("Imputing missing values using proximities from %v iterations.\n", o.RfImpute)
+		canidates := make([]int, 0, len(data.Data))
+		for i := 0; i < len(data.Data); i++ {
+			if i != targeti && !blacklistis[i] {
+				canidates = append(canidates, i)
+			}
+		}
+		data.ProximityImpute(targeti, canidates, o.RfImpute, o.NTrees, mTry, leafSize, o.NCores)
+	}
+
 	//infer nSamples and mTry from data if they are 0
 	nSamples := ParseAsIntOrFractionOfTotal(o.StringnSamples, nNonMissing)
 	if nSamples <= 0 {
@@ -264,16 +405,21 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 	}
 	fmt.Printf("nSamples : %v\n", nSamples)
 
-	if o.progress {
-		o.oob = true
+	if o.Progress {
+		o.OOB = true
 	}
-	if o.caseoob != "" {
-		o.oob = true
+	if o.CaseOOB != "" {
+		o.OOB = true
 	}
 	var oobVotes VoteTallyer
-	if o.oob {
+	if o.OOB {
 		fmt.Println("Recording oob error.")
-		if targetf.NCats() == 0 {
+		if survivalEvent != nil {
+			events, _ := ParseEvents(survivalEvent)
+			oobVotes = NewSurvivalBallotBox(events)
+		} else if mt, ok := targetf.(*MultiTarget); ok {
+			oobVotes = NewMultiBallotBox(data.Data[0].Length(), mt.Targets)
+		} else if targetf.NCats() == 0 {
 			//regression
 			oobVotes = NewNumBallotBox(data.Data[0].Length())
 		} else {
@@ -284,29 +430,83 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 
 	//****** Set up Target for Alternative Impurity  if needed *******//
 	var target Target
-	if o.density {
+	if o.Density {
 		fmt.Println("Estimating Density.")
 		target = &DensityTarget{&data.Data, nSamples}
 	} else {
 
 		switch targetf.(type) {
 
+		case *MultiTarget:
+			fmt.Println("Performing multivariate regression/classification.")
+			target = targetf
+
 		case NumFeature:
+			if survivalEvent != nil {
+				fmt.Println("Performing survival analysis with log-rank splitting.")
+				targetf = NewSurvivalTarget(targetf.(NumFeature), survivalEvent)
+				target = targetf
+				break
+			}
 			fmt.Println("Performing regression.")
-			if o.l1 {
+			if o.L1 {
 				fmt.Println("Using l1/absolute deviance error.")
 				targetf = &L1Target{targetf.(NumFeature)}
 			}
-			if o.ordinal {
+			if o.Ordinal {
 				fmt.Println("Using Ordinal (mode) prediction.")
 				targetf = NewOrdinalTarget(targetf.(NumFeature))
 			}
 			switch {
-			case o.gradboost != 0.0:
+			case o.Poisson:
+				fmt.Println("Using Poisson deviance.")
+				targetf = &PoissonTarget{targetf.(NumFeature)}
+			case o.Gamma:
+				fmt.Println("Using Gamma deviance.")
+				targetf = &GammaTarget{targetf.(NumFeature)}
+			case o.Tweedie != 0.0:
+				fmt.Printf("Using Tweedie deviance with power %v.\n", o.Tweedie)
+				if o.Tweedie <= 1.0 || o.Tweedie >= 2.0 {
+					log.Fatal("Tweedie power must be between 1 and 2.")
+				}
+				targetf = &TweedieTarget{targetf.(NumFeature), o.Tweedie}
+			case o.Huber != 0.0:
+				fmt.Printf("Using Huber loss with delta %v.\n", o.Huber)
+				if o.Huber < 0.0 {
+					log.Fatal("Huber delta must be positive.")
+				}
+				targetf = &HuberTarget{targetf.(NumFeature), o.Huber}
+			case o.Quantile != 0.0:
+				fmt.Printf("Using quantile loss with alpha %v.\n", o.Quantile)
+				if o.Quantile < 0.0 || o.Quantile >= 1.0 {
+					log.Fatal("Quantile alpha must be between 0 and 1.")
+				}
+				targetf = &QuantileTarget{targetf.(NumFeature), o.Quantile}
+			}
+			if i := CheckDevianceTarget(targetf.(NumFeature)); i >= 0 {
+				log.Fatalf("Target value %v of case %v is outside the support of the deviance.", targetf.GetStr(i), data.CaseLabels[i])
+			}
+			switch {
+			case o.GradBoost != 0.0:
 				fmt.Println("Using Gradiant Boosting.")
-				targetf = &GradBoostTarget{targetf.(NumFeature), o.gradboost}
+				loss := TargetLoss(targetf.(NumFeature))
+				if loss == nil {
+					log.Fatal("Gradiant boosting isn't supported for deviance targets.")
+				}
+				targetf = NewGradBoostTarget(targetf.(NumFeature), loss, o.GradBoost)
+				if o.Newton {
+					fmt.Printf("Using Newton boosting with lambda %v, alpha %v, min split loss %v and min child weight %v.\n", o.Lambda, o.Alpha, o.MinSplitLoss, o.MinChildWeight)
+					if o.MultiBoost {
+						log.Fatal("Multiboost isn't supported for Newton boosting.")
+					}
+					nbt, err := NewNewtonBoostTarget(targetf.(*GradBoostTarget), o.Lambda, o.Alpha, o.MinSplitLoss, o.MinChildWeight)
+					if err != nil {
+						log.Fatal(err)
+					}
+					targetf = nbt
+				}
 
-			case o.adaboost:
+			case o.AdaBoost:
 				fmt.Println("Using Numeric Adaptive Boosting.")
 				//BUG(ryan): gradiant boostign should expose learning rate.
 				targetf = NewNumAdaBoostTarget(targetf.(NumFeature))
@@ -316,10 +516,10 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 		case CatFeature:
 			fmt.Println("Performing classification.")
 			switch {
-			case *o.costs != "":
-				fmt.Println("Using missclasification costs: ", *o.costs)
+			case o.Costs != "":
+				fmt.Println("Using missclasification costs: ", o.Costs)
 				costmap := make(map[string]float64)
-				err := json.Unmarshal([]byte(*o.costs), &costmap)
+				err := json.Unmarshal([]byte(o.Costs), &costmap)
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -327,10 +527,10 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 				regTarg := NewRegretTarget(targetf.(CatFeature))
 				regTarg.SetCosts(costmap)
 				targetf = regTarg
-			case *o.rfweights != "":
-				fmt.Println("Using rf weights: ", *o.rfweights)
+			case o.RfWeights != "":
+				fmt.Println("Using rf weights: ", o.RfWeights)
 				weightmap := make(map[string]float64)
-				err := json.Unmarshal([]byte(*o.rfweights), &weightmap)
+				err := json.Unmarshal([]byte(o.RfWeights), &weightmap)
 				if err != nil {
 					log.Fatal(err)
 				}
@@ -338,10 +538,20 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 				wrfTarget := NewWRFTarget(targetf.(CatFeature), weightmap)
 				targetf = wrfTarget
 
-			case o.entropy:
+			case o.Entropy:
 				fmt.Println("Using entropy minimization.")
 				targetf = &EntropyTarget{targetf.(CatFeature)}
 
+			case o.GradBoost != 0.0:
+				fmt.Println("Using Gradiant Boosting with the logistic/softmax loss.")
+				if o.MultiBoost {
+					log.Fatal("Multiboost isn't supported for gradiant boosted classification.")
+				}
+				if o.Newton {
+					log.Fatal("Newton boosting is only supported for numerical targets.")
+				}
+				targetf = NewGradBoostClassTarget(targetf.(CatFeature), o.GradBoost)
+
 			case boost:
 
 				fmt.Println("Using Adaptive Boosting.")
@@ -353,13 +563,75 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 		}
 	}
 
+	tbt, treeBoost := target.(TreeBoostingTarget)
+	gbt, gradBoost := target.(*GradBoostTarget)
+	if nbt, ok := target.(*NewtonBoostTarget); ok {
+		gbt, gradBoost = nbt.GradBoostTarget, true
+	}
+	gbct, gradBoostClass := target.(*GradBoostClassTarget)
+	//one leaf trees with the initial score of each class for boosted classification
+	var interceptTrees []*Tree
+	switch {
+	case gradBoost:
+		fmt.Printf("Initial prediction : %v\n", gbt.Intercept)
+		if forestwriter != nil {
+			forestwriter.WriteForestHeader(GBTForest, treeTarget, o.NTrees, gbt.Intercept)
+		}
+		if o.OOB {
+			//boosted trees depend on each other so only the whole model can be evaluated
+			fmt.Println("Recording training error in place of oob error for gradiant boosting.")
+			oobVotes = NewSumBallotBox(data.Data[0].Length(), gbt.Intercept)
+		}
+	case gradBoostClass:
+		o.NTrees *= gbct.TreesPerIteration()
+		fmt.Printf("Growing %v trees per iteration for %v trees in total.\n", gbct.TreesPerIteration(), o.NTrees)
+		interceptTrees = gbct.InterceptTrees()
+		if forestwriter != nil {
+			forestwriter.WriteForestHeader(GBTClassForest, treeTarget, len(interceptTrees)+o.NTrees, 0.0)
+			for i, tree := range interceptTrees {
+				forestwriter.WriteTree(tree, i)
+			}
+		}
+		if o.OOB {
+			fmt.Println("Recording training error in place of oob error for gradiant boosting.")
+			oobVotes = NewSoftmaxBallotBox(data.Data[0].Length())
+			for _, tree := range interceptTrees {
+				tree.Vote(data, oobVotes)
+			}
+		}
+	}
+
+	//trees grown against the same row subsample in each boosting iteration
+	treesPerIteration := 1
+	if gradBoostClass {
+		treesPerIteration = gbct.TreesPerIteration()
+	}
+	if o.Subsample > 0.0 {
+		fmt.Printf("Subsampling %v of the cases without replacement for each iteration.\n", o.Subsample)
+	}
+	colSampleLevel := o.ColSampleLevel > 0.0 || o.ColSampleNode > 0.0
+	if o.ColSampleTree > 0.0 || colSampleLevel {
+		fmt.Printf("Sampling features by tree %v, level %v and node %v.\n", o.ColSampleTree, o.ColSampleLevel, o.ColSampleNode)
+	}
+	lt, lossTarget := target.(LossTarget)
+	var oobImprovement []float64
+	if o.OOBImprove != "" {
+		if !lossTarget {
+			log.Fatal("Out of bag improvement can only be tracked for gradiant boosting.")
+		}
+		fmt.Printf("Recording out of bag improvement by iteration to %v\n", o.OOBImprove)
+	}
+
 	//****************** Needed Collections and vars ******************//
 	var trees []*Tree
-	trees = make([]*Tree, 0, o.nTrees)
+	trees = make([]*Tree, 0, o.NTrees)
+	if o.DoTest {
+		trees = append(trees, interceptTrees...)
+	}
 
 	var imppnt *[]*RunningMean
 	var mmdpnt *[]*RunningMean
-	if *o.imp != "" {
+	if o.Imp != "" {
 		fmt.Println("Recording Importance Scores.")
 
 		imppnt = NewRunningMeans(len(data.Data))
@@ -368,9 +640,82 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 
 	treechan := make(chan *Tree, 0)
 
+	var inbagfile *os.File
+	var bagMutex sync.Mutex
+	bags := make(map[*Tree][]int)
+	oobs := make(map[*Tree][]int)
+	if o.InBag != "" {
+		fmt.Printf("Recording in bag cases to %v\n", o.InBag)
+		var err error
+		inbagfile, err = os.Create(o.InBag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer inbagfile.Close()
+	}
+
+	if o.Bins > 0 {
+		if o.Bins > MaxBins {
+			o.Bins = MaxBins
+		}
+		fmt.Printf("Binning numerical features into at most %v bins.\n", o.Bins)
+		tobin := make([]int, 0, len(data.Data))
+		for i := 0; i < len(data.Data); i++ {
+			if i != targeti && !blacklistis[i] {
+				tobin = append(tobin, i)
+			}
+		}
+		data.BinNumFeatures(o.Bins, tobin)
+	}
+
+	var presorted *PresortedIndex
+	if o.Presort {
+		fmt.Println("Presorting numerical features.")
+		tosort := make([]int, 0, len(data.Data))
+		for i := 0; i < len(data.Data); i++ {
+			if i != targeti && !blacklistis[i] {
+				tosort = append(tosort, i)
+			}
+		}
+		presorted = NewPresortedIndex(data, tosort)
+	}
+
+	if o.Oblique > 0 {
+		fmt.Printf("Searching %v oblique splits on combinations of %v numerical features at each node.\n", o.Oblique, o.ObliqueFeatures)
+	}
+
+	if o.Multiway > 0 {
+		fmt.Printf("Splitting categorical features with at most %v categories into one branch per category.\n", o.Multiway)
+	}
+
+	var monotone *MonotoneConstraints
+	if o.Monotone != "" {
+		fmt.Println("Using monotone constraints: ", o.Monotone)
+		if _, ok := data.Data[targeti].(NumFeature); !ok {
+			log.Fatal("Monotone constraints require a numerical target.")
+		}
+		switch target.(type) {
+		case *SurvivalTarget:
+			//predictions are cumulative hazard functions that can't be clipped to bounds
+			log.Fatal("Monotone constraints aren't supported for survival analysis.")
+		case NumFeature:
+		default:
+			log.Fatal("Monotone constraints aren't supported for this kind of regression.")
+		}
+		directions := make(map[string]int)
+		err := json.Unmarshal([]byte(o.Monotone), &directions)
+		if err != nil {
+			log.Fatal(err)
+		}
+		monotone, err = NewMonotoneConstraints(data, directions)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	//****************** Good Stuff Stars Here ;) ******************//
 	trainingStart := time.Now()
-	for core := 0; core < o.nCores; core++ {
+	for core := 0; core < o.NCores; core++ {
 		go func() {
 			weight := -1.0
 			canidates := make([]int, 0, len(data.Data))
@@ -380,11 +725,11 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 				}
 			}
 			tree := NewTree()
-			tree.Target = *targetname
+			tree.Target = treeTarget
 			cases := make([]int, 0, nSamples)
 			oobcases := make([]int, 0, nSamples)
 
-			if o.nobag {
+			if o.NoBag {
 				for i := 0; i < nSamples; i++ {
 					if !targetf.IsMissing(i) {
 						cases = append(cases, i)
@@ -392,6 +737,18 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 				}
 			}
 
+			//cases to subsample without replacement and features for column sampling
+			var nonmissing []int
+			if o.Subsample > 0.0 {
+				for i := 0; i < targetf.Length(); i++ {
+					if !targetf.IsMissing(i) {
+						nonmissing = append(nonmissing, i)
+					}
+				}
+			}
+			treecans := make([]int, 0, len(canidates))
+			ngrown := 0
+
 			var depthUsed *[]int
 			if mmdpnt != nil {
 				du := make([]int, len(data.Data))
@@ -399,13 +756,39 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 			}
 
 			allocs := NewBestSplitAllocs(nSamples, targetf)
+			if presorted != nil {
+				allocs.Presort = NewPresortedCases(data, presorted)
+			}
+			allocs.ExtraTrees = o.ExtraTrees
+			allocs.Multiway = o.Multiway
+			obliquecans := canidates
+			if monotone != nil {
+				allocs.Monotone = monotone.Copy()
+				//oblique splits can't respect the constraints so they don't use constrained features
+				obliquecans = make([]int, 0, len(canidates))
+				for _, i := range canidates {
+					if monotone.Direction(i) == 0 {
+						obliquecans = append(obliquecans, i)
+					}
+				}
+			}
+			if o.Oblique > 0 {
+				allocs.Oblique = NewObliqueSplits(data, obliquecans, o.Oblique, o.ObliqueFeatures)
+			}
 			for {
 				nCases := data.Data[0].Length()
-				//sample nCases case with replacement
-				if !o.nobag {
+				if o.Subsample > 0.0 {
+					//one subsample for all of the trees of a boosting iteration
+					if ngrown%treesPerIteration == 0 {
+						n := sampleCount(o.Subsample, len(nonmissing))
+						SampleFirstN(&nonmissing, nil, n, 0)
+						cases = append(cases[0:0], nonmissing[:n]...)
+					}
+				} else if !o.NoBag {
+					//sample nCases case with replacement
 					cases = cases[0:0]
 
-					if o.balance {
+					if o.Balance {
 						bSampler.Sample(&cases, nSamples)
 
 					} else {
@@ -419,7 +802,7 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 
 				}
 
-				if o.nobag && nSamples != nCases {
+				if o.NoBag && o.Subsample <= 0.0 && nSamples != nCases {
 					cases = cases[0:0]
 					for i := 0; i < nSamples; i++ {
 						if !targetf.IsMissing(i) {
@@ -429,7 +812,7 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 					SampleFirstN(&cases, nil, nCases, 0)
 				}
 
-				if o.oob || o.evaloob {
+				if o.OOB || o.EvalOOB || o.OOBImprove != "" {
 					ibcases := make([]bool, nCases)
 					for _, v := range cases {
 						ibcases[v] = true
@@ -442,7 +825,30 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 					}
 				}
 
-				tree.Grow(data, target, cases, canidates, oobcases, mTry, leafSize, o.splitmissing, o.force, o.vet, o.evaloob, imppnt, depthUsed, allocs)
+				growTarget := target
+				if gradBoost && o.MultiBoost {
+					//grow against a snapshot of the residuals as other trees are boosted
+					boostMutex.Lock()
+					growTarget = gbt.Residuals()
+					boostMutex.Unlock()
+				}
+
+				treecans = append(treecans[0:0], canidates...)
+				if o.ColSampleTree > 0.0 {
+					n := sampleCount(o.ColSampleTree, len(treecans))
+					SampleFirstN(&treecans, nil, n, 0)
+					treecans = treecans[:n]
+				}
+				if colSampleLevel {
+					tree.GrowLevelSampled(data, growTarget, cases, treecans, oobcases, o.ColSampleLevel, o.ColSampleNode, leafSize, o.SplitMissing, o.Force, o.Vet, o.EvalOOB, imppnt, depthUsed, allocs)
+				} else {
+					treemTry := mTry
+					if treemTry > len(treecans) {
+						treemTry = len(treecans)
+					}
+					tree.Grow(data, growTarget, cases, treecans, oobcases, treemTry, leafSize, o.SplitMissing, o.Force, o.Vet, o.EvalOOB, imppnt, depthUsed, allocs)
+				}
+				ngrown++
 
 				if mmdpnt != nil {
 					for i, v := range *depthUsed {
@@ -454,7 +860,7 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 					}
 				}
 
-				if boost {
+				if boost && !treeBoost {
 					boostMutex.Lock()
 					weight = targetf.(BoostingTarget).Boost(tree.Partition(data))
 					boostMutex.Unlock()
@@ -467,10 +873,22 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 					tree.Weight = weight
 				}
 
-				if o.oob {
+				if o.OOB && !treeBoost {
 					tree.VoteCases(data, oobVotes, oobcases)
 				}
 
+				if inbagfile != nil || treeBoost {
+					bagMutex.Lock()
+					bags[tree] = append([]int(nil), cases...)
+					bagMutex.Unlock()
+				}
+
+				if o.OOBImprove != "" {
+					bagMutex.Lock()
+					oobs[tree] = append([]int(nil), oobcases...)
+					bagMutex.Unlock()
+				}
+
 				treechan <- tree
 				tree = <-treechan
 			}
@@ -478,28 +896,62 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 
 	}
 
-	for i := 0; i < o.nTrees; i++ {
+	for i := 0; i < o.NTrees; i++ {
 		tree := <-treechan
 		if tree == nil {
 			break
 		}
+		bagMutex.Lock()
+		inbag := bags[tree]
+		delete(bags, tree)
+		bagMutex.Unlock()
+		if treeBoost {
+			//boost here so only trees that make it into the forest update the predictions
+			//leaf steps are fit on the in bag cases so the out of bag improvement is honest
+			boostMutex.Lock()
+			if o.OOBImprove != "" {
+				bagMutex.Lock()
+				oob := oobs[tree]
+				delete(oobs, tree)
+				bagMutex.Unlock()
+				before := lt.MeanLoss(oob)
+				tree.Weight = tbt.BoostTree(tree, data, inbag)
+				improvement := before - lt.MeanLoss(oob)
+				if i%treesPerIteration == 0 {
+					oobImprovement = append(oobImprovement, improvement)
+				} else {
+					oobImprovement[len(oobImprovement)-1] += improvement
+				}
+			} else {
+				tree.Weight = tbt.BoostTree(tree, data, inbag)
+			}
+			boostMutex.Unlock()
+			if o.OOB {
+				tree.Vote(data, oobVotes)
+			}
+		}
 		if forestwriter != nil {
-			forestwriter.WriteTree(tree, i)
+			forestwriter.WriteTree(tree, len(interceptTrees)+i)
+		}
+		if inbagfile != nil {
+			if err := WriteInBag(inbagfile, inbag, data.Data[0].Length()); err != nil {
+				log.Fatal(err)
+			}
 		}
 
-		if o.dotest {
+		if o.DoTest || o.Unsupervised != "" {
 			trees = append(trees, tree)
 
-			if i < o.nTrees-1 {
+			if i < o.NTrees-1 {
 				//newtree := new(Tree)
 				treechan <- NewTree()
 			}
 		} else {
-			if i < o.nTrees-1 {
+			if i < o.NTrees-1 {
 				treechan <- tree
 			}
 		}
-		if o.progress {
+		if o.Progress {
 			fmt.Printf("Model oob error after tree %v : %v\n", i, oobVotes.TallyError(unboostedTarget))
 		}
 
@@ -507,12 +959,57 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 
 	trainingEnd := time.Now()
 	fmt.Printf("Training model took %v.\n", trainingEnd.Sub(trainingStart))
+	if gradBoost {
+		fmt.Printf("Training loss : %v\n", gbt.TrainingLoss())
+	}
+	if gradBoostClass {
+		fmt.Printf("Training loss : %v\n", gbct.TrainingLoss())
+	}
+	if o.OOBImprove != "" {
+		improvefile, err := os.Create(o.OOBImprove)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = WriteOOBImprovement(improvefile, oobImprovement)
+		improvefile.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		best := BestIteration(oobImprovement)
+		fmt.Printf("Best number of iterations by out of bag improvement : %v (%v trees)\n", best, best*treesPerIteration)
+	}
 
-	if o.oob {
+	if o.OOB {
 		fmt.Printf("Out of Bag Error : %v\n", oobVotes.TallyError(unboostedTarget))
+		if mbb, ok := oobVotes.(*MultiBallotBox); ok {
+			fmt.Printf("Out of Bag Error by Target : %v\n", mbb.TallyErrors(unboostedTarget))
+		}
 	}
-	if o.caseoob != "" {
-		caseoobfile, err := os.Create(o.caseoob)
+	if o.Unsupervised != "" {
+		fmt.Printf("Outputting proximities between real cases to %v\n", o.Unsupervised)
+		real := make([]int, 0, nReal)
+		for i := 0; i < nReal; i++ {
+			real = append(real, i)
+		}
+		leaves := NewLeafIndex(&Forest{*targetname, trees, "", 0.0}, data)
+		prox := Proximity(leaves, leaves, nil, nil, real, o.NCores)
+		proxfile, err := os.Create(o.Unsupervised)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer proxfile.Close()
+		for k, i := range prox.Rows {
+			cols, vals := prox.Row(k)
+			for n, j := range cols {
+				if j < nReal {
+					fmt.Fprintf(proxfile, "%v\t%v\t%v\n", data.CaseLabels[i], data.CaseLabels[j], vals[n])
+				}
+			}
+		}
+	}
+
+	if o.CaseOOB != "" {
+		caseoobfile, err := os.Create(o.CaseOOB)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -522,8 +1019,8 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 		}
 	}
 
-	if *o.imp != "" {
-		impfile, err := os.Create(*o.imp)
+	if o.Imp != "" {
+		impfile, err := os.Create(o.Imp)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -531,27 +1028,43 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 		for i, v := range *imppnt {
 			mean, count := v.Read()
 			meanMinDepth, treeCount := (*mmdpnt)[i].Read()
-			fmt.Fprintf(impfile, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", data.Data[i].GetName(), mean, count, mean*float64(count)/float64(o.nTrees), mean*float64(count)/float64(treeCount), treeCount, meanMinDepth)
+			fmt.Fprintf(impfile, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", data.Data[i].GetName(), mean, count, mean*float64(count)/float64(o.NTrees), mean*float64(count)/float64(treeCount), treeCount, meanMinDepth)
 
 		}
 	}
 
-	if o.dotest {
+	if o.DoTest {
 		var bb VoteTallyer
 
 		testdata := data
 		testtarget := unboostedTarget
-		if o.testfm != "" {
+		testevent := survivalEvent
+		if o.TestFm != "" {
 			var err error
-			testdata, err = LoadAFM(o.testfm)
+			testdata, err = LoadAFM(o.TestFm)
 			if err != nil {
 				log.Fatal(err)
 			}
-			targeti, ok = testdata.Map[*targetname]
-			if !ok {
-				log.Fatal("Target not found in test data.")
+			if names := ParseMultiTargetName(*targetname); names != nil {
+				mt, err := NewMultiTarget(testdata, names, false)
+				if err != nil {
+					log.Fatal(err)
+				}
+				testtarget = mt
+			} else {
+				targeti, ok = testdata.Map[*targetname]
+				if !ok {
+					log.Fatal("Target not found in test data.")
+				}
+				testtarget = testdata.Data[targeti]
+			}
+			if survivalEvent != nil {
+				eventi, ok := testdata.Map[o.Survival]
+				if !ok {
+					log.Fatal("Survival event feature not found in test data.")
+				}
+				testevent = testdata.Data[eventi]
 			}
-			testtarget = testdata.Data[targeti]
 
 			for _, tree := range trees {
 				// tree.Root.Climb(func(n *Node) {
@@ -578,7 +1091,16 @@ func Grow(data *FeatureMatrix, forestwriter *ForestWriter, targetname *string, o
 			}
 		}
 
-		if unboostedTarget.NCats() == 0 {
+		if testevent != nil {
+			events, _ := ParseEvents(testevent)
+			bb = NewSurvivalBallotBox(events)
+		} else if mt, ok := testtarget.(*MultiTarget); ok {
+			bb = NewMultiBallotBox(testdata.Data[0].Length(), mt.Targets)
+		} else if gradBoost {
+			bb = NewSumBallotBox(testdata.Data[0].Length(), gbt.Intercept)
+		} else if gradBoostClass {
+			bb = NewSoftmaxBallotBox(testdata.Data[0].Length())
+		} else if unboostedTarget.NCats() == 0 {
 			//regression
 			bb = NewNumBallotBox(testdata.Data[0].Length())
 		} else {
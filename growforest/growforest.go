@@ -3,7 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
-	".."
+	"github.com/ryanbressler/CloudForest"
 	"log"
 	"os"
 )
@@ -14,133 +14,160 @@ func main() {
 	rf := flag.String("rfpred",
 		"", "File name to output predictor forest in sf format.")
 	targetname := flag.String("target",
-		"", "The row header of the target in the feature matrix.")
-	
-	//Parse Data
-	fmt.Printf("Loading data from: %v\n", *fm)
-	data, err := CloudForest.LoadAFM(*fm)
-	if err != nil {
-		log.Fatal(err)
-	}
+		"", "The row header of the target in the feature matrix. A comma seperated list of row headers grows a multivariate forest predicting all of them.")
 
-	var forestwriter *CloudForest.ForestWriter
-	if *rf != "" {
-		forestfile, err := os.Create(*rf)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer forestfile.Close()
-		forestwriter = CloudForest.NewForestWriter(forestfile)
-	}
-/*
+	var o CloudForest.GrowOpts
+	o.SetDefaults()
 
-	imp := flag.String("importance",
+	flag.StringVar(&o.Imp, "importance",
 		"", "File name to output importance.")
-	costs := flag.String("cost",
+	flag.StringVar(&o.Costs, "cost",
 		"", "For categorical targets, a json string to float map of the cost of falsely identifying each category.")
 
-	rfweights := flag.String("rfweights",
+	flag.StringVar(&o.RfWeights, "rfweights",
 		"", "For categorical targets, a json string to float map of the weights to use for each category in Weighted RF.")
 
-	blacklist := flag.String("blacklist",
+	flag.StringVar(&o.Blacklist, "blacklist",
 		"", "A list of feature id's to exclude from the set of predictors.")
 
-	var nCores int
-	flag.IntVar(&nCores, "nCores", 1, "The number of cores to use.")
+	flag.IntVar(&o.NCores, "nCores", 1, "The number of cores to use.")
+
+	flag.StringVar(&o.StringnSamples, "nSamples", "0", "The number of cases to sample (with replacement) for each tree as a count (ex: 10) or portion of total (ex: .5). If <=0 set to total number of cases.")
+
+	flag.StringVar(&o.StringmTry, "mTry", "0", "Number of candidate features for each split as a count (ex: 10) or portion of total (ex: .5). Ceil(sqrt(nFeatures)) if <=0.")
+
+	flag.StringVar(&o.StringleafSize, "leafSize", "0", "The minimum number of cases on a leaf node. If <=0 will be inferred to 1 for classification 4 for regression.")
+
+	flag.StringVar(&o.ShuffleRE, "shuffleRE", "", "A regular expression to identify features that should be shuffled.")
+
+	flag.StringVar(&o.BlockRE, "blockRE", "", "A regular expression to identify features that should be filtered out.")
+
+	flag.StringVar(&o.IncludeRE, "includeRE", "", "Filter features that DON'T match this RE.")
+
+	flag.IntVar(&o.NTrees, "nTrees", 100, "Number of trees to grow in the predictor.")
+
+	flag.IntVar(&o.NContrasts, "nContrasts", 0, "The number of randomized artificial contrast features to include in the feature matrix.")
+
+	flag.StringVar(&o.CpuProfile, "cpuprofile", "", "write cpu profile to file")
+
+	flag.BoolVar(&o.ContrastAll, "contrastall", false, "Include a shuffled artificial contrast copy of every feature.")
+
+	flag.IntVar(&o.Bins, "bins", 0, "Quantize numerical features into at most this many bins (up to 255) and search splits with histograms.")
+
+	flag.BoolVar(&o.Presort, "presort", false, "Sort numerical features once before growth and keep the sorted cases partitioned as trees grow instead of sorting at each node.")
+
+	flag.BoolVar(&o.ExtraTrees, "extratrees", false, "Grow extremely randomized trees by drawing one random split for each of mTry features at each node instead of searching for the best.")
+
+	flag.IntVar(&o.Oblique, "oblique", 0, "The number of random linear combinations of numerical features to try as oblique splits at each node (in addition to mTry features).")
+
+	flag.IntVar(&o.ObliqueFeatures, "obliquefeatures", 2, "The number of numerical features in each oblique split's linear combination.")
+
+	flag.IntVar(&o.Multiway, "multiway", 0, "Split categorical features with at most this many categories into one branch per category (C4.5 style, chosen by gain ratio).")
 
-	var StringnSamples string
-	flag.StringVar(&StringnSamples, "nSamples", "0", "The number of cases to sample (with replacement) for each tree as a count (ex: 10) or portion of total (ex: .5). If <=0 set to total number of cases.")
+	flag.StringVar(&o.Monotone, "monotone", "", "For numerical targets, a json string to int map of features predictions should be increasing (1) or decreasing (-1) in.")
 
-	var StringmTry string
-	flag.StringVar(&StringmTry, "mTry", "0", "Number of candidate features for each split as a count (ex: 10) or portion of total (ex: .5). Ceil(sqrt(nFeatures)) if <=0.")
+	flag.BoolVar(&o.Impute, "impute", false, "Impute missing values to feature mean/mode before growth.")
 
-	var StringleafSize string
-	flag.StringVar(&StringleafSize, "leafSize", "0", "The minimum number of cases on a leaf node. If <=0 will be inferred to 1 for classification 4 for regression.")
+	flag.IntVar(&o.RfImpute, "rfimpute", 0, "Impute missing values using proximities from this many iterations of forest growth before growth (not with -impute).")
 
-	var shuffleRE string
-	flag.StringVar(&shuffleRE, "shuffleRE", "", "A regular expression to identify features that should be shuffled.")
+	flag.BoolVar(&o.SplitMissing, "splitmissing", false, "Split missing values onto a third branch at each node (experimental).")
 
-	var blockRE string
-	flag.StringVar(&blockRE, "blockRE", "", "A regular expression to identify features that should be filtered out.")
+	flag.BoolVar(&o.L1, "l1", false, "Use l1 norm regression (target must be numeric).")
 
-	var includeRE string
-	flag.StringVar(&includeRE, "includeRE", "", "Filter features that DON'T match this RE.")
+	flag.BoolVar(&o.Poisson, "poisson", false, "Use Poisson deviance regression (target must be non negative counts).")
 
-	var nTrees int
-	flag.IntVar(&nTrees, "nTrees", 100, "Number of trees to grow in the predictor.")
+	flag.BoolVar(&o.Gamma, "gamma", false, "Use Gamma deviance regression (target must be positive).")
 
-	var nContrasts int
-	flag.IntVar(&nContrasts, "nContrasts", 0, "The number of randomized artificial contrast features to include in the feature matrix.")
+	flag.Float64Var(&o.Tweedie, "tweedie", 0.0, "Use Tweedie deviance regression with the specified power between 1 and 2 (target must be non negative).")
 
-	var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
+	flag.Float64Var(&o.Huber, "huber", 0.0, "Use Huber loss regression with the specified delta.")
 
-	var contrastAll bool
-	flag.BoolVar(&contrastAll, "contrastall", false, "Include a shuffled artificial contrast copy of every feature.")
+	flag.Float64Var(&o.Quantile, "quantile", 0.0, "Use quantile (pinball) loss regression predicting the specified quantile between 0 and 1.")
 
-	var impute bool
-	flag.BoolVar(&impute, "impute", false, "Impute missing values to feature mean/mode before growth.")
+	flag.BoolVar(&o.ScaleTargets, "scaletargets", false, "Scale the impurity of each of multiple targets by its impurity over all cases.")
 
-	var splitmissing bool
-	flag.BoolVar(&splitmissing, "splitmissing", false, "Split missing values onto a third branch at each node (experimental).")
+	flag.BoolVar(&o.Density, "density", false, "Build density estimating trees instead of classifcation/regression trees.")
 
-	var l1 bool
-	flag.BoolVar(&l1, "l1", false, "Use l1 norm regression (target must be numeric).")
+	flag.StringVar(&o.Unsupervised, "unsupervised", "", "Grow an unsupervised forest to distinguish real from synthetic cases and output proximities between real cases to this file (no target needed).")
 
-	var density bool
-	flag.BoolVar(&density, "density", false, "Build density estimating trees instead of classifcation/regression trees.")
+	flag.BoolVar(&o.Isolation, "isolation", false, "Build isolation trees for anomaly detection (no target needed). nSamples defaults to 256.")
 
-	var vet bool
-	flag.BoolVar(&vet, "vet", false, "Penalize potential splitter impurity decrease by subtracting the best split of a permuted target.")
+	flag.StringVar(&o.Survival, "survival", "", "Grow a random survival forest using the target as the time to event and this feature as the event indicator (0/1). Splits maximize the log-rank statistic and oob error is 1 - concordance.")
 
-	var evaloob bool
-	flag.BoolVar(&evaloob, "evaloob", false, "Evaluate potential splitting features on OOB cases after finding split value in bag.")
+	flag.BoolVar(&o.Vet, "vet", false, "Penalize potential splitter impurity decrease by subtracting the best split of a permuted target.")
 
-	var force bool
-	flag.BoolVar(&force, "force", false, "Force at least one non constant feature to be tested for each split.")
+	flag.BoolVar(&o.EvalOOB, "evaloob", false, "Evaluate potential splitting features on OOB cases after finding split value in bag.")
 
-	var entropy bool
-	flag.BoolVar(&entropy, "entropy", false, "Use entropy minimizing classification (target must be categorical).")
+	flag.BoolVar(&o.Force, "force", false, "Force at least one non constant feature to be tested for each split.")
 
-	var oob bool
-	flag.BoolVar(&oob, "oob", false, "Calculate and report oob error.")
+	flag.BoolVar(&o.Entropy, "entropy", false, "Use entropy minimizing classification (target must be categorical).")
 
-	var caseoob string
-	flag.StringVar(&caseoob, "oobpreds", "", "Calculate and report oob predictions in the file specified.")
+	flag.BoolVar(&o.OOB, "oob", false, "Calculate and report oob error.")
 
-	var progress bool
-	flag.BoolVar(&progress, "progress", false, "Report tree number and running oob error.")
+	flag.StringVar(&o.CaseOOB, "oobpreds", "", "Calculate and report oob predictions in the file specified.")
 
-	var adaboost bool
-	flag.BoolVar(&adaboost, "adaboost", false, "Use Adaptive boosting for regression/classification.")
+	flag.BoolVar(&o.Progress, "progress", false, "Report tree number and running oob error.")
 
-	var gradboost float64
-	flag.Float64Var(&gradboost, "gbt", 0.0, "Use gradiant boosting with the specified learning rate.")
+	flag.BoolVar(&o.AdaBoost, "adaboost", false, "Use Adaptive boosting for regression/classification.")
 
-	var multiboost bool
-	flag.BoolVar(&multiboost, "multiboost", false, "Allow multithreaded boosting which may have unexpected results. (highly experimental)")
+	flag.Float64Var(&o.GradBoost, "gbt", 0.0, "Use gradiant boosting with the specified learning rate (logistic/softmax loss for categorical targets).")
 
-	var nobag bool
-	flag.BoolVar(&nobag, "nobag", false, "Don't bag samples for each tree.")
+	flag.BoolVar(&o.Newton, "newton", false, "Use second order (Newton) gradiant boosting with regularized leaves (requires -gbt and a numeric target).")
 
-	var balance bool
-	flag.BoolVar(&balance, "balance", false, "Balance bagging of samples by target class for unbalanced classification.")
+	flag.Float64Var(&o.Lambda, "lambda", 1.0, "The L2 penalty on leaf values for Newton boosting (must be positive).")
 
-	var balanceby string
-	flag.StringVar(&balanceby, "balanceby", "", "Roughly balanced bag the target within each class of this feature.")
+	flag.Float64Var(&o.Alpha, "alpha", 0.0, "The L1 penalty on leaf values for Newton boosting.")
 
-	var ordinal bool
-	flag.BoolVar(&ordinal, "ordinal", false, "Use ordinal regression (target must be numeric).")
+	flag.Float64Var(&o.MinSplitLoss, "minsplitloss", 0.0, "The minimum loss reduction needed to split a node in Newton boosting.")
 
-	var permutate bool
-	flag.BoolVar(&permutate, "permute", false, "Permute the target feature (to establish random predictive power).")
+	flag.Float64Var(&o.MinChildWeight, "minchildweight", 0.0, "The minimum sum of hessians on each side of a split in Newton boosting. If >0 leafSize defaults to 1.")
 
-	var dotest bool
-	flag.BoolVar(&dotest, "selftest", false, "Test the forest on the data and report accuracy.")
+	flag.Float64Var(&o.Subsample, "subsample", 0.0, "Grow each tree (each iteration when boosting) on this fraction of the cases sampled without replacement instead of bagging.")
 
-	var testfm string
-	flag.StringVar(&testfm, "test", "", "Data to test the model on.")
+	flag.Float64Var(&o.ColSampleTree, "colsampletree", 0.0, "The fraction of features sampled for each tree.")
+
+	flag.Float64Var(&o.ColSampleLevel, "colsamplelevel", 0.0, "The fraction of each tree's features sampled for each depth of the tree.")
+
+	flag.Float64Var(&o.ColSampleNode, "colsamplenode", 0.0, "The fraction of each depth's features tried at each node (in place of mTry).")
+
+	flag.StringVar(&o.OOBImprove, "oobimprove", "", "Write the out of bag improvement in loss made by each gradiant boosting iteration to this file and report the best number of iterations.")
+
+	flag.BoolVar(&o.MultiBoost, "multiboost", false, "Allow multithreaded boosting which may have unexpected results. (highly experimental)")
+
+	flag.BoolVar(&o.NoBag, "nobag", false, "Don't bag samples for each tree.")
+
+	flag.BoolVar(&o.Balance, "balance", false, "Balance bagging of samples by target class for unbalanced classification.")
+
+	flag.StringVar(&o.BalanceBy, "balanceby", "", "Roughly balanced bag the target within each class of this feature.")
+
+	flag.BoolVar(&o.Ordinal, "ordinal", false, "Use ordinal regression (target must be numeric).")
+
+	flag.BoolVar(&o.Permutate, "permute", false, "Permute the target feature (to establish random predictive power).")
+
+	flag.BoolVar(&o.DoTest, "selftest", false, "Test the forest on the data and report accuracy.")
+
+	flag.StringVar(&o.TestFm, "test", "", "Data to test the model on.")
+
+	flag.StringVar(&o.InBag, "inbag", "", "File name to output the in bag count of each case for each tree.")
 
 	flag.Parse()
-*/	
-	CloudForest.Grow(data, forestwriter, targetname)
+
+	//Parse Data
+	fmt.Printf("Loading data from: %v\n", *fm)
+	data, err := CloudForest.LoadAFM(*fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var forestwriter *CloudForest.ForestWriter
+	if *rf != "" {
+		forestfile, err := os.Create(*rf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer forestfile.Close()
+		forestwriter = CloudForest.NewForestWriter(forestfile)
+	}
+
+	CloudForest.Grow(data, forestwriter, targetname, o)
 }
@@ -0,0 +1,87 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+HuberTarget wraps a numerical feature as a target for use in robust regression with the
+Huber loss which is squared error for residuals smaller then Delta and absolute error
+(scaled to be continuous) for larger residuals:
+
+	r^2/2 if |r| <= Delta else Delta*(|r| - Delta/2)
+
+Nodes predict a Huber M-estimate of location found by a single step from the median
+(as in Friedman's M-TreeBoost):
+
+	median + mean(sign(r)*min(Delta,|r|)) with r = y - median
+
+Gradient boosting a HuberTarget uses the matching HuberLoss (see TargetLoss).
+*/
+type HuberTarget struct {
+	NumFeature
+	Delta float64
+}
+
+/*
+HuberTarget.SplitImpurity is a Huber loss version of SplitImpurity.
+*/
+func (target *HuberTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	nl := float64(len(*l))
+	nr := float64(len(*r))
+	nm := 0.0
+
+	impurityDecrease = nl * target.Impurity(l, nil)
+	impurityDecrease += nr * target.Impurity(r, nil)
+	if m != nil && len(*m) > 0 {
+		nm = float64(len(*m))
+		impurityDecrease += nm * target.Impurity(m, nil)
+	}
+
+	impurityDecrease /= nl + nr + nm
+	return
+}
+
+//UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l as in learning from numerical variables.
+//Here it just wraps SplitImpurity but it can be implemented to provide further optimization.
+func (target *HuberTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	return target.SplitImpurity(l, r, m, allocs)
+}
+
+//HuberTarget.Impurity returns the mean Huber loss of the cases vs their Huber M-estimate.
+func (target *HuberTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	e = target.Error(cases, target.Predicted(cases))
+	return
+}
+
+//HuberTarget.Predicted returns the one step Huber M-estimate of the non missing cases.
+func (target *HuberTarget) Predicted(cases *[]int) float64 {
+	return huberEstimate(nonMissingValues(target, *cases), target.Delta)
+}
+
+//HuberTarget.Error returns the mean Huber loss of the cases specified vs the predicted
+//value. Only non missing cases are considered.
+func (target *HuberTarget) Error(cases *[]int, predicted float64) (e float64) {
+	n := 0
+	for _, i := range *cases {
+		if !target.IsMissing(i) {
+			r := math.Abs(target.Get(i) - predicted)
+			if r <= target.Delta {
+				e += r * r / 2.0
+			} else {
+				e += target.Delta * (r - target.Delta/2.0)
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		e /= float64(n)
+	}
+	return
+}
+
+//HuberTarget.FindPredicted returns the Huber M-estimate of the cases as a string.
+func (target *HuberTarget) FindPredicted(cases []int) (pred string) {
+	return fmt.Sprintf("%v", target.Predicted(&cases))
+}
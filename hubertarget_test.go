@@ -0,0 +1,31 @@
+package CloudForest
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestHuberTarget(t *testing.T) {
+	fm := ParseAFM(strings.NewReader(".\ta\tb\tc\td\te\nN:Y\t1\t2\t3\t100\tNA\n"))
+	cases := []int{0, 1, 2, 3, 4}
+	target := &HuberTarget{fm.Data[0].(NumFeature), 1.0}
+
+	//clipped residuals vs the median of 2.5 cancel out
+	if p := target.Predicted(&cases); p != 2.5 {
+		t.Errorf("Huber estimate %v not 2.5.", p)
+	}
+	if e := target.Impurity(&cases, nil); math.Abs(e-98.25/4.0) > 1e-12 {
+		t.Errorf("Huber impurity %v not %v.", e, 98.25/4.0)
+	}
+
+	//with a large delta the loss is half the squared error
+	target.Delta = 1000.0
+	sq := fm.Data[0].(NumFeature)
+	if p, m := target.Predicted(&cases), sq.Mean(&cases); math.Abs(p-m) > 1e-12 {
+		t.Errorf("Huber estimate %v with large delta not the mean %v.", p, m)
+	}
+	if e, v := target.Impurity(&cases, nil), sq.Error(&cases, sq.Mean(&cases))/2.0; math.Abs(e-v) > 1e-9 {
+		t.Errorf("Huber impurity %v with large delta not half the mean squared error %v.", e, v)
+	}
+}
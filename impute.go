@@ -0,0 +1,119 @@
+package CloudForest
+
+/*
+ProximityImpute implements Breiman's iterative proximity based imputation (rfImpute).
+Missing values in the candidate features are first filled in with the feature
+mean/mode. Then, nIter times, a forest of nTrees is grown to predict the feature at
+targeti using the candidates, proximities are calculated and each originally missing value is
+replaced with the proximity weighted mean (numerical features) or the category with the
+largest total proximity (categorical features) of the cases where the feature isn't
+missing.
+
+Only cases where the target isn't missing are used to grow the forests but all cases
+are imputed. Features that are not DenseNumFeatures or DenseCatFeatures are left with
+the mean/mode imputation. mTry and leafSize are as in GrowRandomForest.
+*/
+func (fm *FeatureMatrix) ProximityImpute(targeti int, candidates []int, nIter int, nTrees int, mTry int, leafSize int, nCores int) {
+	target := fm.Data[targeti]
+	nCases := target.Length()
+
+	//record which values were missing before filling with the mean/mode
+	missing := make(map[int][]int)
+	rows := make([]int, 0)
+	hasMissing := make([]bool, nCases)
+	for _, fi := range candidates {
+		f := fm.Data[fi]
+		for i := 0; i < nCases; i++ {
+			if f.IsMissing(i) {
+				missing[fi] = append(missing[fi], i)
+				if !hasMissing[i] {
+					hasMissing[i] = true
+					rows = append(rows, i)
+				}
+			}
+		}
+		if len(missing[fi]) > 0 {
+			f.ImputeMissing()
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	observed := make([]int, 0, nCases)
+	for i := 0; i < nCases; i++ {
+		if !target.IsMissing(i) {
+			observed = append(observed, i)
+		}
+	}
+
+	for iter := 0; iter < nIter; iter++ {
+		forest := &Forest{target.GetName(), make([]*Tree, 0, nTrees), "", 0.0}
+		allocs := NewBestSplitAllocs(len(observed), target)
+		cases := make([]int, 0, len(observed))
+		for t := 0; t < nTrees; t++ {
+			cases = cases[0:0]
+			for _, j := range SampleWithReplacment(len(observed), len(observed)) {
+				cases = append(cases, observed[j])
+			}
+			tree := NewTree()
+			tree.Grow(fm, target, cases, candidates, nil, mTry, leafSize, false, false, false, false, nil, nil, allocs)
+			forest.Trees = append(forest.Trees, tree)
+		}
+
+		leaves := NewLeafIndex(forest, fm)
+		prox := Proximity(leaves, leaves, nil, nil, rows, nCores)
+		rowOf := make(map[int]int, len(rows))
+		for k, i := range prox.Rows {
+			rowOf[i] = k
+		}
+
+		for fi, cases := range missing {
+			wasMissing := make(map[int]bool, len(cases))
+			for _, i := range cases {
+				wasMissing[i] = true
+			}
+			imputed := make(map[int]float64, len(cases))
+			for _, i := range cases {
+				cols, vals := prox.Row(rowOf[i])
+				switch f := fm.Data[fi].(type) {
+				case *DenseNumFeature:
+					sum, weight := 0.0, 0.0
+					for n, j := range cols {
+						if j != i && !wasMissing[j] {
+							sum += vals[n] * f.Get(j)
+							weight += vals[n]
+						}
+					}
+					if weight > 0.0 {
+						imputed[i] = sum / weight
+					}
+				case *DenseCatFeature:
+					votes := make([]float64, f.NCats())
+					best := -1
+					for n, j := range cols {
+						if j != i && !wasMissing[j] {
+							c := f.Geti(j)
+							votes[c] += vals[n]
+							if best < 0 || votes[c] > votes[best] {
+								best = c
+							}
+						}
+					}
+					if best >= 0 {
+						imputed[i] = float64(best)
+					}
+				}
+			}
+			//update after calculating so all cases in an iteration use the same values
+			for i, v := range imputed {
+				switch f := fm.Data[fi].(type) {
+				case *DenseNumFeature:
+					f.Put(i, v)
+				case *DenseCatFeature:
+					f.Puti(i, int(v))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package CloudForest
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestProximityImpute(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping proximity imputation on iris data set.")
+	}
+	fm := ParseLibSVM(strings.NewReader(irislibsvm))
+	petal := fm.Data[3].(*DenseNumFeature)
+	truth := make(map[int]float64)
+	for i := 0; i < 150; i += 10 {
+		truth[i] = petal.Get(i)
+		petal.PutMissing(i)
+	}
+	fm.Data[4].PutMissing(5)
+
+	fm.ProximityImpute(0, []int{1, 2, 3, 4}, 3, 50, 2, 1, 2)
+
+	if fm.Data[4].IsMissing(5) {
+		t.Errorf("Missing value not imputed.")
+	}
+	mean := 0.0
+	for i := 0; i < 150; i++ {
+		if _, ok := truth[i]; !ok {
+			mean += petal.Get(i)
+		}
+	}
+	mean /= float64(150 - len(truth))
+	proxErr, meanErr := 0.0, 0.0
+	for i, v := range truth {
+		if petal.IsMissing(i) {
+			t.Fatalf("Missing value not imputed.")
+		}
+		proxErr += math.Abs(petal.Get(i) - v)
+		meanErr += math.Abs(mean - v)
+	}
+	if proxErr >= meanErr {
+		t.Errorf("Proximity imputation error %v not less then mean imputation error %v.", proxErr, meanErr)
+	}
+}
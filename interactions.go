@@ -0,0 +1,292 @@
+package CloudForest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+/*
+InteractionTally accumulates statistics about pairs of features that are used
+together in the structure of a forest's trees. Features are identified by the
+names used in their splitters and assigned indexes in the order they are seen.
+
+CoOccurrence counts, for each pair of features (i<j), the number of root to leaf
+paths that split on both features.
+
+MinDepthSum and MinDepthCount accumulate the conditional minimal depth of
+feature j within the maximal subtrees of feature i (the subtrees rooted at nodes
+splitting on i that have no ancestor splitting on i) as in Ishwaran et al.'s
+"High-Dimensional Variable Selection for Survival Data". Depth is measured
+relative to the root of the maximal subtree so that the children of that root
+have depth 1.
+*/
+type InteractionTally struct {
+	Names         []string
+	Index         map[string]int
+	CoOccurrence  *SparseCounter
+	MinDepthSum   map[int]map[int]float64
+	MinDepthCount *SparseCounter
+}
+
+//NewInteractionTally returns an initialized InteractionTally.
+func NewInteractionTally() *InteractionTally {
+	return &InteractionTally{make([]string, 0),
+		make(map[string]int),
+		new(SparseCounter),
+		make(map[int]map[int]float64),
+		new(SparseCounter)}
+}
+
+//featureIndex returns the index of the feature used in n's splitter, adding it if needed.
+func (it *InteractionTally) featureIndex(n *Node) int {
+	name := n.Splitter.Feature
+	i, ok := it.Index[name]
+	if !ok {
+		i = len(it.Names)
+		it.Index[name] = i
+		it.Names = append(it.Names, name)
+	}
+	return i
+}
+
+//children returns the non nil children of a node.
+func (n *Node) children() []*Node {
+	children := make([]*Node, 0, 3+len(n.Branches))
+	for _, c := range append([]*Node{n.Left, n.Right, n.Missing}, n.Branches...) {
+		if c != nil {
+			children = append(children, c)
+		}
+	}
+	return children
+}
+
+/*
+TallyInteractions adds the co-occurrence and conditional minimal depth statistics
+of the tree's structure to it. It doesn't require data and can be used on trees read
+from .sf files. It is not thread safe.
+*/
+func (t *Tree) TallyInteractions(it *InteractionTally) {
+	//minimum depth of each feature in the subtree rooted at each node
+	subDepths := make(map[*Node]map[int]int)
+	var fillDepths func(n *Node) map[int]int
+	fillDepths = func(n *Node) map[int]int {
+		depths := make(map[int]int)
+		if n.Splitter == nil {
+			return depths
+		}
+		depths[it.featureIndex(n)] = 0
+		for _, c := range n.children() {
+			for f, d := range fillDepths(c) {
+				if od, ok := depths[f]; !ok || d+1 < od {
+					depths[f] = d + 1
+				}
+			}
+		}
+		subDepths[n] = depths
+		return depths
+	}
+	fillDepths(t.Root)
+
+	var walk func(n *Node, path map[int]int)
+	walk = func(n *Node, path map[int]int) {
+		if n.Splitter == nil {
+			//leaf, count pairs of features on the path
+			fis := make([]int, 0, len(path))
+			for f := range path {
+				fis = append(fis, f)
+			}
+			sort.Ints(fis)
+			for a := 0; a < len(fis); a++ {
+				for b := a + 1; b < len(fis); b++ {
+					it.CoOccurrence.Add(fis[a], fis[b], 1)
+				}
+			}
+			return
+		}
+		fi := it.featureIndex(n)
+		if path[fi] == 0 {
+			//root of a maximal fi subtree
+			for fj, d := range subDepths[n] {
+				if fj == fi {
+					continue
+				}
+				if _, ok := it.MinDepthSum[fi]; !ok {
+					it.MinDepthSum[fi] = make(map[int]float64)
+				}
+				it.MinDepthSum[fi][fj] += float64(d)
+				it.MinDepthCount.Add(fi, fj, 1)
+			}
+		}
+		path[fi]++
+		for _, c := range n.children() {
+			walk(c, path)
+		}
+		path[fi]--
+		if path[fi] == 0 {
+			delete(path, fi)
+		}
+	}
+	walk(t.Root, make(map[int]int))
+}
+
+/*
+HStatistic estimates Friedman and Popescu's H-statistic for the interaction between
+two features from partial dependence functions calculated over grids of values:
+
+	H^2 = sum (PD_jk(xj,xk) - PD_j(xj) - PD_k(xk))^2 / sum PD_jk(xj,xk)^2
+
+where each partial dependence function has been centered to have mean zero and the sums
+are over the grid points (the cartesian product of gridj and gridk) instead of over the
+data points. For categorical targets the numerator and denominator are summed over the
+fraction of votes for each class. It returns the square root, H.
+*/
+func HStatistic(forest *Forest, fm *FeatureMatrix, fj int, fk int, gridj []string, gridk []string, categorical bool) (h float64) {
+	pdj := PartialDependence(forest, fm, []int{fj}, [][]string{gridj}, categorical, false)
+	pdk := PartialDependence(forest, fm, []int{fk}, [][]string{gridk}, categorical, false)
+	pdjk := PartialDependence(forest, fm, []int{fj, fk}, [][]string{gridj, gridk}, categorical, false)
+
+	cj := centeredByOutput(pdj)
+	ck := centeredByOutput(pdk)
+	cjk := centeredByOutput(pdjk)
+
+	num := 0.0
+	denom := 0.0
+	for out, jk := range cjk {
+		j, jok := cj[out]
+		k, kok := ck[out]
+		for a := range gridj {
+			for b := range gridk {
+				v := jk[a*len(gridk)+b]
+				d := v
+				if jok {
+					d -= j[a]
+				}
+				if kok {
+					d -= k[b]
+				}
+				num += d * d
+				denom += v * v
+			}
+		}
+	}
+	if denom == 0.0 {
+		return 0.0
+	}
+	return math.Sqrt(num / denom)
+}
+
+//centeredByOutput returns each output column of a partial dependence, keyed by
+//class (or "" for regression) and centered to have mean zero over the grid.
+func centeredByOutput(pd *PartialDep) map[string][]float64 {
+	centered := make(map[string][]float64)
+	for k, out := range pd.outputHeaders() {
+		if pd.Classes == nil {
+			out = ""
+		}
+		col := make([]float64, 0, len(pd.PD))
+		mean := 0.0
+		for _, row := range pd.PD {
+			col = append(col, row[k])
+			mean += row[k]
+		}
+		mean /= float64(len(col))
+		for i := range col {
+			col[i] -= mean
+		}
+		centered[out] = col
+	}
+	return centered
+}
+
+//Interaction summarizes the evidence for an interaction between two features.
+type Interaction struct {
+	Feature1     string
+	Feature2     string
+	CoOccurrence int
+	MinDepth12   float64
+	MinDepth21   float64
+	H            float64
+}
+
+/*
+Interactions returns a summary of all pairs of features that co-occur on at least
+one root to leaf path. MinDepth12 is the mean conditional minimal depth of Feature2 in
+maximal subtrees of Feature1 and MinDepth21 the reverse (NaN if never observed). H is
+set to NaN and can be filled in with HStatistic. Interactions are sorted by decreasing
+co-occurrence.
+*/
+func (it *InteractionTally) Interactions() (interactions []*Interaction) {
+	interactions = make([]*Interaction, 0)
+	for i, row := range it.CoOccurrence.Map {
+		for j, count := range row {
+			interactions = append(interactions, &Interaction{it.Names[i],
+				it.Names[j],
+				count,
+				it.meanMinDepth(i, j),
+				it.meanMinDepth(j, i),
+				math.NaN()})
+		}
+	}
+	sort.Sort(interactionsByCoOccurrence(interactions))
+	return
+}
+
+func (it *InteractionTally) meanMinDepth(i int, j int) float64 {
+	count := it.MinDepthCount.Map[i][j]
+	if count == 0 {
+		return math.NaN()
+	}
+	return it.MinDepthSum[i][j] / float64(count)
+}
+
+type interactionsByCoOccurrence []*Interaction
+
+func (s interactionsByCoOccurrence) Len() int      { return len(s) }
+func (s interactionsByCoOccurrence) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s interactionsByCoOccurrence) Less(i, j int) bool {
+	if s[i].CoOccurrence != s[j].CoOccurrence {
+		return s[i].CoOccurrence > s[j].CoOccurrence
+	}
+	if s[i].Feature1 != s[j].Feature1 {
+		return s[i].Feature1 < s[j].Feature1
+	}
+	return s[i].Feature2 < s[j].Feature2
+}
+
+/*
+RankInteractions sorts interactions by decreasing H statistic with pairs for which
+H wasn't calculated placed after, in order of decreasing co-occurrence.
+*/
+func RankInteractions(interactions []*Interaction) {
+	sort.Stable(interactionsByH(interactions))
+}
+
+type interactionsByH []*Interaction
+
+func (s interactionsByH) Len() int      { return len(s) }
+func (s interactionsByH) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s interactionsByH) Less(i, j int) bool {
+	hi, hj := s[i].H, s[j].H
+	switch {
+	case math.IsNaN(hi):
+		return false
+	case math.IsNaN(hj):
+		return true
+	}
+	return hi > hj
+}
+
+//WriteInteractions writes a ranked list of interactions as a tsv with a header row.
+func WriteInteractions(w io.Writer, interactions []*Interaction) (err error) {
+	if _, err = fmt.Fprintln(w, "Feature1\tFeature2\tH\tCoOccurrence\tMinDepth12\tMinDepth21"); err != nil {
+		return
+	}
+	for _, in := range interactions {
+		if _, err = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", in.Feature1, in.Feature2, in.H, in.CoOccurrence, in.MinDepth12, in.MinDepth21); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ryanbressler/CloudForest"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	fm := flag.String("fm",
+		"", "AFM formated feature matrix containing data. Required to calculate H-statistics.")
+	rf := flag.String("rfpred",
+		"rface.sf", "A predictor forest.")
+	outf := flag.String("out",
+		"interactions.tsv", "The name of a file to write the ranked feature pairs into.")
+	var nTop int
+	flag.IntVar(&nTop, "top", 10, "Number of pairs (by co-occurrence) to calculate H-statistics for.")
+	var nGrid int
+	flag.IntVar(&nGrid, "grid", 10, "Number of grid points to sweep numerical features over.")
+	var num bool
+	flag.BoolVar(&num, "mean", false, "Force numeric (mean) voting.")
+	var cat bool
+	flag.BoolVar(&cat, "mode", false, "Force categorical (mode) voting.")
+
+	flag.Parse()
+
+	forestfile, err := os.Open(*rf) // For read access.
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer forestfile.Close()
+	forestreader := CloudForest.NewForestReader(forestfile)
+	forest, err := forestreader.ReadForest()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tally := CloudForest.NewInteractionTally()
+	for _, tree := range forest.Trees {
+		tree.TallyInteractions(tally)
+	}
+	interactions := tally.Interactions()
+	fmt.Printf("Found %v co-occurring feature pairs in %v trees.\n", len(interactions), len(forest.Trees))
+
+	if *fm != "" {
+		data, err := CloudForest.LoadAFM(*fm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		categorical := cat || !(num || strings.HasPrefix(forest.Target, "N"))
+		grids := make(map[int][]string)
+		for i, in := range interactions {
+			if i >= nTop {
+				break
+			}
+			fj, ok := data.Map[in.Feature1]
+			if !ok {
+				log.Fatalf("Feature %v not found in data.", in.Feature1)
+			}
+			fk, ok := data.Map[in.Feature2]
+			if !ok {
+				log.Fatalf("Feature %v not found in data.", in.Feature2)
+			}
+			for _, fi := range []int{fj, fk} {
+				if _, ok := grids[fi]; !ok {
+					grids[fi] = CloudForest.NewGrid(data.Data[fi], nGrid)
+				}
+			}
+			in.H = CloudForest.HStatistic(forest, data, fj, fk, grids[fj], grids[fk], categorical)
+			fmt.Printf("H-statistic for %v and %v: %v\n", in.Feature1, in.Feature2, in.H)
+		}
+		CloudForest.RankInteractions(interactions)
+	}
+
+	fmt.Printf("Outputting ranked feature pairs to %v\n", *outf)
+	outfile, err := os.Create(*outf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer outfile.Close()
+	if err = CloudForest.WriteInteractions(outfile, interactions); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package CloudForest
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestInteractions(t *testing.T) {
+	tree := new(Tree)
+	tree.AddNode("*", "", &Splitter{Feature: "N:A", Numerical: true, Value: 0.5})
+	tree.AddNode("*L", "", &Splitter{Feature: "N:B", Numerical: true, Value: 0.5})
+	tree.AddNode("*LL", "", &Splitter{Feature: "N:C", Numerical: true, Value: 0.5})
+	tree.AddNode("*LLL", "0", nil)
+	tree.AddNode("*LLR", "1", nil)
+	tree.AddNode("*LR", "1", nil)
+	tree.AddNode("*R", "1", nil)
+
+	tally := NewInteractionTally()
+	tree.TallyInteractions(tally)
+	interactions := tally.Interactions()
+	if len(interactions) != 3 {
+		t.Fatalf("Found %v interacting pairs not 3.", len(interactions))
+	}
+	top := interactions[0]
+	if top.CoOccurrence != 3 || top.Feature1 != "N:A" || top.Feature2 != "N:B" {
+		t.Errorf("Top pair %v %v co-occurs %v times, expected N:A N:B 3 times.", top.Feature1, top.Feature2, top.CoOccurrence)
+	}
+	if top.MinDepth12 != 1.0 || !math.IsNaN(top.MinDepth21) {
+		t.Errorf("Conditional minimal depths %v and %v not 1 and NaN.", top.MinDepth12, top.MinDepth21)
+	}
+	if d := tally.meanMinDepth(tally.Index["N:A"], tally.Index["N:C"]); d != 2.0 {
+		t.Errorf("Conditional minimal depth of N:C under N:A is %v not 2.", d)
+	}
+
+	fm := ParseAFM(strings.NewReader(fm))
+	candidates := []int{2, 3, 4}
+	forest := GrowRandomForest(fm, fm.Data[0].(Feature), candidates, fm.Data[0].Length(), 3, 10, 1, false, false, false, false, nil)
+	grid := NewGrid(fm.Data[4], 5)
+	catgrid := NewGrid(fm.Data[2], 5)
+	h := HStatistic(forest, fm, 4, 2, grid, catgrid, false)
+	if h < 0.0 || h > 1.0 || math.IsNaN(h) {
+		t.Errorf("H-statistic %v not between 0 and 1.", h)
+	}
+
+	interactions[1].H = 0.5
+	RankInteractions(interactions)
+	if interactions[0].H != 0.5 {
+		t.Errorf("Pair with H-statistic not ranked first.")
+	}
+	var out bytes.Buffer
+	if err := WriteInteractions(&out, interactions); err != nil {
+		t.Errorf("Error writing interactions: %v", err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 4 {
+		t.Errorf("Interaction output has %v lines not 4.", lines)
+	}
+}
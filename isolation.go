@@ -0,0 +1,200 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+//IsolationTarget is the target name recorded in the headers of isolation forests.
+const IsolationTarget = "ISOLATION"
+
+/*
+AveragePathLength returns c(n), the average path length of an unsuccessful search in a
+binary search tree of n cases, used by isolation forests to account for the cases
+left unisolated at a node:
+
+	c(n) = 2H(n-1) - 2(n-1)/n
+
+where H is the harmonic number (estimated as ln(i) + Euler's constant).
+*/
+func AveragePathLength(n int) float64 {
+	switch {
+	case n <= 1:
+		return 0.0
+	case n == 2:
+		return 1.0
+	}
+	fn := float64(n)
+	return 2.0*(math.Log(fn-1.0)+0.5772156649) - 2.0*(fn-1.0)/fn
+}
+
+/*
+GrowIsolation grows an isolation tree (Liu, Ting and Zhou's iTree) on the specified
+cases. At each node a feature is chosen at random from the candidates that are not
+constant on the node's cases and split at a value chosen uniformly between its minimum
+and maximum (numerical features) or on a random subset of the categories present
+(categorical features). Growth stops when a node has one case or maxDepth is reached.
+
+Cases missing the chosen feature are not sent down either branch but stop at the node,
+treating them as unisolated among its cases, so that missingness itself doesn't make
+a case look anomalous. Every node's Pred is set to c(n), the average path length of its
+n cases, so that path lengths can be adjusted for unisolated cases (see PathLengths).
+cases is reordered in place.
+*/
+func (t *Tree) GrowIsolation(fm *FeatureMatrix, cases []int, candidates []int, maxDepth int) {
+	t.Target = IsolationTarget
+	t.Root.growIsolation(fm, cases, candidates, 0, maxDepth)
+}
+
+func (n *Node) growIsolation(fm *FeatureMatrix, cases []int, candidates []int, depth int, maxDepth int) {
+	n.Pred = strconv.FormatFloat(AveragePathLength(len(cases)), 'g', -1, 64)
+	if len(cases) <= 1 || depth >= maxDepth {
+		return
+	}
+
+	for _, k := range rand.Perm(len(candidates)) {
+		f := fm.Data[candidates[k]]
+		splitter := randomSplitter(f, cases)
+		if splitter == nil {
+			continue
+		}
+		l, r, _ := splitter.Split(fm, cases)
+		n.Splitter = splitter
+		n.Left = new(Node)
+		n.Right = new(Node)
+		n.Left.growIsolation(fm, l, candidates, depth+1, maxDepth)
+		n.Right.growIsolation(fm, r, candidates, depth+1, maxDepth)
+		return
+	}
+}
+
+//randomSplitter returns a random splitter for f that sends at least one non missing case
+//each way or nil if f is constant on the cases.
+func randomSplitter(f Feature, cases []int) *Splitter {
+	switch f.(type) {
+	case NumFeature:
+		nf := f.(NumFeature)
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, c := range cases {
+			if !nf.IsMissing(c) {
+				v := nf.Get(c)
+				min = math.Min(min, v)
+				max = math.Max(max, v)
+			}
+		}
+		if !(min < max) {
+			return nil
+		}
+		value := min + rand.Float64()*(max-min)
+		if value >= max {
+			value = min
+		}
+		return &Splitter{Feature: f.GetName(), Numerical: true, Value: value}
+
+	case CatFeature:
+		cf := f.(CatFeature)
+		present := make([]string, 0)
+		seen := make(map[int]bool)
+		for _, c := range cases {
+			if !cf.IsMissing(c) && !seen[cf.Geti(c)] {
+				seen[cf.Geti(c)] = true
+				present = append(present, cf.NumToCat(cf.Geti(c)))
+			}
+		}
+		if len(present) < 2 {
+			return nil
+		}
+		//a random non empty proper subset goes left
+		left := make(map[string]bool)
+		perm := rand.Perm(len(present))
+		for _, k := range perm[:1+rand.Intn(len(present)-1)] {
+			left[present[k]] = true
+		}
+		return &Splitter{Feature: f.GetName(), Left: left}
+	}
+	return nil
+}
+
+/*
+PathLengths returns the isolation path length of every case in fm: the depth of the
+deepest node it reaches plus the average path length stored in that node's Pred. Cases
+missing the value a node splits on stop at that node. It returns an error
+if a node has no numerical Pred as in trees that aren't isolation trees.
+*/
+func (t *Tree) PathLengths(fm *FeatureMatrix) (lengths []float64, err error) {
+	ncases := fm.Data[0].Length()
+	cases := make([]int, 0, ncases)
+	for i := 0; i < ncases; i++ {
+		cases = append(cases, i)
+	}
+	lengths = make([]float64, ncases)
+	t.Root.Recurse(func(n *Node, cases []int, depth int) {
+		c, e := strconv.ParseFloat(n.Pred, 64)
+		if e != nil {
+			err = fmt.Errorf("Node prediction %v is not an average path length.", n.Pred)
+			return
+		}
+		for _, i := range cases {
+			lengths[i] = float64(depth) + c
+		}
+	}, fm, cases, 0)
+	return
+}
+
+/*
+GrowIsolationForest grows nTrees isolation trees each on nSamples cases sampled without
+replacement with the maximum depth set to ceil(log2(nSamples)) as suggested by Liu et al.
+*/
+func GrowIsolationForest(fm *FeatureMatrix, candidates []int, nSamples int, nTrees int) (f *Forest) {
+	f = &Forest{IsolationTarget, make([]*Tree, 0, nTrees), "", 0.0}
+	nCases := fm.Data[0].Length()
+	if nSamples > nCases || nSamples <= 0 {
+		nSamples = nCases
+	}
+	maxDepth := int(math.Ceil(math.Log2(float64(nSamples))))
+	cases := make([]int, 0, nCases)
+	for i := 0; i < nTrees; i++ {
+		cases = cases[0:0]
+		for j := 0; j < nCases; j++ {
+			cases = append(cases, j)
+		}
+		SampleFirstN(&cases, nil, nSamples, 0)
+		tree := NewTree()
+		tree.GrowIsolation(fm, cases[:nSamples], candidates, maxDepth)
+		f.Trees = append(f.Trees, tree)
+	}
+	return
+}
+
+/*
+IsolationScores returns the anomaly score of each case in fm:
+
+	s(x) = 2^(-E[h(x)/c(n)])
+
+where h(x) is a tree's path length for the case and c(n) is the average path length
+of the n cases the tree was grown on (the Pred of its root). Scores near 1 indicate
+anomalies while scores well below 0.5 indicate normal cases.
+*/
+func IsolationScores(forest *Forest, fm *FeatureMatrix) (scores []float64, err error) {
+	ncases := fm.Data[0].Length()
+	scores = make([]float64, ncases)
+	for _, tree := range forest.Trees {
+		norm, e := strconv.ParseFloat(tree.Root.Pred, 64)
+		if e != nil || norm == 0.0 {
+			return nil, fmt.Errorf("Tree root prediction %v is not an average path length.", tree.Root.Pred)
+		}
+		lengths, e := tree.PathLengths(fm)
+		if e != nil {
+			return nil, e
+		}
+		for i, l := range lengths {
+			scores[i] += l / norm
+		}
+	}
+	for i := range scores {
+		scores[i] = math.Pow(2.0, -scores[i]/float64(len(forest.Trees)))
+	}
+	return
+}
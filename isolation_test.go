@@ -0,0 +1,49 @@
+package CloudForest
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestIsolationForest(t *testing.T) {
+	fm := ParseLibSVM(strings.NewReader(irislibsvm))
+	for fi := 1; fi < 5; fi++ {
+		fm.Data[fi].(*DenseNumFeature).Put(7, 10.0)
+	}
+	for i := 20; i < 150; i += 13 {
+		fm.Data[2].PutMissing(i)
+	}
+
+	if c := AveragePathLength(256); math.Abs(c-10.244) > 0.001 {
+		t.Errorf("Average path length of 256 cases is %v not 10.244.", c)
+	}
+
+	forest := GrowIsolationForest(fm, []int{0, 1, 2, 3, 4}, 64, 50)
+	scores, err := IsolationScores(forest, fm)
+	if err != nil {
+		t.Fatalf("Error scoring cases: %v", err)
+	}
+	for i, s := range scores {
+		if i != 7 && s >= scores[7] {
+			t.Errorf("Case %v has anomaly score %v not less then the outlier's %v.", i, s, scores[7])
+		}
+	}
+
+	var sf bytes.Buffer
+	NewForestWriter(&sf).WriteForest(forest)
+	read, err := NewForestReader(&sf).ReadForest()
+	if err != nil {
+		t.Fatalf("Error reading isolation forest: %v", err)
+	}
+	readScores, err := IsolationScores(read, fm)
+	if err != nil {
+		t.Fatalf("Error scoring cases with read forest: %v", err)
+	}
+	for i := range scores {
+		if math.Abs(scores[i]-readScores[i]) > 1e-9 {
+			t.Errorf("Case %v scored %v before writing and %v after.", i, scores[i], readScores[i])
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package CloudForest
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+Loss is a differentiable loss function for use in gradient boosting by GradBoostTarget.
+Trees are grown against the pseudo residuals returned by NegGradient and the prediction
+of each leaf is then chosen by LeafValue to minimize the loss of its cases.
+*/
+type Loss interface {
+	//Eval returns the loss of predicting f for a case with value y.
+	Eval(y float64, f float64) float64
+	//NegGradient returns the pseudo residual, the negative gradient of the loss with respect to f.
+	NegGradient(y float64, f float64) float64
+	//Init returns the constant prediction that minimizes the loss over the non missing cases.
+	Init(y NumFeature, cases []int) float64
+	//LeafValue returns the value to add to the current predictions (pred) of the non missing
+	//cases in a leaf to minimize their loss.
+	LeafValue(y NumFeature, pred []float64, cases []int) float64
+}
+
+/*
+TargetLoss returns the Loss matching a regression target: AbsoluteLoss for L1Target,
+HuberLoss for HuberTarget, QuantileLoss for QuantileTarget and SquaredLoss for other
+numerical features. It returns nil for targets whose loss isn't supported by gradient
+boosting like the deviance targets.
+*/
+func TargetLoss(target NumFeature) Loss {
+	switch t := target.(type) {
+	case *L1Target:
+		return AbsoluteLoss{}
+	case *HuberTarget:
+		return HuberLoss{t.Delta}
+	case *QuantileTarget:
+		return QuantileLoss{t.Alpha}
+	case *PoissonTarget, *GammaTarget, *TweedieTarget:
+		return nil
+	}
+	return SquaredLoss{}
+}
+
+//residuals returns y - pred for the non missing cases.
+func residuals(y NumFeature, pred []float64, cases []int) []float64 {
+	r := make([]float64, 0, len(cases))
+	for _, i := range cases {
+		if !y.IsMissing(i) {
+			r = append(r, y.Get(i)-pred[i])
+		}
+	}
+	return r
+}
+
+//nonMissingValues returns the non missing values of y for the cases.
+func nonMissingValues(y NumFeature, cases []int) []float64 {
+	v := make([]float64, 0, len(cases))
+	for _, i := range cases {
+		if !y.IsMissing(i) {
+			v = append(v, y.Get(i))
+		}
+	}
+	return v
+}
+
+//mean returns the mean of vals or NaN if it is empty.
+func mean(vals []float64) float64 {
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	m := 0.0
+	for _, v := range vals {
+		m += v
+	}
+	return m / float64(len(vals))
+}
+
+//quantile returns the smallest value with at least alpha of vals at or below it
+//without modifying vals.
+func quantile(vals []float64, alpha float64) float64 {
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	sorted := make([]float64, len(vals))
+	copy(sorted, vals)
+	sort.Float64s(sorted)
+	k := int(math.Ceil(alpha*float64(len(sorted)))) - 1
+	if k < 0 {
+		k = 0
+	}
+	return sorted[k]
+}
+
+//huberEstimate returns the one step Huber M-estimate of location of vals starting from
+//their median.
+func huberEstimate(vals []float64, delta float64) float64 {
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	med := median(vals)
+	step := 0.0
+	for _, v := range vals {
+		step += math.Max(-delta, math.Min(delta, v-med))
+	}
+	return med + step/float64(len(vals))
+}
+
+//SquaredLoss is half the squared error. Its pseudo residuals are the ordinary residuals.
+type SquaredLoss struct{}
+
+func (l SquaredLoss) Eval(y float64, f float64) float64 {
+	return (y - f) * (y - f) / 2.0
+}
+
+func (l SquaredLoss) NegGradient(y float64, f float64) float64 {
+	return y - f
+}
+
+func (l SquaredLoss) Init(y NumFeature, cases []int) float64 {
+	return mean(nonMissingValues(y, cases))
+}
+
+func (l SquaredLoss) LeafValue(y NumFeature, pred []float64, cases []int) float64 {
+	return mean(residuals(y, pred, cases))
+}
+
+//AbsoluteLoss is the absolute (l1) error. Its pseudo residuals are the signs of the residuals
+//and leaves predict the median residual.
+type AbsoluteLoss struct{}
+
+func (l AbsoluteLoss) Eval(y float64, f float64) float64 {
+	return math.Abs(y - f)
+}
+
+func (l AbsoluteLoss) NegGradient(y float64, f float64) float64 {
+	switch {
+	case y > f:
+		return 1.0
+	case y < f:
+		return -1.0
+	}
+	return 0.0
+}
+
+func (l AbsoluteLoss) Init(y NumFeature, cases []int) float64 {
+	return median(nonMissingValues(y, cases))
+}
+
+func (l AbsoluteLoss) LeafValue(y NumFeature, pred []float64, cases []int) float64 {
+	return median(residuals(y, pred, cases))
+}
+
+//HuberLoss is the Huber loss used by HuberTarget. Its pseudo residuals are the residuals
+//clipped to [-Delta,Delta] and leaves predict the one step Huber estimate of their residuals.
+type HuberLoss struct {
+	Delta float64
+}
+
+func (l HuberLoss) Eval(y float64, f float64) float64 {
+	r := math.Abs(y - f)
+	if r <= l.Delta {
+		return r * r / 2.0
+	}
+	return l.Delta * (r - l.Delta/2.0)
+}
+
+func (l HuberLoss) NegGradient(y float64, f float64) float64 {
+	return math.Max(-l.Delta, math.Min(l.Delta, y-f))
+}
+
+func (l HuberLoss) Init(y NumFeature, cases []int) float64 {
+	return huberEstimate(nonMissingValues(y, cases), l.Delta)
+}
+
+func (l HuberLoss) LeafValue(y NumFeature, pred []float64, cases []int) float64 {
+	return huberEstimate(residuals(y, pred, cases), l.Delta)
+}
+
+//QuantileLoss is the quantile (pinball) loss used by QuantileTarget. Its pseudo residuals
+//are Alpha or Alpha-1 depending on the sign of the residual and leaves predict the Alpha
+//quantile of their residuals.
+type QuantileLoss struct {
+	Alpha float64
+}
+
+func (l QuantileLoss) Eval(y float64, f float64) float64 {
+	r := y - f
+	if r < 0.0 {
+		return (l.Alpha - 1.0) * r
+	}
+	return l.Alpha * r
+}
+
+func (l QuantileLoss) NegGradient(y float64, f float64) float64 {
+	if y < f {
+		return l.Alpha - 1.0
+	}
+	return l.Alpha
+}
+
+func (l QuantileLoss) Init(y NumFeature, cases []int) float64 {
+	return quantile(nonMissingValues(y, cases), l.Alpha)
+}
+
+func (l QuantileLoss) LeafValue(y NumFeature, pred []float64, cases []int) float64 {
+	return quantile(residuals(y, pred, cases), l.Alpha)
+}
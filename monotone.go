@@ -0,0 +1,249 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+/*
+Bounds holds the lower and upper bounds monotone constraints impose on the predictions of
+a node and the nodes below it. A nil *Bounds is unbounded.
+*/
+type Bounds struct {
+	Lower float64
+	Upper float64
+}
+
+//Clip returns v limited to the bounds. It is safe to call on nil Bounds.
+func (b *Bounds) Clip(v float64) float64 {
+	if b == nil {
+		return v
+	}
+	return math.Max(b.Lower, math.Min(b.Upper, v))
+}
+
+//ClipPred returns a numerical prediction, formatted as by FindPredicted, limited to the
+//bounds. Predictions that aren't numbers are returned unchanged.
+func (b *Bounds) ClipPred(pred string) string {
+	if b == nil {
+		return pred
+	}
+	v, err := strconv.ParseFloat(pred, 64)
+	if err != nil {
+		return pred
+	}
+	if c := b.Clip(v); c != v {
+		pred = fmt.Sprintf("%v", c)
+	}
+	return pred
+}
+
+/*
+split returns the bounds of the left and right children of a split on a feature the
+predictions should be increasing (dir 1) or decreasing (dir -1) in that gives the left and
+right children the predictions l and r. The children's (clipped) predictions are separated
+at their mean so every prediction below the left child is on the correct side of every
+prediction below the right child.
+*/
+func (b *Bounds) split(dir int, l float64, r float64) (left *Bounds, right *Bounds) {
+	lower, upper := math.Inf(-1), math.Inf(1)
+	if b != nil {
+		lower, upper = b.Lower, b.Upper
+	}
+	mid := (b.Clip(l) + b.Clip(r)) / 2.0
+	if dir > 0 {
+		return &Bounds{lower, mid}, &Bounds{mid, upper}
+	}
+	return &Bounds{mid, upper}, &Bounds{lower, mid}
+}
+
+/*
+MonotoneConstraints constrains the predictions of trees grown against a numerical target
+(including the residuals of boosting targets) to be increasing (1) or decreasing (-1) in
+specified numerical features. Directions maps the index of each constrained feature in the
+FeatureMatrix to its direction so constraints hold however the feature is stored (including
+as a BinnedNumFeature binned after the constraints were created).
+
+FeatureMatrix.BestSplitter sets Searching to the direction of each feature before searching
+its splits and the BestSplit methods of DenseNumFeature and BinnedNumFeature (including
+histogram split searching) reject splits on a constrained feature if the mean target values
+of the children, clipped to the bounds of the node being split, are in the wrong order.
+When such a split is used Tree.Grow bounds the predictions of the nodes below each child on
+either side of the mean of the children's values and propagates the bounds down the tree as
+it recurses. Splits on other features pass their node's bounds on to their children and
+predictions are clipped to the bounds of their node (including by the BoostTree methods of
+boosting targets), which guarantees the constraints hold.
+
+A MonotoneConstraints should be set as the Monotone field of a BestSplitAllocs; Tree.Grow
+keeps Bounds set to those of the node being split so, like the rest of BestSplitAllocs, it
+should only be used by a single go routine. Constraints are ignored if the target isn't
+a NumFeature.
+*/
+type MonotoneConstraints struct {
+	Directions map[int]int
+	Bounds     *Bounds
+	Searching  int
+}
+
+/*
+NewMonotoneConstraints creates constraints from a map of feature ids to the direction, 1
+for increasing or -1 for decreasing, predictions should move in as the feature increases.
+It returns an error if a feature isn't found, isn't numerical or has another direction.
+*/
+func NewMonotoneConstraints(fm *FeatureMatrix, directions map[string]int) (mc *MonotoneConstraints, err error) {
+	mc = &MonotoneConstraints{make(map[int]int, len(directions)), nil, 0}
+	for name, dir := range directions {
+		i, ok := fm.Map[name]
+		if !ok {
+			return nil, fmt.Errorf("Monotone constraint feature %v not found in data.", name)
+		}
+		if _, ok := fm.Data[i].(NumFeature); !ok || fm.Data[i].NCats() != 0 {
+			return nil, fmt.Errorf("Monotone constraint feature %v isn't a numerical feature.", name)
+		}
+		if dir != 1 && dir != -1 {
+			return nil, fmt.Errorf("Monotone constraint direction %v of %v isn't 1 or -1.", dir, name)
+		}
+		mc.Directions[i] = dir
+	}
+	return
+}
+
+//Copy returns constraints with the same directions for use in another go routine.
+func (mc *MonotoneConstraints) Copy() *MonotoneConstraints {
+	return &MonotoneConstraints{mc.Directions, nil, 0}
+}
+
+//Direction returns the direction the fi'th feature is constrained in or 0 if it isn't. It
+//is safe to call on a nil MonotoneConstraints.
+func (mc *MonotoneConstraints) Direction(fi int) int {
+	if mc == nil {
+		return 0
+	}
+	return mc.Directions[fi]
+}
+
+//Search sets Searching to the direction of the fi'th feature (0 for features that aren't in
+//the FeatureMatrix like oblique projections) before its splits are searched. It is safe to
+//call on a nil MonotoneConstraints.
+func (mc *MonotoneConstraints) Search(fi int) {
+	if mc != nil {
+		mc.Searching = mc.Directions[fi]
+	}
+}
+
+//searching returns the direction of the feature whose splits are being searched. It is safe
+//to call on a nil MonotoneConstraints.
+func (mc *MonotoneConstraints) searching() int {
+	if mc == nil {
+		return 0
+	}
+	return mc.Searching
+}
+
+//Allows checks if a split on a feature constrained in direction dir whose children have
+//the mean target values l and r keeps the predictions in order.
+func (mc *MonotoneConstraints) Allows(dir int, l float64, r float64) bool {
+	return float64(dir)*(mc.Bounds.Clip(r)-mc.Bounds.Clip(l)) >= 0.0
+}
+
+/*
+BoundChildren sets the bounds of the children of a node that has just been split with the
+coded split of the fi'th feature of fm. Splits on constrained features bound their children
+on either side of their mean target values and other splits pass the node's bounds on.
+*/
+func (mc *MonotoneConstraints) BoundChildren(n *Node, fm *FeatureMatrix, target Target, fi int, codedSplit interface{}, cases *[]int) {
+	left, right := n.Bounds, n.Bounds
+	if _, ok := codedSplit.(float64); ok {
+		if dir := mc.Direction(fi); dir != 0 {
+			if l, r, ok := childValues(target, fm, fi, codedSplit, cases); ok {
+				left, right = n.Bounds.split(dir, l, r)
+			}
+		}
+	}
+	if n.Left != nil {
+		n.Left.Bounds = left
+	}
+	if n.Right != nil {
+		n.Right.Bounds = right
+	}
+	if n.Missing != nil {
+		n.Missing.Bounds = n.Bounds
+	}
+	for _, b := range n.Branches {
+		b.Bounds = n.Bounds
+	}
+}
+
+//childValues returns the mean target values of the left and right children of a split
+//and false if the target isn't numerical or either child has no non missing values.
+func childValues(target Target, fm *FeatureMatrix, fi int, codedSplit interface{}, cases *[]int) (l float64, r float64, ok bool) {
+	num, ok := target.(NumFeature)
+	if !ok {
+		return
+	}
+	li, ri := splitPoints(fm, fi, codedSplit, cases)
+	var lok, rok bool
+	l, lok = meanValue(num, (*cases)[:li])
+	r, rok = meanValue(num, (*cases)[ri:])
+	return l, r, lok && rok
+}
+
+//monotoneSums keeps running sums of the target values of the cases left and right of a
+//split as cases in sorted order are moved left, for checking monotone constraints.
+type monotoneSums struct {
+	num   NumFeature
+	dir   int
+	lsum  float64
+	rsum  float64
+	ln    int
+	rn    int
+	moved int
+}
+
+//newMonotoneSums returns sums with all of the sorted cases on the right or nil if splits
+//aren't constrained (dir is 0) or the target isn't numerical.
+func newMonotoneSums(target Target, sorted []int, dir int) *monotoneSums {
+	num, ok := target.(NumFeature)
+	if !ok || dir == 0 {
+		return nil
+	}
+	s := &monotoneSums{num: num, dir: dir}
+	for _, c := range sorted {
+		if !num.IsMissing(c) {
+			s.rsum += num.Get(c)
+			s.rn++
+		}
+	}
+	return s
+}
+
+//allows moves the sorted cases before the i'th left and checks if mc allows the split
+//between them and the rest.
+func (s *monotoneSums) allows(mc *MonotoneConstraints, sorted []int, i int) bool {
+	for ; s.moved < i; s.moved++ {
+		if c := sorted[s.moved]; !s.num.IsMissing(c) {
+			v := s.num.Get(c)
+			s.lsum += v
+			s.rsum -= v
+			s.ln++
+			s.rn--
+		}
+	}
+	return s.ln > 0 && s.rn > 0 && mc.Allows(s.dir, s.lsum/float64(s.ln), s.rsum/float64(s.rn))
+}
+
+//meanValue returns the mean of the non missing values of cases and false if there are none.
+func meanValue(num NumFeature, cases []int) (mean float64, ok bool) {
+	n := 0
+	for _, c := range cases {
+		if !num.IsMissing(c) {
+			mean += num.Get(c)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0.0, false
+	}
+	return mean / float64(n), true
+}
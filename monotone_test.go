@@ -0,0 +1,186 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+//monotoneTestData returns n cases of a target that increases with X and decreases with Z
+//but is dominated by noise. It draws them from a fixed seed so the data is the same in
+//every run.
+func monotoneTestData(n int) *FeatureMatrix {
+	rng := rand.New(rand.NewSource(1))
+	x := make([]string, 0, n)
+	z := make([]string, 0, n)
+	y := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		xv, zv := rng.Float64(), rng.Float64()
+		x = append(x, fmt.Sprintf("%v", xv))
+		z = append(z, fmt.Sprintf("%v", zv))
+		y = append(y, fmt.Sprintf("%v", xv-zv+math.Sin(20.0*xv)+rng.NormFloat64()))
+	}
+	return parseRows([]string{"N:Y", "N:X", "N:Z"}, [][]string{y, x, z})
+}
+
+//checkMonotone checks that the sum of the weighted votes of trees for a grid of cases is
+//increasing in X and decreasing in Z.
+func checkMonotone(t *testing.T, trees []*Tree) {
+	steps := 25
+	y := make([]string, 0, steps*steps)
+	x := make([]string, 0, steps*steps)
+	z := make([]string, 0, steps*steps)
+	for i := 0; i < steps; i++ {
+		for j := 0; j < steps; j++ {
+			y = append(y, "0")
+			x = append(x, fmt.Sprintf("%v", float64(i)/float64(steps-1)))
+			z = append(z, fmt.Sprintf("%v", float64(j)/float64(steps-1)))
+		}
+	}
+	//the grid has the same layout as the training data so coded splits can be used
+	grid := parseRows([]string{"N:Y", "N:X", "N:Z"}, [][]string{y, x, z})
+	bb := NewSumBallotBox(steps*steps, 0.0)
+	for _, tree := range trees {
+		tree.Vote(grid, bb)
+	}
+	pred := func(i, j int) float64 {
+		return bb.TallyNum(i*steps + j)
+	}
+	for i := 0; i < steps; i++ {
+		for j := 0; j < steps; j++ {
+			if i > 0 && pred(i, j) < pred(i-1, j)-1e-9 {
+				t.Fatalf("Prediction decreases from %v to %v as X increases at %v %v.", pred(i-1, j), pred(i, j), i, j)
+			}
+			if j > 0 && pred(i, j) > pred(i, j-1)+1e-9 {
+				t.Fatalf("Prediction increases from %v to %v as Z increases at %v %v.", pred(i, j-1), pred(i, j), i, j)
+			}
+		}
+	}
+}
+
+func TestMonotoneConstraints(t *testing.T) {
+	n := 1000
+	fm := monotoneTestData(n)
+	target := fm.Data[0].(*DenseNumFeature)
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+
+	for _, directions := range []map[string]int{{"N:Foo": 1}, {"N:X": 2}} {
+		if _, err := NewMonotoneConstraints(fm, directions); err == nil {
+			t.Errorf("No error for constraints %v.", directions)
+		}
+	}
+	monotone, err := NewMonotoneConstraints(fm, map[string]int{"N:X": 1, "N:Z": -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//fully grown trees should be monotone even with presorting and extra trees
+	allocs := NewBestSplitAllocs(n, target)
+	allocs.Monotone = monotone.Copy()
+	trees := make([]*Tree, 0, 3)
+	for k := 0; k < 3; k++ {
+		tree := NewTree()
+		tree.Grow(fm, target, cases, []int{1, 2}, nil, 2, 1, false, false, false, false, nil, nil, allocs)
+		trees = append(trees, tree)
+		checkMonotone(t, []*Tree{tree})
+		allocs.ExtraTrees = k == 1
+		if k == 0 {
+			allocs.Presort = NewPresortedCases(fm, NewPresortedIndex(fm, []int{1, 2}))
+		}
+	}
+	checkMonotone(t, trees)
+
+	//the constraints shouldn't stop the tree from fitting the overall trend
+	bb := NewNumBallotBox(n)
+	trees[0].Vote(fm, bb)
+	if r2 := bb.TallyR2Score(target); r2 < 0.03 {
+		t.Errorf("Monotone tree has training R2 %v.", r2)
+	}
+
+	//boosted trees (and their sum) should be monotone
+	gbt := NewGradBoostTarget(target, SquaredLoss{}, 0.1)
+	allocs = NewBestSplitAllocs(n, gbt)
+	allocs.Monotone = monotone.Copy()
+	boosted := make([]*Tree, 0, 10)
+	for k := 0; k < 10; k++ {
+		tree := NewTree()
+		tree.Grow(fm, gbt, cases, []int{1, 2}, nil, 2, 10, false, false, false, false, nil, nil, allocs)
+		tree.Weight = gbt.BoostTree(tree, fm, cases)
+		boosted = append(boosted, tree)
+	}
+	checkMonotone(t, boosted)
+}
+
+func TestMonotoneConstraintsBinned(t *testing.T) {
+	n := 1000
+	fm := monotoneTestData(n)
+	target := fm.Data[0].(*DenseNumFeature)
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+
+	//binning after the constraints are created should still constrain histogram splits
+	monotone, err := NewMonotoneConstraints(fm, map[string]int{"N:X": 1, "N:Z": -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.BinNumFeatures(16, []int{1, 2})
+	if _, ok := fm.Data[1].(*BinnedNumFeature); !ok {
+		t.Fatal("N:X wasn't binned.")
+	}
+	allocs := NewBestSplitAllocs(n, target)
+	allocs.Monotone = monotone.Copy()
+	tree := NewTree()
+	tree.Grow(fm, target, cases, []int{1, 2}, nil, 2, 1, false, false, false, false, nil, nil, allocs)
+	checkMonotone(t, []*Tree{tree})
+
+	//boosting targets use BestBinnedSplit
+	gbt := NewGradBoostTarget(target, SquaredLoss{}, 0.1)
+	allocs = NewBestSplitAllocs(n, gbt)
+	allocs.Monotone = monotone.Copy()
+	boosted := make([]*Tree, 0, 10)
+	for k := 0; k < 10; k++ {
+		tree := NewTree()
+		tree.Grow(fm, gbt, cases, []int{1, 2}, nil, 2, 10, false, false, false, false, nil, nil, allocs)
+		tree.Weight = gbt.BoostTree(tree, fm, cases)
+		boosted = append(boosted, tree)
+	}
+	checkMonotone(t, boosted)
+
+	//binned features can be constrained directly
+	if _, err := NewMonotoneConstraints(fm, map[string]int{"N:X": 1}); err != nil {
+		t.Error(err)
+	}
+
+	//a target that decreases in a feature constrained to be increasing has no allowed splits
+	x := make([]string, 0, n)
+	y := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		x = append(x, fmt.Sprintf("%v", i))
+		y = append(y, fmt.Sprintf("%v", -i))
+	}
+	fm = parseRows([]string{"N:Y", "N:X"}, [][]string{y, x})
+	target = fm.Data[0].(*DenseNumFeature)
+	monotone, err = NewMonotoneConstraints(fm, map[string]int{"N:X": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.BinNumFeatures(16, []int{1})
+	for _, tgt := range []Target{target, NewGradBoostTarget(target, SquaredLoss{}, 0.1)} {
+		allocs = NewBestSplitAllocs(n, tgt)
+		_, imp, _ := fm.Data[1].BestSplit(tgt, &cases, allocs.Impurity(tgt, &cases), 1, allocs)
+		if imp <= 0.0 {
+			t.Fatalf("No split found without constraints for %T.", tgt)
+		}
+		allocs.Monotone = monotone.Copy()
+		allocs.Monotone.Search(1)
+		if _, imp, _ = fm.Data[1].BestSplit(tgt, &cases, allocs.Impurity(tgt, &cases), 1, allocs); imp > 0.0 {
+			t.Errorf("Binned split with impurity decrease %v violates constraint for %T.", imp, tgt)
+		}
+	}
+}
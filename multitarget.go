@@ -0,0 +1,240 @@
+package CloudForest
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+MultiTarget combines several numerical and/or categorical features into a single target
+for multivariate regression and classification. The impurity of a set of cases is the
+sum of the impurities of the component targets, each multiplied by the corresponding
+entry in Scales, and a node predicts the prediction of each component target (see
+FindPredicted).
+
+MultiTarget embeds the first component target to satisfy the Feature interface.
+IsMissing, GetStr and the methods of Target are overridden to consider all of the
+component targets. Split searches need an allocation for each component which
+NewBestSplitAllocs creates in BestSplitAllocs.Sub.
+*/
+type MultiTarget struct {
+	Feature
+	Targets []Feature
+	Scales  []float64
+}
+
+/*
+NewMultiTarget creates a MultiTarget from the named features. If scale is true each
+component's impurity is divided by its impurity over all non missing cases so that
+targets measured on different scales (and with different impurity functions) make
+comparable contributions; otherwise all scales are 1.
+*/
+func NewMultiTarget(fm *FeatureMatrix, names []string, scale bool) (mt *MultiTarget, err error) {
+	targets := make([]Feature, 0, len(names))
+	for _, name := range names {
+		i, ok := fm.Map[name]
+		if !ok {
+			return nil, fmt.Errorf("Target %v not found in data.", name)
+		}
+		targets = append(targets, fm.Data[i])
+	}
+	mt = &MultiTarget{targets[0], targets, make([]float64, len(targets))}
+	cases := make([]int, 0, mt.Length())
+	for i := 0; i < mt.Length(); i++ {
+		if !mt.IsMissing(i) {
+			cases = append(cases, i)
+		}
+	}
+	for k, t := range targets {
+		mt.Scales[k] = 1.0
+		if scale {
+			if imp := t.Impurity(&cases, newCounter(t)); imp > 0.0 {
+				mt.Scales[k] = 1.0 / imp
+			}
+		}
+	}
+	return
+}
+
+//newCounter returns a class counter for categorical features and nil for numerical ones.
+func newCounter(f Feature) *[]int {
+	if f.NCats() == 0 {
+		return nil
+	}
+	counter := make([]int, f.NCats())
+	return &counter
+}
+
+//MultiTargetName returns the target name recorded for multiple targets.
+func MultiTargetName(names []string) string {
+	return strings.Join(names, ",")
+}
+
+//ParseMultiTargetName returns the names of the targets of a forest grown with a
+//MultiTarget or nil if there is only one target.
+func ParseMultiTargetName(name string) (names []string) {
+	if _, _, ok := ParseSurvivalTargetName(name); ok || !strings.Contains(name, ",") {
+		return nil
+	}
+	return strings.Split(name, ",")
+}
+
+//GetName returns the names of the component targets joined with commas.
+func (mt *MultiTarget) GetName() string {
+	names := make([]string, 0, len(mt.Targets))
+	for _, t := range mt.Targets {
+		names = append(names, t.GetName())
+	}
+	return MultiTargetName(names)
+}
+
+//NCats returns 0 as a MultiTarget has no categories of its own.
+func (mt *MultiTarget) NCats() int {
+	return 0
+}
+
+//IsMissing returns true if case i is missing in any of the component targets.
+func (mt *MultiTarget) IsMissing(i int) bool {
+	for _, t := range mt.Targets {
+		if t.IsMissing(i) {
+			return true
+		}
+	}
+	return false
+}
+
+//GetStr returns the component target's values of case i seperated by tabs.
+func (mt *MultiTarget) GetStr(i int) string {
+	values := make([]string, 0, len(mt.Targets))
+	for _, t := range mt.Targets {
+		values = append(values, t.GetStr(i))
+	}
+	return strings.Join(values, "\t")
+}
+
+//Copy returns a MultiTarget with copies of the component targets.
+func (mt *MultiTarget) Copy() Feature {
+	targets := make([]Feature, 0, len(mt.Targets))
+	for _, t := range mt.Targets {
+		targets = append(targets, t.Copy())
+	}
+	scales := make([]float64, len(mt.Scales))
+	copy(scales, mt.Scales)
+	return &MultiTarget{targets[0], targets, scales}
+}
+
+//CopyInTo copies the component targets into those of copyf which must be a MultiTarget.
+func (mt *MultiTarget) CopyInTo(copyf Feature) {
+	for k, t := range mt.Targets {
+		t.CopyInTo(copyf.(*MultiTarget).Targets[k])
+	}
+}
+
+//Impurity returns the scaled sum of the component target's impurities. It allocates a
+//counter for each categorical component so ImpurityFromAllocs should be used when
+//searching for splits.
+func (mt *MultiTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	for k, t := range mt.Targets {
+		e += mt.Scales[k] * t.Impurity(cases, newCounter(t))
+	}
+	return
+}
+
+//ImpurityFromAllocs returns the scaled sum of the component target's impurities
+//calculated using the class counters of the component allocations in allocs.Sub.
+func (mt *MultiTarget) ImpurityFromAllocs(cases *[]int, allocs *BestSplitAllocs) (e float64) {
+	for k, t := range mt.Targets {
+		e += mt.Scales[k] * t.Impurity(cases, allocs.Sub[k].Counter)
+	}
+	return
+}
+
+//SplitImpurity returns the scaled sum of the component target's split impurities
+//calculated using the component allocations in allocs.Sub.
+func (mt *MultiTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	for k, t := range mt.Targets {
+		impurityDecrease += mt.Scales[k] * t.SplitImpurity(l, r, m, allocs.Sub[k])
+	}
+	return
+}
+
+//UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l as in learning from numerical variables.
+//Here it calls the component target's UpdateSImpFromAllocs so their iterative updates are used.
+func (mt *MultiTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	for k, t := range mt.Targets {
+		impurityDecrease += mt.Scales[k] * t.UpdateSImpFromAllocs(l, r, m, allocs.Sub[k], movedRtoL)
+	}
+	return
+}
+
+//FindPredicted returns the predictions of each of the component targets seperated by
+//semicolons.
+func (mt *MultiTarget) FindPredicted(cases []int) (pred string) {
+	preds := make([]string, 0, len(mt.Targets))
+	for _, t := range mt.Targets {
+		preds = append(preds, t.FindPredicted(cases))
+	}
+	return strings.Join(preds, ";")
+}
+
+/*
+MultiBallotBox tallies the votes of trees grown with a MultiTarget using a
+NumBallotBox or CatBallotBox for each component target. Tally returns the tallies of
+each component seperated by tabs.
+*/
+type MultiBallotBox struct {
+	Boxes []VoteTallyer
+}
+
+//NewMultiBallotBox creates a MultiBallotBox for size cases with a ballot box for each of
+//the features (numerical or categorical).
+func NewMultiBallotBox(size int, features []Feature) *MultiBallotBox {
+	bb := &MultiBallotBox{make([]VoteTallyer, 0, len(features))}
+	for _, f := range features {
+		if f.NCats() == 0 {
+			bb.Boxes = append(bb.Boxes, NewNumBallotBox(size))
+		} else {
+			bb.Boxes = append(bb.Boxes, NewCatBallotBox(size))
+		}
+	}
+	return bb
+}
+
+//Vote splits a prediction made by MultiTarget.FindPredicted and votes for each part in
+//the corresponding ballot box.
+func (bb *MultiBallotBox) Vote(casei int, pred string, weight float64) {
+	preds := strings.Split(pred, ";")
+	if len(preds) != len(bb.Boxes) {
+		return
+	}
+	for k, box := range bb.Boxes {
+		box.Vote(casei, preds[k], weight)
+	}
+}
+
+//Tally returns the tally of case i in each ballot box seperated by tabs.
+func (bb *MultiBallotBox) Tally(i int) string {
+	tallies := make([]string, 0, len(bb.Boxes))
+	for _, box := range bb.Boxes {
+		tallies = append(tallies, box.Tally(i))
+	}
+	return strings.Join(tallies, "\t")
+}
+
+//TallyErrors returns the error of each ballot box vs the corresponding component of
+//feature, which must be a MultiTarget.
+func (bb *MultiBallotBox) TallyErrors(feature Feature) (errors []float64) {
+	for k, t := range feature.(*MultiTarget).Targets {
+		errors = append(errors, bb.Boxes[k].TallyError(t))
+	}
+	return
+}
+
+//TallyError returns the mean of the errors returned by TallyErrors.
+func (bb *MultiBallotBox) TallyError(feature Feature) (e float64) {
+	errors := bb.TallyErrors(feature)
+	for _, v := range errors {
+		e += v
+	}
+	return e / float64(len(errors))
+}
@@ -0,0 +1,89 @@
+package CloudForest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMultiTarget(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	//N:Y depends on N:X1 and C:Z on N:X2
+	n := 200
+	var afm bytes.Buffer
+	rows := make([][]string, 6)
+	for i := 0; i < n; i++ {
+		x1, x2, x3 := rng.Float64(), rng.Float64(), rng.Float64()
+		z := "a"
+		if x2 > 0.5 {
+			z = "b"
+		}
+		for k, v := range []interface{}{fmt.Sprintf("c%v", i), 10.0 * x1, z, x1, x2, x3} {
+			rows[k] = append(rows[k], fmt.Sprintf("%v", v))
+		}
+	}
+	for k, name := range []string{".", "N:Y", "C:Z", "N:X1", "N:X2", "N:X3"} {
+		fmt.Fprintf(&afm, "%v\t%v\n", name, strings.Join(rows[k], "\t"))
+	}
+	fm := ParseAFM(&afm)
+
+	names := ParseMultiTargetName("N:Y,C:Z")
+	if len(names) != 2 || ParseMultiTargetName("N:Y") != nil || ParseMultiTargetName(SurvivalTargetName("N:Y", "C:Z")) != nil {
+		t.Errorf("Multiple target names parsed incorrectly.")
+	}
+	mt, err := NewMultiTarget(fm, names, true)
+	if err != nil {
+		t.Fatalf("Error creating MultiTarget: %v", err)
+	}
+	if mt.GetName() != "N:Y,C:Z" {
+		t.Errorf("MultiTarget name is %v.", mt.GetName())
+	}
+
+	allocs := NewBestSplitAllocs(n, mt)
+	if len(allocs.Sub) != 2 {
+		t.Fatalf("Allocations created for %v component targets not 2.", len(allocs.Sub))
+	}
+	l, r := []int{0, 1, 2, 3}, []int{4, 5, 6, 7, 8}
+	expected := 0.0
+	for k, f := range mt.Targets {
+		expected += mt.Scales[k] * f.SplitImpurity(&l, &r, nil, NewBestSplitAllocs(n, f))
+	}
+	if imp := mt.SplitImpurity(&l, &r, nil, allocs); math.Abs(imp-expected) > 1e-12 {
+		t.Errorf("Split impurity %v isn't the scaled sum of component impurities %v.", imp, expected)
+	}
+	if imp := allocs.Impurity(mt, &l); imp != mt.Impurity(&l, nil) {
+		t.Errorf("Impurity from allocations %v isn't %v.", imp, mt.Impurity(&l, nil))
+	}
+	if a := testing.AllocsPerRun(10, func() { allocs.Impurity(mt, &l) }); a > 0 {
+		t.Errorf("Impurity from allocations made %v allocations.", a)
+	}
+
+	forest := GrowRandomForest(fm, mt, []int{2, 3, 4}, n, 2, 20, 1, false, false, false, false, nil)
+	for _, tree := range forest.Trees {
+		tree.Target = forest.Target
+	}
+	var sf bytes.Buffer
+	NewForestWriter(&sf).WriteForest(forest)
+	read, err := NewForestReader(&sf).ReadForest()
+	if err != nil {
+		t.Fatalf("Error reading multivariate forest: %v", err)
+	}
+	if read.Target != "N:Y,C:Z" {
+		t.Errorf("Read forest target is %v.", read.Target)
+	}
+
+	bb := NewMultiBallotBox(n, mt.Targets)
+	for _, tree := range read.Trees {
+		tree.Vote(fm, bb)
+	}
+	errors := bb.TallyErrors(mt)
+	if len(errors) != 2 || !(errors[0] < 0.1) || !(errors[1] < 0.1) {
+		t.Errorf("Multivariate forest has training errors %v.", errors)
+	}
+	if tally := strings.Split(bb.Tally(0), "\t"); len(tally) != 2 || tally[1] != mt.Targets[1].GetStr(0) {
+		t.Errorf("Tally %v doesn't have a column per target.", tally)
+	}
+}
@@ -0,0 +1,223 @@
+package CloudForest
+
+import (
+	"math"
+)
+
+/*
+MultiwaySplit is the coded split of a DenseCatFeature into one branch per category, as in
+C4.5, instead of two groups of categories. Cats holds the category of each branch. Cases
+with a category that isn't in Cats (like out of bag cases with a category no in bag case
+at the node had) go down the Fallback branch, the one with the most in bag cases.
+*/
+type MultiwaySplit struct {
+	Cats     []int
+	Fallback int
+}
+
+/*
+groupCases groups cases in place into one contiguous group per branch, in order, followed
+by the cases for which branchOf returns -1 and returns slices pointing at each group and at
+the missing cases. buf is used as scratch space and must be at least as long as cases.
+*/
+func groupCases(cases []int, buf []int, nbranches int, branchOf func(i int) int) (branches [][]int, m []int) {
+	starts := make([]int, nbranches+2)
+	buf = buf[:len(cases)]
+	copy(buf, cases)
+	for _, c := range buf {
+		b := branchOf(c)
+		if b < 0 {
+			b = nbranches
+		}
+		starts[b+1]++
+	}
+	for b := 1; b < len(starts); b++ {
+		starts[b] += starts[b-1]
+	}
+	next := append([]int(nil), starts...)
+	for _, c := range buf {
+		b := branchOf(c)
+		if b < 0 {
+			b = nbranches
+		}
+		cases[next[b]] = c
+		next[b]++
+	}
+	branches = make([][]int, nbranches)
+	for b := range branches {
+		branches[b] = cases[starts[b]:starts[b+1]]
+	}
+	m = cases[starts[nbranches]:]
+	return
+}
+
+//SplitMultiway does an inplace split of cases into one branch per category of a MultiwaySplit
+//and returns slices pointing into the origional cases slice.
+func (f *DenseCatFeature) SplitMultiway(split *MultiwaySplit, cases []int) (branches [][]int, m []int) {
+	branchOf := make([]int, f.NCats())
+	for i := range branchOf {
+		branchOf[i] = split.Fallback
+	}
+	for b, cat := range split.Cats {
+		branchOf[cat] = b
+	}
+	return groupCases(cases, make([]int, len(cases)), len(split.Cats), func(i int) int {
+		if f.Missing[i] {
+			return -1
+		}
+		return branchOf[f.CatData[i]]
+	})
+}
+
+//Multiway checks if the splitter sends cases down one branch per category in Levels.
+func (s *Splitter) Multiway() bool {
+	return s.Levels != nil
+}
+
+/*
+SplitMultiway does an inplace split of cases by a multiway splitter into one branch per
+category in Levels. Cases with a category that isn't in Levels (as can happen with new
+data) go down the Fallback branch and missing cases are returned as missing.
+*/
+func (s *Splitter) SplitMultiway(fm *FeatureMatrix, cases []int) (branches [][]int, m []int) {
+	f := fm.Data[fm.Map[s.Feature]].(CatFeature)
+	levels := make(map[string]int, len(s.Levels))
+	for b, level := range s.Levels {
+		levels[level] = b
+	}
+	branchOf := make(map[int]int)
+	return groupCases(cases, make([]int, len(cases)), len(s.Levels), func(i int) int {
+		if f.IsMissing(i) {
+			return -1
+		}
+		cat := f.Geti(i)
+		b, ok := branchOf[cat]
+		if !ok {
+			b, ok = levels[f.NumToCat(cat)]
+			if !ok {
+				b = s.Fallback
+			}
+			branchOf[cat] = b
+		}
+		return b
+	})
+}
+
+/*
+multiwayGainRatio returns the gain ratio of splitting cases with impurity parentImp into
+branches and the cases missing the feature. It scores the split the same way as
+DenseCatFeature.BestSplit does. The missing cases count towards the weighted mean
+impurity of the children, as in BestSplit's adjustment for missing cases. The split
+information is only that of the branches.
+*/
+func multiwayGainRatio(target Target, parentImp float64, branches [][]int, missing []int, allocs *BestSplitAllocs) float64 {
+	impurityDecrease, _ := multiwayDecrease(target, parentImp, append(branches, missing), allocs)
+	if impurityDecrease == minImp {
+		return minImp
+	}
+	return impurityDecrease / multiwaySplitInfo(branches)
+}
+
+/*
+multiwayDecrease returns the impurity decrease of splitting cases with impurity parentImp
+into branches (the weighted mean of the branches' impurities subtracted from parentImp)
+and their split information as returned by multiwaySplitInfo.
+*/
+func multiwayDecrease(target Target, parentImp float64, branches [][]int, allocs *BestSplitAllocs) (impurityDecrease float64, splitInfo float64) {
+	total := 0
+	for _, b := range branches {
+		total += len(b)
+	}
+	if total == 0 {
+		return minImp, 1.0
+	}
+	imp := 0.0
+	for _, b := range branches {
+		if len(b) == 0 {
+			continue
+		}
+		imp += float64(len(b)) / float64(total) * allocs.Impurity(target, &b)
+	}
+	return parentImp - imp, multiwaySplitInfo(branches)
+}
+
+//multiwaySplitInfo returns the split information, -sum(p*log2(p)) over the fraction p of
+//cases in each branch, or one if that is more.
+func multiwaySplitInfo(branches [][]int) (splitInfo float64) {
+	total := 0
+	for _, b := range branches {
+		total += len(b)
+	}
+	for _, b := range branches {
+		if len(b) == 0 {
+			continue
+		}
+		p := float64(len(b)) / float64(total)
+		splitInfo -= p * math.Log2(p)
+	}
+	return math.Max(splitInfo, 1.0)
+}
+
+/*
+BestMultiwaySplit splits cases into one branch per category present, as in C4.5, if there
+are between 3 and allocs.Multiway such categories and each branch would have at least
+leafSize cases. Otherwise it returns a nil split and BestSplit searches for a binary split
+instead.
+
+It returns the decrease in impurity and the split information (at least 1 bit as for a
+balanced binary split). BestSplit adjusts the decrease for missing cases and divides it by
+the split information to get C4.5's gain ratio which penalizes splits into many small
+branches so they are only chosen over binary splits that give a similar decrease if they
+separate the target better.
+
+It expects to be provided cases for which the feature is not missing.
+*/
+func (f *DenseCatFeature) BestMultiwaySplit(target Target,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (split *MultiwaySplit, impurityDecrease float64, splitInfo float64, constant bool) {
+
+	impurityDecrease = minImp
+	splitInfo = 1.0
+	nCats := f.NCats()
+	if nCats > allocs.Multiway || len(*cases) == 0 {
+		return
+	}
+
+	counts := make([]int, nCats)
+	for _, c := range *cases {
+		counts[f.CatData[c]]++
+	}
+	split = &MultiwaySplit{make([]int, 0, nCats), 0}
+	for cat, count := range counts {
+		if count > 0 {
+			if count < leafSize {
+				return nil, minImp, 1.0, false
+			}
+			if len(split.Cats) > 0 && count > counts[split.Cats[split.Fallback]] {
+				split.Fallback = len(split.Cats)
+			}
+			split.Cats = append(split.Cats, cat)
+		}
+	}
+	constant = len(split.Cats) < 2
+	if len(split.Cats) < 3 {
+		return nil, minImp, 1.0, constant
+	}
+
+	branchOf := counts
+	for cat := range branchOf {
+		branchOf[cat] = -1
+	}
+	for b, cat := range split.Cats {
+		branchOf[cat] = b
+	}
+	grouped := append((*allocs.Left)[0:0], *cases...)
+	branches, _ := groupCases(grouped, *allocs.Right, len(split.Cats), func(i int) int {
+		return branchOf[f.CatData[i]]
+	})
+
+	impurityDecrease, splitInfo = multiwayDecrease(target, parentImp, branches, allocs)
+	return
+}
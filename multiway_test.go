@@ -0,0 +1,131 @@
+package CloudForest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestMultiwaySplits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 1000
+	levels := []string{"a", "b", "c", "d"}
+	c := make([]string, 0, n)
+	x := make([]string, 0, n)
+	y := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		l := rng.Intn(len(levels))
+		c = append(c, levels[l])
+		x = append(x, fmt.Sprintf("%v", rng.Float64()))
+		y = append(y, strings.ToUpper(levels[l]))
+	}
+	fm := parseRows([]string{"C:Y", "C:L", "N:X"}, [][]string{y, c, x})
+	target := fm.Data[0].(Target)
+
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+
+	//the root should split into one branch per level of the feature driving the target
+	allocs := NewBestSplitAllocs(n, target)
+	allocs.Multiway = 5
+	allocs.Presort = NewPresortedCases(fm, NewPresortedIndex(fm, []int{2}))
+	tree := NewTree()
+	tree.Grow(fm, target, cases, []int{1, 2}, nil, 2, 1, false, false, false, false, nil, nil, allocs)
+	root := tree.Root
+	if !root.Splitter.Multiway() || root.Splitter.Feature != "C:L" || len(root.Branches) != len(levels) {
+		t.Fatalf("Root splitter %v with %v branches isn't a multiway split of C:L.", root.Splitter, len(root.Branches))
+	}
+	if root.Left != nil || root.Right != nil || root.IsLeaf() {
+		t.Error("Multiway root has left or right children or is a leaf.")
+	}
+	bb := NewCatBallotBox(n)
+	tree.Vote(fm, bb)
+	if e := bb.TallyError(fm.Data[0]); e > 0.0 {
+		t.Errorf("Multiway tree has training error %v.", e)
+	}
+
+	//features with more categories than allowed get binary splits
+	allocs.Multiway = 3
+	binary := NewTree()
+	binary.Grow(fm, target, cases, []int{1}, nil, 1, 1, false, false, false, false, nil, nil, allocs)
+	if binary.Root.Splitter.Multiway() || binary.Root.Branches != nil {
+		t.Errorf("Feature with 4 categories was split multiway with Multiway=3: %v", binary.Root.Splitter)
+	}
+
+	//multiway splitters should be written to and read from .sf files
+	buf := new(bytes.Buffer)
+	NewForestWriter(buf).WriteTree(tree, 0)
+	if !strings.Contains(buf.String(), "SPLITTERTYPE=MULTIWAY") || !strings.Contains(buf.String(), "NODE=*B3") ||
+		!strings.Contains(buf.String(), fmt.Sprintf("FALLBACK=%v", root.Splitter.Fallback)) {
+		t.Fatalf("Multiway splitter not written:\n%v", buf.String())
+	}
+	readtree, _, err := NewForestReader(buf).ReadTree()
+	if err != nil && readtree == nil {
+		t.Fatal(err)
+	}
+	if len(readtree.Root.Branches) != len(levels) || strings.Join(readtree.Root.Splitter.Levels, ":") != strings.Join(root.Splitter.Levels, ":") ||
+		readtree.Root.Splitter.Fallback != root.Splitter.Fallback {
+		t.Fatalf("Read splitter %v with %v branches doesn't match %v.", readtree.Root.Splitter, len(readtree.Root.Branches), root.Splitter)
+	}
+	readbb := NewCatBallotBox(n)
+	readtree.Vote(fm, readbb)
+	for i := 0; i < n; i++ {
+		if readbb.Tally(i) != bb.Tally(i) {
+			t.Fatalf("Read tree predicts %v for case %v not %v.", readbb.Tally(i), i, bb.Tally(i))
+		}
+	}
+
+	//categories not seen in training go down the fallback branch and missing values to missing
+	newfm := parseRows([]string{"C:Y", "C:L", "N:X"}, [][]string{{"A", "A", "A"}, {"e", "NA", "b"}, {"0", "0", "0"}})
+	bs, m := readtree.Root.Splitter.SplitMultiway(newfm, []int{0, 1, 2})
+	fallback := bs[readtree.Root.Splitter.Fallback]
+	if len(m) != 1 || len(bs) != len(levels) || len(fallback) == 0 || fallback[0] != 0 {
+		t.Errorf("Multiway split of new data gave branches %v and missing %v.", bs, m)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[c[i]]++
+	}
+	for _, level := range root.Splitter.Levels {
+		if counts[level] > counts[root.Splitter.Levels[root.Splitter.Fallback]] {
+			t.Errorf("Fallback branch %v isn't the largest.", root.Splitter.Fallback)
+		}
+	}
+	readbb = NewCatBallotBox(3)
+	readtree.Vote(newfm, readbb)
+	if readbb.Tally(0) == "NA" {
+		t.Error("Case with a new category got no vote.")
+	}
+
+	//the gain ratio should account for missing cases before dividing by the split information
+	c[0], c[1] = "NA", "NA"
+	fm = parseRows([]string{"C:Y", "C:L", "N:X"}, [][]string{y, c, x})
+	target = fm.Data[0].(Target)
+	f := fm.Data[1].(*DenseCatFeature)
+	allocs.Multiway = 5
+	parentImp := allocs.Impurity(target, &cases)
+	_, gainRatio, _ := f.BestSplit(target, &cases, parentImp, 1, allocs)
+	missing := []int{0, 1}
+	nonmissing := make([]int, 0, n)
+	for i := 2; i < n; i++ {
+		nonmissing = append(nonmissing, i)
+	}
+	multiway, decrease, splitInfo, _ := f.BestMultiwaySplit(target, &nonmissing, allocs.Impurity(target, &nonmissing), 1, allocs)
+	adjusted := parentImp + (float64(n-2)*(decrease-allocs.Impurity(target, &nonmissing))-2.0*allocs.Impurity(target, &missing))/float64(n)
+	if multiway == nil || math.Abs(gainRatio-adjusted/splitInfo) > 1e-9 {
+		t.Errorf("Gain ratio %v isn't the missing adjusted decrease %v over the split information %v.", gainRatio, adjusted, splitInfo)
+	}
+
+	//scoring the split out of bag on the same cases should handle missing cases the same way
+	oob := append([]int(nil), cases...)
+	candidates := []int{1}
+	_, split, oobRatio, _ := fm.BestSplitter(target, &cases, &candidates, 1, &oob, 1, false, false, true, allocs, 0)
+	if _, ok := split.(*MultiwaySplit); !ok || math.Abs(oobRatio-gainRatio) > 1e-9 {
+		t.Errorf("Out of bag gain ratio %v of split %v doesn't match the in bag gain ratio %v.", oobRatio, split, gainRatio)
+	}
+}
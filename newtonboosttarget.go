@@ -0,0 +1,258 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+NewtonLoss is a Loss with a second derivative for use in second order (Newton) boosting by
+NewtonBoostTarget.
+*/
+type NewtonLoss interface {
+	Loss
+	//Hessian returns the second derivative of the loss with respect to f.
+	Hessian(y float64, f float64) float64
+}
+
+func (l SquaredLoss) Hessian(y float64, f float64) float64 {
+	return 1.0
+}
+
+func (l HuberLoss) Hessian(y float64, f float64) float64 {
+	if math.Abs(y-f) <= l.Delta {
+		return 1.0
+	}
+	return 0.0
+}
+
+/*
+NewtonBoostTarget wraps a GradBoostTarget for second order (Newton) boosting with
+regularized leaves in the style of XGBoost.
+
+For a set of cases with sum of gradients G and sum of hessians H the optimal leaf value
+and the corresponding decrease in loss (the structure score) are:
+
+	w = -T(G)/(H+Lambda)
+	score = -1/2 * T(G)^2/(H+Lambda)
+
+where T(G) = sign(G)*max(|G|-Alpha,0) applies the L1 penalty and Lambda is the L2 penalty.
+Impurity is the score divided by the number of cases so the impurity decrease of a split is
+the gain:
+
+	1/2 * (T(GL)^2/(HL+Lambda) + T(GR)^2/(HR+Lambda) - T(G)^2/(H+Lambda)) - Gamma
+
+divided by the number of cases. Since scores are negative and nodes with non positive
+impurity aren't split, a constant offset per case (an upper bound on -score/n) is added to
+impurities which cancels out of impurity decreases. Splits that don't reduce the loss by
+more than Gamma aren't made. Splits that would leave less than MinChildWeight of hessian on
+either side are also rejected which can be used as an alternative to leafSize.
+
+The sums of the negative gradients (the residuals of the embeded GradBoostTarget) and of
+the hessians are stored in allocs (in Lsum and Lsum_sqr etc.) so they are updated
+incrementally as cases are moved from right to left while searching numerical splits.
+
+NewtonBoostTarget is not thread safe; calls to BoostTree should be serialized.
+*/
+type NewtonBoostTarget struct {
+	*GradBoostTarget
+	Newton         NewtonLoss
+	Lambda         float64
+	Alpha          float64
+	Gamma          float64
+	MinChildWeight float64
+	Hess           []float64
+	offset         float64
+}
+
+/*
+NewNewtonBoostTarget creates a Newton boosting target from a gradient boosting target
+with the specified penalties. It returns an error if the gradient boosting target's loss
+doesn't have a hessian or lambda isn't positive. Lambda must be positive as cases can
+have zero hessian (like those outside of the Huber loss's delta) and without it the
+offset wouldn't bound the scores of sets of cases including them.
+*/
+func NewNewtonBoostTarget(gbt *GradBoostTarget, lambda float64, alpha float64, gamma float64, minChildWeight float64) (nbt *NewtonBoostTarget, err error) {
+	newton, ok := gbt.Loss.(NewtonLoss)
+	if !ok {
+		return nil, fmt.Errorf("Newton boosting requires a loss with a hessian not %T", gbt.Loss)
+	}
+	if lambda <= 0.0 {
+		return nil, fmt.Errorf("Newton boosting requires a positive lambda not %v", lambda)
+	}
+	nbt = &NewtonBoostTarget{gbt, newton, lambda, alpha, gamma, minChildWeight, make([]float64, len(gbt.Pred)), 0.0}
+	nbt.updateHessians()
+	return
+}
+
+/*
+updateHessians sets the hessian of each non missing case at its current prediction and
+recalculates the impurity offset as the largest 1/2 * r^2/(h+Lambda/N) over the N cases
+with residual r and hessian h. By the Cauchy-Schwarz inequality this bounds -score/n for
+any set of n cases including those with zero hessian since Lambda is positive.
+*/
+func (f *NewtonBoostTarget) updateHessians() {
+	f.offset = 0.0
+	w := f.Lambda / float64(len(f.Pred))
+	for i, p := range f.Pred {
+		if !f.Y.IsMissing(i) {
+			f.Hess[i] = f.Newton.Hessian(f.Y.Get(i), p)
+			r := f.Get(i)
+			f.offset = math.Max(f.offset, 0.5*r*r/(f.Hess[i]+w))
+		}
+	}
+}
+
+//sums returns the sum of the negative gradients and hessians of the non missing cases.
+func (f *NewtonBoostTarget) sums(cases *[]int) (g float64, h float64) {
+	for _, i := range *cases {
+		if !f.Y.IsMissing(i) {
+			g += f.Get(i)
+			h += f.Hess[i]
+		}
+	}
+	return
+}
+
+//score returns the structure score, -1/2 * T(g)^2/(h+Lambda), of a leaf with sums g and h.
+func (f *NewtonBoostTarget) score(g float64, h float64) float64 {
+	if h+f.Lambda <= 0.0 {
+		return 0.0
+	}
+	t := f.threshold(g)
+	return -0.5 * t * t / (h + f.Lambda)
+}
+
+//threshold applies the L1 penalty returning sign(g)*max(|g|-Alpha,0).
+func (f *NewtonBoostTarget) threshold(g float64) float64 {
+	switch {
+	case g > f.Alpha:
+		return g - f.Alpha
+	case g < -f.Alpha:
+		return g + f.Alpha
+	}
+	return 0.0
+}
+
+//LeafValue returns the regularized Newton step, T(g)/(h+Lambda), for the sums of the
+//negative gradients and hessians of a leaf's cases.
+func (f *NewtonBoostTarget) LeafValue(g float64, h float64) float64 {
+	if h+f.Lambda <= 0.0 {
+		return 0.0
+	}
+	return f.threshold(g) / (h + f.Lambda)
+}
+
+//NewtonBoostTarget.Impurity returns the structure score of the cases divided by their number
+//plus the offset.
+func (f *NewtonBoostTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	if len(*cases) == 0 {
+		return 0.0
+	}
+	g, h := f.sums(cases)
+	return f.offset + f.score(g, h)/float64(len(*cases))
+}
+
+//splitScore returns the total score of a split from the sums in allocs plus Gamma for each
+//new leaf or +Inf if a side has less than MinChildWeight hessian.
+func (f *NewtonBoostTarget) splitScore(m *[]int, allocs *BestSplitAllocs) (total float64) {
+	if allocs.Lsum_sqr < f.MinChildWeight || allocs.Rsum_sqr < f.MinChildWeight {
+		return math.Inf(1)
+	}
+	total = f.score(allocs.Lsum, allocs.Lsum_sqr) + f.score(allocs.Rsum, allocs.Rsum_sqr) + f.Gamma
+	if m != nil && len(*m) > 0 {
+		total += f.score(allocs.Msum, allocs.Msum_sqr) + f.Gamma
+	}
+	return
+}
+
+/*
+NewtonBoostTarget.SplitImpurity returns the total structure score of the left, right and
+missing cases plus Gamma for each new leaf divided by the total number of cases plus the
+offset. It returns +Inf (so the split is never chosen) if either side has less than
+MinChildWeight hessian.
+*/
+func (f *NewtonBoostTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	n := float64(len(*l) + len(*r))
+	allocs.Lsum, allocs.Lsum_sqr = f.sums(l)
+	allocs.Rsum, allocs.Rsum_sqr = f.sums(r)
+	if m != nil && len(*m) > 0 {
+		n += float64(len(*m))
+		allocs.Msum, allocs.Msum_sqr = f.sums(m)
+	}
+	return f.offset + f.splitScore(m, allocs)/n
+}
+
+//UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l as in learning from numerical variables.
+//It moves the gradient and hessian sums stored in allocs and recalculates the score from them.
+func (f *NewtonBoostTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	g, h := f.sums(movedRtoL)
+	allocs.Lsum += g
+	allocs.Rsum -= g
+	allocs.Lsum_sqr += h
+	allocs.Rsum_sqr -= h
+
+	n := float64(len(*l) + len(*r))
+	if m != nil {
+		n += float64(len(*m))
+	}
+	return f.offset + f.splitScore(m, allocs)/n
+}
+
+//NHistStats returns the number of statistics, the count and the sums of the negative
+//gradients and hessians, used by NewtonBoostTarget as a HistogramTarget.
+func (f *NewtonBoostTarget) NHistStats() int {
+	return 3
+}
+
+//AddHistStats adds 1 and the negative gradient and hessian of case i to stats.
+func (f *NewtonBoostTarget) AddHistStats(i int, stats []float64) {
+	stats[0]++
+	if !f.Y.IsMissing(i) {
+		stats[1] += f.Get(i)
+		stats[2] += f.Hess[i]
+	}
+}
+
+//HistImpurity returns the impurity of cases with the summed stats as Impurity would.
+func (f *NewtonBoostTarget) HistImpurity(stats []float64) float64 {
+	if stats[0] == 0.0 {
+		return 0.0
+	}
+	return f.offset + f.score(stats[1], stats[2])/stats[0]
+}
+
+//HistSplitImpurity returns the impurity of a split with the summed left, right and missing
+//(which may be nil) stats as SplitImpurity would.
+func (f *NewtonBoostTarget) HistSplitImpurity(l []float64, r []float64, m []float64) float64 {
+	if l[2] < f.MinChildWeight || r[2] < f.MinChildWeight {
+		return math.Inf(1)
+	}
+	n := l[0] + r[0]
+	total := f.score(l[1], l[2]) + f.score(r[1], r[2]) + f.Gamma
+	if m != nil && m[0] > 0.0 {
+		n += m[0]
+		total += f.score(m[1], m[2]) + f.Gamma
+	}
+	return f.offset + total/n
+}
+
+/*
+BoostTree sets the prediction of each node of a tree grown against the gradients to the
+regularized Newton step for the in bag cases that fall in it (clipped to the node's Bounds
+if it was grown with monotone constraints), adds the step of the leaf each case falls in
+to the predictions of all cases, updates the residuals and hessians and returns the
+weight (LearnRate) the tree should be given.
+*/
+func (f *NewtonBoostTarget) BoostTree(tree *Tree, fm *FeatureMatrix, inbag []int) (weight float64) {
+	boostNodes(tree, fm, inbag, func(n *Node, cases []int) (step float64, pred string) {
+		step = n.Bounds.Clip(f.LeafValue(f.sums(&cases)))
+		return step, fmt.Sprintf("%v", step)
+	}, func(i int, step float64) {
+		f.Pred[i] += f.LearnRate * step
+	})
+
+	f.updateResiduals()
+	f.updateHessians()
+	return f.LearnRate
+}
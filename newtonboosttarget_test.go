@@ -0,0 +1,103 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNewtonBoost(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 300
+	x := make([]string, 0, n)
+	y := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		v := rng.Float64()
+		x = append(x, fmt.Sprintf("%v", v))
+		y = append(y, fmt.Sprintf("%v", 10.0+math.Sin(6.0*v)+0.1*rng.NormFloat64()))
+	}
+	fm := parseRows([]string{"N:Y", "N:X"}, [][]string{y, x})
+	target := fm.Data[0].(NumFeature)
+
+	if _, err := NewNewtonBoostTarget(NewGradBoostTarget(target, AbsoluteLoss{}, 0.1), 1.0, 0.0, 0.0, 0.0); err == nil {
+		t.Error("Newton boosting accepted a loss without a hessian.")
+	}
+	if _, err := NewNewtonBoostTarget(NewGradBoostTarget(target, HuberLoss{0.5}, 0.1), 0.0, 0.0, 0.0, 0.0); err == nil {
+		t.Error("Newton boosting accepted a lambda of 0.")
+	}
+
+	//with only the L2 penalty the squared loss newton step is the mean residual shrunk by n/(n+lambda)
+	nbt, err := NewNewtonBoostTarget(NewGradBoostTarget(target, SquaredLoss{}, 0.1), 1.0, 0.0, 0.0, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []int{0, 1, 2, 3}
+	g, h := nbt.sums(&cases)
+	if math.Abs(nbt.LeafValue(g, h)-0.8*SquaredLoss{}.LeafValue(target, nbt.Pred, cases)) > 1e-9 || h != 4.0 {
+		t.Errorf("Newton step %v with hessian %v isn't the shrunk mean residual.", nbt.LeafValue(g, h), h)
+	}
+
+	//incremental updates should match recalculating the split
+	allocs := NewBestSplitAllocs(n, nbt)
+	l := []int{0, 1}
+	r := []int{2, 3, 4, 5}
+	nbt.SplitImpurity(&l, &r, nil, allocs)
+	l = []int{0, 1, 2}
+	r = []int{3, 4, 5}
+	moved := []int{2}
+	updated := nbt.UpdateSImpFromAllocs(&l, &r, nil, allocs, &moved)
+	if direct := nbt.SplitImpurity(&l, &r, nil, allocs); math.Abs(updated-direct) > 1e-9 {
+		t.Errorf("Updated split impurity %v doesn't match %v.", updated, direct)
+	}
+
+	//splits leaving too little hessian on a side or not reducing the loss by gamma are rejected
+	nbt.MinChildWeight = 4.0
+	if imp := nbt.SplitImpurity(&l, &r, nil, allocs); !math.IsInf(imp, 1) {
+		t.Errorf("Split with child hessian below the min child weight had impurity %v.", imp)
+	}
+	nbt.MinChildWeight = 0.0
+	nbt.Gamma = 1e9
+	forest := GrowRandomForest(fm, nbt, []int{1}, n, 1, 5, 1, false, false, false, false, nil)
+	for _, tree := range forest.Trees {
+		if tree.Root.Left != nil {
+			t.Error("Tree split despite a min split loss larger than any gain.")
+		}
+	}
+
+	for _, alpha := range []float64{0.0, 5.0} {
+		nbt, _ = NewNewtonBoostTarget(NewGradBoostTarget(target, SquaredLoss{}, 0.1), 1.0, alpha, 0.0, 1.0)
+		initial := nbt.TrainingLoss()
+		forest = GrowRandomForest(fm, nbt, []int{1}, n, 1, 100, 1, false, false, false, false, nil)
+		if final := nbt.TrainingLoss(); !(final < initial/2.0) {
+			t.Errorf("Newton boosting with alpha %v reduced the training loss from %v only to %v.", alpha, initial, final)
+		}
+		if forest.Type != GBTForest || forest.Intercept != nbt.Intercept {
+			t.Errorf("Newton boosted forest has type %v and intercept %v.", forest.Type, forest.Intercept)
+		}
+		bb := NewSumBallotBox(n, forest.Intercept)
+		for _, tree := range forest.Trees {
+			tree.Vote(fm, bb)
+		}
+		for i := 0; i < n; i++ {
+			if math.Abs(bb.TallyNum(i)-nbt.Pred[i]) > 1e-9 {
+				t.Errorf("Applied prediction %v for case %v doesn't match the training fit %v.", bb.TallyNum(i), i, nbt.Pred[i])
+				break
+			}
+		}
+	}
+
+	//impurities stay non negative when many huber cases have zero hessian
+	nbt, _ = NewNewtonBoostTarget(NewGradBoostTarget(target, HuberLoss{0.05}, 0.1), 1e-3, 0.0, 0.0, 0.0)
+	all := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		all = append(all, i)
+	}
+	for k := 0; k < 100; k++ {
+		SampleFirstN(&all, nil, n, 0)
+		subset := all[:1+rng.Intn(n)]
+		if imp := nbt.Impurity(&subset, nil); imp < 0.0 {
+			t.Fatalf("Impurity %v of %v cases with huber loss is negative.", imp, len(subset))
+		}
+	}
+}
@@ -12,7 +12,11 @@ type CodedRecursable func(*Node, *[]int, int, int) (int, interface{}, int)
 
 //A node of a decision tree.
 //Pred is a string containing either the category or a representation of a float
-//(less then ideal)
+//(less then ideal). Pred is set for interior nodes as well as leaves so that
+//predictions can be followed along the path a case takes through the tree.
+//Nodes with a multiway splitter have one child in Branches per category instead of
+//Left and Right. Bounds is set to the bounds monotone constraints put on the node's
+//predictions when they are used.
 type Node struct {
 	CodedSplit interface{}
 	Featurei   int
@@ -21,6 +25,13 @@ type Node struct {
 	Missing    *Node
 	Pred       string
 	Splitter   *Splitter
+	Branches   []*Node
+	Bounds     *Bounds
+}
+
+//IsLeaf checks if the node has no Left, Right or multiway children.
+func (n *Node) IsLeaf() bool {
+	return n.Left == nil && n.Right == nil && n.Branches == nil
 }
 
 //vist each child node with the supplied function
@@ -32,6 +43,11 @@ func (n *Node) Climb(c func(*Node)) {
 	if n.Right != nil {
 		n.Right.Climb(c)
 	}
+	for _, b := range n.Branches {
+		if b != nil {
+			b.Climb(c)
+		}
+	}
 	if n.Missing != nil {
 		n.Missing.Climb(c)
 	}
@@ -40,10 +56,11 @@ func (n *Node) Climb(c func(*Node)) {
 //Recurse is used to apply a Recursable function at every downstream node as the cases
 //specified by case []int are split using the data in fm *Featurematrix. Recursion
 //down a branch stops when a a node with n.Splitter == nil is reached. Recursion down
-//the Missing branch is only used if n.Missing!=nil.
+//the Missing branch is only used if n.Missing!=nil. Nodes with Branches recurse down
+//each branch instead of Left and Right.
 //For example votes can be tabulated using code like:
 //	t.Root.Recurse(func(n *Node, cases []int) {
-//		if n.Left == nil && n.Right == nil {
+//		if n.IsLeaf() {
 //			// I'm in a leaf node
 //			for i := 0; i < len(cases); i++ {
 //				bb.Vote(cases[i], n.Pred)
@@ -55,6 +72,20 @@ func (n *Node) Recurse(r Recursable, fm *FeatureMatrix, cases []int, depth int)
 	depth++
 	var ls, rs, ms []int
 	switch {
+	case n.Branches != nil:
+		var bs [][]int
+		if split, ok := n.CodedSplit.(*MultiwaySplit); ok {
+			bs, ms = fm.Data[n.Featurei].(*DenseCatFeature).SplitMultiway(split, cases)
+		} else {
+			bs, ms = n.Splitter.SplitMultiway(fm, cases)
+		}
+		for i, b := range n.Branches {
+			b.Recurse(r, fm, bs[i], depth)
+		}
+		if len(ms) > 0 && n.Missing != nil {
+			n.Missing.Recurse(r, fm, ms, depth)
+		}
+		return
 	case n.CodedSplit != nil:
 		ls, rs, ms = fm.Data[n.Featurei].Split(n.CodedSplit, cases)
 	case n.Splitter != nil:
@@ -72,8 +103,16 @@ func (n *Node) Recurse(r Recursable, fm *FeatureMatrix, cases []int, depth int)
 func (n *Node) CodedRecurse(r CodedRecursable, fm *FeatureMatrix, cases *[]int, depth int, nconstantsbefore int) {
 	fi, codedSplit, nconstants := r(n, cases, depth, nconstantsbefore)
 	depth++
-	if codedSplit != nil {
-		li, ri := fm.Data[fi].SplitPoints(codedSplit, cases)
+	if split, ok := codedSplit.(*MultiwaySplit); ok {
+		bs, m := fm.Data[fi].(*DenseCatFeature).SplitMultiway(split, *cases)
+		for i, b := range n.Branches {
+			b.CodedRecurse(r, fm, &bs[i], depth, nconstants)
+		}
+		if len(m) > 0 && n.Missing != nil {
+			n.Missing.CodedRecurse(r, fm, &m, depth, nconstants)
+		}
+	} else if codedSplit != nil {
+		li, ri := splitPoints(fm, fi, codedSplit, cases)
 		cs := (*cases)[:li]
 		n.Left.CodedRecurse(r, fm, &cs, depth, nconstants)
 		cs = (*cases)[ri:]
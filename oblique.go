@@ -0,0 +1,142 @@
+package CloudForest
+
+import (
+	"math"
+	"math/rand"
+)
+
+/*
+ObliqueSplits searches for oblique splits on random linear combinations of numerical
+features as in Breiman's Forest-RC. At each node NCombinations combinations of NFeatures
+randomly chosen features, with coefficients drawn uniformly from [-1,1], are projected
+into a scratch DenseNumFeature whose BestSplit is used to find the best threshold with
+the target's usual impurity. Coefficients are divided by the standard deviation of their
+feature so features on different scales contribute comparably and the resulting weights
+apply to the unscaled values. Cases missing any of a combination's features are treated
+as missing.
+
+An ObliqueSplits should be set as the Oblique field of a BestSplitAllocs. Tree.Grow then
+uses the best oblique split found at a node if it beats the best split on the candidate
+features. Like the rest of BestSplitAllocs it should only be used by a single go routine.
+*/
+type ObliqueSplits struct {
+	NCombinations int
+	NFeatures     int
+	Features      []int
+	Scales        []float64
+	proj          *DenseNumFeature
+	picked        []int
+	fs            []NumFeature
+	weights       []float64
+}
+
+/*
+NewObliqueSplits creates an ObliqueSplits drawing combinations of nFeatures of the specified
+features that are numerical and not constant. nFeatures is limited to at least two and at most the
+number of such features. It returns nil if there are less than two of them.
+*/
+func NewObliqueSplits(fm *FeatureMatrix, features []int, nCombinations int, nFeatures int) (o *ObliqueSplits) {
+	ncases := fm.Data[0].Length()
+	numerical := make([]int, 0, len(features))
+	scales := make([]float64, 0, len(features))
+	for _, fi := range features {
+		f, ok := fm.Data[fi].(NumFeature)
+		if !ok {
+			continue
+		}
+		var n, sum, sumsqr float64
+		for i := 0; i < ncases; i++ {
+			if !f.IsMissing(i) {
+				v := f.Get(i)
+				n++
+				sum += v
+				sumsqr += v * v
+			}
+		}
+		if n < 2.0 {
+			continue
+		}
+		sd := math.Sqrt((sumsqr - sum*sum/n) / (n - 1.0))
+		if sd <= constant_cutoff {
+			continue
+		}
+		numerical = append(numerical, fi)
+		scales = append(scales, sd)
+	}
+	if len(numerical) < 2 {
+		return nil
+	}
+	if nFeatures < 2 {
+		nFeatures = 2
+	}
+	if nFeatures > len(numerical) {
+		nFeatures = len(numerical)
+	}
+	if nCombinations < 1 {
+		nCombinations = 1
+	}
+	o = &ObliqueSplits{nCombinations,
+		nFeatures,
+		numerical,
+		scales,
+		&DenseNumFeature{make([]float64, ncases), make([]bool, ncases), "", false},
+		make([]int, len(numerical)),
+		make([]NumFeature, nFeatures),
+		make([]float64, nFeatures)}
+	return
+}
+
+/*
+BestSplit returns the best oblique splitter of cases found and its impurity decrease or nil
+if no combination could split the cases.
+*/
+func (o *ObliqueSplits) BestSplit(fm *FeatureMatrix,
+	target Target,
+	cases *[]int,
+	parentImp float64,
+	leafSize int,
+	allocs *BestSplitAllocs) (best *Splitter, impurityDecrease float64) {
+
+	impurityDecrease = minImp
+	deck := o.picked
+	for i := range deck {
+		deck[i] = i
+	}
+
+	fs := o.fs
+	for k := 0; k < o.NCombinations; k++ {
+		SampleFirstN(&deck, nil, o.NFeatures, 0)
+		for j := 0; j < o.NFeatures; j++ {
+			fs[j] = fm.Data[o.Features[deck[j]]].(NumFeature)
+			o.weights[j] = (2.0*rand.Float64() - 1.0) / o.Scales[deck[j]]
+		}
+
+		o.proj.HasMissing = false
+		for _, c := range *cases {
+			v := 0.0
+			missing := false
+			for j, f := range fs {
+				if f.IsMissing(c) {
+					missing = true
+					break
+				}
+				v += o.weights[j] * f.Get(c)
+			}
+			o.proj.NumData[c] = v
+			o.proj.Missing[c] = missing
+			o.proj.HasMissing = o.proj.HasMissing || missing
+		}
+
+		allocs.Monotone.Search(-1)
+		split, inerImp, constant := o.proj.BestSplit(target, cases, parentImp, leafSize, allocs)
+		if !constant && inerImp > impurityDecrease {
+			impurityDecrease = inerImp
+			features := make([]string, o.NFeatures)
+			for j := range features {
+				features[j] = fs[j].GetName()
+			}
+			best = NewObliqueSplitter(features, append([]float64(nil), o.weights...), split.(float64))
+		}
+	}
+	return
+}
@@ -0,0 +1,89 @@
+package CloudForest
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestObliqueSplits(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 1000
+	x := make([]string, 0, n)
+	y := make([]string, 0, n)
+	c := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		xv, yv := rng.Float64(), 10.0*rng.Float64()
+		x = append(x, fmt.Sprintf("%v", xv))
+		y = append(y, fmt.Sprintf("%v", yv))
+		if xv+yv/10.0 > 1.0 {
+			c = append(c, "a")
+		} else {
+			c = append(c, "b")
+		}
+	}
+	fm := parseRows([]string{"C:C", "N:X", "N:Y"}, [][]string{c, x, y})
+	target := fm.Data[0].(Target)
+
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+
+	//trees with oblique splits should separate classes on a diagonal with fewer leaves
+	grow := func(oblique bool) *Tree {
+		allocs := NewBestSplitAllocs(n, target)
+		if oblique {
+			allocs.Oblique = NewObliqueSplits(fm, []int{0, 1, 2}, 20, 2)
+		}
+		tree := NewTree()
+		tree.Grow(fm, target, cases, []int{1, 2}, nil, 2, 1, false, false, false, false, nil, nil, allocs)
+		return tree
+	}
+	axis := grow(false)
+	tree := grow(true)
+	nAxis, nOblique := len(axis.GetLeaves(fm, nil)), len(tree.GetLeaves(fm, nil))
+	if nOblique >= nAxis {
+		t.Errorf("Oblique tree has %v leaves, not less than the %v of the axis aligned tree.", nOblique, nAxis)
+	}
+	if !tree.Root.Splitter.Oblique() || len(tree.Root.Splitter.Features) != 2 {
+		t.Fatalf("Root splitter %v isn't oblique on both features.", tree.Root.Splitter)
+	}
+
+	bb := NewCatBallotBox(n)
+	tree.Vote(fm, bb)
+	if e := bb.TallyError(fm.Data[0]); e > 0.02 {
+		t.Errorf("Fully grown oblique tree has training error %v.", e)
+	}
+
+	//oblique splitters should be written to and read from .sf files
+	buf := new(bytes.Buffer)
+	NewForestWriter(buf).WriteTree(tree, 0)
+	if !strings.Contains(buf.String(), "SPLITTERTYPE=OBLIQUE") {
+		t.Fatal("Oblique splitter not written.")
+	}
+	readtree, _, err := NewForestReader(buf).ReadTree()
+	if err != nil && readtree == nil {
+		t.Fatal(err)
+	}
+	rs := readtree.Root.Splitter
+	if !rs.Oblique() || rs.Feature != tree.Root.Splitter.Feature || rs.Value != tree.Root.Splitter.Value || rs.Weights[1] != tree.Root.Splitter.Weights[1] {
+		t.Errorf("Read splitter %v doesn't match %v.", rs, tree.Root.Splitter)
+	}
+	readbb := NewCatBallotBox(n)
+	readtree.Vote(fm, readbb)
+	for i := 0; i < n; i++ {
+		if readbb.Tally(i) != bb.Tally(i) {
+			t.Fatalf("Read tree predicts %v for case %v not %v.", readbb.Tally(i), i, bb.Tally(i))
+		}
+	}
+
+	//cases missing any of the features are missing
+	fm.Data[1].PutMissing(0)
+	l, r, m := tree.Root.Splitter.Split(fm, []int{0, 1, 2})
+	if len(m) != 1 || m[0] != 0 || len(l)+len(r) != 2 {
+		t.Errorf("Oblique split with a missing value gave left, right, missing %v %v %v.", l, r, m)
+	}
+}
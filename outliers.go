@@ -0,0 +1,126 @@
+package CloudForest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+/*
+Outlyingness calculates Breiman's proximity based outlier measure for each row of prox.
+As in Breiman and Cutler's description (and R's randomForest), the raw measure for case i
+is the number of cases (the columns of prox) divided by the sum of squared proximities
+between i and the other cases of the same class:
+
+	raw(i) = n / sum_{j in class(i), j != i} prox(i,j)^2
+
+Raw measures are then standardized within each class by subtracting the median and
+dividing by the median absolute deviation. classes gives the class of every case (the
+columns of prox); if it is nil all cases are treated as one class as for regression.
+Both the standardized and raw measures are returned parallel to prox.Rows.
+
+Cases with no proximity to any case of their class have an infinite raw measure and
+score. They are left out of the median and median absolute deviation of their class so
+they can't make the scores of the rest of the class infinite or NaN.
+*/
+func Outlyingness(prox *SparseProximity, classes []string) (scores []float64, raw []float64) {
+	class := func(i int) string {
+		if classes == nil {
+			return ""
+		}
+		return classes[i]
+	}
+
+	raw = make([]float64, len(prox.Rows))
+	byClass := make(map[string][]float64)
+	for k, i := range prox.Rows {
+		cols, vals := prox.Row(k)
+		sum := 0.0
+		for n, j := range cols {
+			if j != i && class(j) == class(i) {
+				sum += vals[n] * vals[n]
+			}
+		}
+		raw[k] = float64(prox.NCols) / sum
+		if !math.IsInf(raw[k], 1) {
+			byClass[class(i)] = append(byClass[class(i)], raw[k])
+		}
+	}
+
+	medians := make(map[string]float64)
+	mads := make(map[string]float64)
+	for c, vals := range byClass {
+		med := median(vals)
+		devs := make([]float64, 0, len(vals))
+		for _, v := range vals {
+			devs = append(devs, math.Abs(v-med))
+		}
+		medians[c] = med
+		mads[c] = median(devs)
+	}
+
+	scores = make([]float64, len(prox.Rows))
+	for k, i := range prox.Rows {
+		c := class(i)
+		scores[k] = raw[k] - medians[c]
+		if mads[c] > 0.0 {
+			scores[k] /= mads[c]
+		}
+	}
+	return
+}
+
+//median returns the median of vals without modifying it.
+func median(vals []float64) float64 {
+	sorted := make([]float64, len(vals))
+	copy(sorted, vals)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return math.NaN()
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2.0
+}
+
+/*
+WriteOutliers writes the outlier scores for the rows of prox ranked from most to least
+outlying as a tsv with a header row and columns:
+
+	Case	Class	Outlyingness	Raw
+*/
+func WriteOutliers(w io.Writer, prox *SparseProximity, scores []float64, raw []float64, labels []string, classes []string) (err error) {
+	order := make([]int, len(scores))
+	for k := range order {
+		order[k] = k
+	}
+	sort.Stable(&byScore{order, scores})
+
+	if _, err = fmt.Fprintln(w, "Case\tClass\tOutlyingness\tRaw"); err != nil {
+		return
+	}
+	for _, k := range order {
+		i := prox.Rows[k]
+		class := ""
+		if classes != nil {
+			class = classes[i]
+		}
+		if _, err = fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", labels[i], class, scores[k], raw[k]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+//byScore sorts row indexes by decreasing score.
+type byScore struct {
+	Order  []int
+	Scores []float64
+}
+
+func (s *byScore) Len() int           { return len(s.Order) }
+func (s *byScore) Swap(i, j int)      { s.Order[i], s.Order[j] = s.Order[j], s.Order[i] }
+func (s *byScore) Less(i, j int) bool { return s.Scores[s.Order[i]] > s.Scores[s.Order[j]] }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ryanbressler/CloudForest"
+	"log"
+	"os"
+	"runtime"
+)
+
+func main() {
+	fm := flag.String("fm",
+		"featurematrix.afm", "AFM formated feature matrix containing the training data.")
+	rf := flag.String("rfpred",
+		"rface.sf", "A predictor forest.")
+	inbag := flag.String("inbag",
+		"", "In bag counts written by growforest -inbag. If provided only out of bag pairs are counted.")
+	targetname := flag.String("target",
+		"", "A categorical feature to compute outlyingness within classes of. Defaults to the forest's target if it is categorical.")
+	outf := flag.String("out",
+		"outliers.tsv", "The name of a file to write the ranked outlier scores into.")
+	var nCores int
+	flag.IntVar(&nCores, "nCores", 1, "The number of cores to use.")
+
+	flag.Parse()
+
+	runtime.GOMAXPROCS(nCores)
+
+	//Parse Data
+	data, err := CloudForest.LoadAFM(*fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	forestfile, err := os.Open(*rf) // For read access.
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer forestfile.Close()
+	forestreader := CloudForest.NewForestReader(forestfile)
+	forest, err := forestreader.ReadForest()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var bags [][]bool
+	if *inbag != "" {
+		bagfile, err := os.Open(*inbag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bagfile.Close()
+		bags, err = CloudForest.ReadInBag(bagfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *targetname == "" {
+		*targetname = forest.Target
+	}
+	var classes []string
+	if targeti, ok := data.Map[*targetname]; ok && data.Data[targeti].NCats() > 0 {
+		fmt.Printf("Computing outlyingness within classes of %v\n", *targetname)
+		target := data.Data[targeti]
+		classes = make([]string, target.Length())
+		for i := range classes {
+			classes[i] = target.GetStr(i)
+		}
+	}
+
+	leaves := CloudForest.NewLeafIndex(forest, data)
+	prox := CloudForest.Proximity(leaves, leaves, bags, bags, nil, nCores)
+	scores, raw := CloudForest.Outlyingness(prox, classes)
+
+	fmt.Printf("Outputting ranked outlier scores to %v\n", *outf)
+	outfile, err := os.Create(*outf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer outfile.Close()
+	if err = CloudForest.WriteOutliers(outfile, prox, scores, raw, data.CaseLabels, classes); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,305 @@
+package CloudForest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+/*
+PartialDep contains partial dependence and individual conditional expectation (ICE)
+curves for one or two features swept over a grid of values.
+
+Grid contains one row per grid point with one value per swept feature. PD contains
+one row per grid point with one column per output: a single column holding the mean
+prediction for numeric targets or one column per class holding the mean fraction of
+votes for that class for categorical targets. ICE has the same layout as PD but with an
+additional middle index giving the case.
+*/
+type PartialDep struct {
+	Features []string
+	Grid     [][]string
+	Classes  []string
+	PD       [][]float64
+	ICE      [][][]float64
+}
+
+/*
+NewGrid builds a grid of values to sweep a feature over. Categorical features are swept
+over all of their category levels. Numerical features are swept over n points evenly
+spaced between the minimum and maximum non missing value of the feature.
+*/
+func NewGrid(f Feature, n int) (grid []string) {
+	switch f.(type) {
+	case CatFeature:
+		cf := f.(CatFeature)
+		grid = make([]string, 0, cf.NCats())
+		for i := 0; i < cf.NCats(); i++ {
+			grid = append(grid, cf.NumToCat(i))
+		}
+	case NumFeature:
+		nf := f.(NumFeature)
+		first := true
+		min := 0.0
+		max := 0.0
+		for i := 0; i < nf.Length(); i++ {
+			if nf.IsMissing(i) {
+				continue
+			}
+			v := nf.Get(i)
+			if first || v < min {
+				min = v
+			}
+			if first || v > max {
+				max = v
+			}
+			first = false
+		}
+		if first {
+			return
+		}
+		if n < 2 || min == max {
+			return []string{fmt.Sprintf("%v", min)}
+		}
+		grid = make([]string, 0, n)
+		step := (max - min) / float64(n-1)
+		for i := 0; i < n; i++ {
+			grid = append(grid, fmt.Sprintf("%v", min+float64(i)*step))
+		}
+	}
+	return
+}
+
+/*
+PartialDependence sweeps the features specified by featureis (one or two feature indexes into fm)
+over the supplied grids. For each point in the grid (the cartesian product of the grids when two
+features are specified) every case in fm has its values for the swept features replaced with the
+grid values and is voted through the forest using Tree.Vote.
+
+If categorical is true votes are tallied in a CatBallotBox and the fraction of votes for each class is
+reported; otherwise votes are tallied in a NumBallotBox and the mean prediction is reported. Gradient
+boosted forests are tallied in a SoftmaxBallotBox (reporting class probabilities) or SumBallotBox.
+
+If ice is false only the partial dependence (mean over cases) is retained.
+*/
+func PartialDependence(forest *Forest, fm *FeatureMatrix, featureis []int, grids [][]string, categorical bool, ice bool) (pd *PartialDep) {
+	nCases := fm.Data[0].Length()
+	pd = &PartialDep{make([]string, 0, len(featureis)), cartesianGrid(grids), nil, nil, nil}
+	for _, fi := range featureis {
+		pd.Features = append(pd.Features, fm.Data[fi].GetName())
+	}
+
+	//shallow copy of the feature matrix so that swept features can be swapped in
+	//without disturbing the origional.
+	sweep := &FeatureMatrix{make([]Feature, len(fm.Data)), fm.Map, fm.CaseLabels}
+	copy(sweep.Data, fm.Data)
+	swept := make([]Feature, 0, len(featureis))
+	for _, fi := range featureis {
+		f := fm.Data[fi].Copy()
+		sweep.Data[fi] = f
+		swept = append(swept, f)
+	}
+
+	boxes := make([]VoteTallyer, 0, len(pd.Grid))
+	for _, point := range pd.Grid {
+		for j, f := range swept {
+			for i := 0; i < nCases; i++ {
+				f.PutStr(i, point[j])
+			}
+		}
+
+		var bb VoteTallyer
+		switch {
+		case categorical && forest.Type == GBTClassForest:
+			bb = NewSoftmaxBallotBox(nCases)
+		case categorical:
+			bb = NewCatBallotBox(nCases)
+		case forest.Type == GBTForest:
+			bb = NewSumBallotBox(nCases, forest.Intercept)
+		default:
+			bb = NewNumBallotBox(nCases)
+		}
+		for _, tree := range forest.Trees {
+			tree.Vote(sweep, bb)
+		}
+		boxes = append(boxes, bb)
+	}
+
+	if categorical {
+		pd.Classes = votedClasses(boxes)
+	}
+	nOut := 1
+	if categorical {
+		nOut = len(pd.Classes)
+	}
+
+	pd.PD = make([][]float64, 0, len(boxes))
+	if ice {
+		pd.ICE = make([][][]float64, 0, len(boxes))
+	}
+	for _, bb := range boxes {
+		mean := make([]float64, nOut)
+		var curves [][]float64
+		if ice {
+			curves = make([][]float64, 0, nCases)
+		}
+		counted := 0
+		for i := 0; i < nCases; i++ {
+			vals := make([]float64, nOut)
+			if sbb, ok := bb.(*SoftmaxBallotBox); ok {
+				for k, class := range pd.Classes {
+					vals[k] = sbb.TallyProbability(i, class)
+				}
+			} else if categorical {
+				cbb := bb.(*CatBallotBox)
+				for k, class := range pd.Classes {
+					vals[k] = cbb.TallyFraction(i, class)
+				}
+			} else if sbb, ok := bb.(*SumBallotBox); ok {
+				vals[0] = sbb.TallyNum(i)
+			} else {
+				vals[0] = bb.(*NumBallotBox).TallyNum(i)
+			}
+			if ice {
+				curves = append(curves, vals)
+			}
+			if !math.IsNaN(vals[0]) {
+				for k, v := range vals {
+					mean[k] += v
+				}
+				counted++
+			}
+		}
+		for k := range mean {
+			mean[k] /= float64(counted)
+		}
+		pd.PD = append(pd.PD, mean)
+		if ice {
+			pd.ICE = append(pd.ICE, curves)
+		}
+	}
+
+	return
+}
+
+//cartesianGrid returns the cartesian product of the supplied grids.
+func cartesianGrid(grids [][]string) (points [][]string) {
+	points = [][]string{[]string{}}
+	for _, grid := range grids {
+		next := make([][]string, 0, len(points)*len(grid))
+		for _, p := range points {
+			for _, v := range grid {
+				point := make([]string, len(p), len(p)+1)
+				copy(point, p)
+				next = append(next, append(point, v))
+			}
+		}
+		points = next
+	}
+	return
+}
+
+//votedClasses returns the sorted set of all classes voted for in any of the supplied CatBallotBoxes.
+func votedClasses(boxes []VoteTallyer) (classes []string) {
+	seen := make(map[string]bool)
+	for _, bb := range boxes {
+		var back []string
+		switch b := bb.(type) {
+		case *CatBallotBox:
+			back = b.Back
+		case *SoftmaxBallotBox:
+			back = b.Back
+		}
+		for _, class := range back {
+			if !seen[class] {
+				seen[class] = true
+				classes = append(classes, class)
+			}
+		}
+	}
+	sort.Strings(classes)
+	return
+}
+
+//outputHeaders returns the names of the PD/ICE value columns.
+func (pd *PartialDep) outputHeaders() []string {
+	if pd.Classes == nil {
+		return []string{"Predicted"}
+	}
+	return pd.Classes
+}
+
+/*
+WritePD writes the partial dependence as a tsv with a header row, one column for each swept feature
+and one column per output as described in PartialDep.
+*/
+func (pd *PartialDep) WritePD(w io.Writer) (err error) {
+	if err = pd.writeHeader(w, false); err != nil {
+		return
+	}
+	for p, point := range pd.Grid {
+		if err = writeRow(w, point, pd.PD[p]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+/*
+WriteICE writes the individual conditional expectation curves as a tsv with one row per case and
+grid point. The first column contains the case label.
+*/
+func (pd *PartialDep) WriteICE(w io.Writer, caseLabels []string) (err error) {
+	if err = pd.writeHeader(w, true); err != nil {
+		return
+	}
+	for i, label := range caseLabels {
+		for p, point := range pd.Grid {
+			if _, err = fmt.Fprintf(w, "%v\t", label); err != nil {
+				return
+			}
+			if err = writeRow(w, point, pd.ICE[p][i]); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (pd *PartialDep) writeHeader(w io.Writer, caseCol bool) (err error) {
+	header := make([]string, 0, len(pd.Features)+len(pd.Classes)+2)
+	if caseCol {
+		header = append(header, "Case")
+	}
+	header = append(header, pd.Features...)
+	header = append(header, pd.outputHeaders()...)
+	for i, h := range header {
+		sep := "\t"
+		if i == len(header)-1 {
+			sep = "\n"
+		}
+		if _, err = fmt.Fprintf(w, "%v%v", h, sep); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeRow(w io.Writer, point []string, vals []float64) (err error) {
+	for _, v := range point {
+		if _, err = fmt.Fprintf(w, "%v\t", v); err != nil {
+			return
+		}
+	}
+	for k, v := range vals {
+		sep := "\t"
+		if k == len(vals)-1 {
+			sep = "\n"
+		}
+		if _, err = fmt.Fprintf(w, "%v%v", v, sep); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ryanbressler/CloudForest"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	fm := flag.String("fm",
+		"featurematrix.afm", "AFM formated feature matrix containing data.")
+	rf := flag.String("rfpred",
+		"rface.sf", "A predictor forest.")
+	features := flag.String("features",
+		"", "A comma seperated list of one or two features to sweep.")
+	pdfn := flag.String("pd",
+		"partialdep.tsv", "The name of a file to write the partial dependence into.")
+	icefn := flag.String("ice",
+		"", "The name of a file to write the individual conditional expectation curves into.")
+	var nGrid int
+	flag.IntVar(&nGrid, "grid", 20, "Number of grid points to sweep numerical features over.")
+	var num bool
+	flag.BoolVar(&num, "mean", false, "Force numeric (mean) voting.")
+	var cat bool
+	flag.BoolVar(&cat, "mode", false, "Force categorical (mode) voting.")
+
+	flag.Parse()
+
+	//Parse Data
+	data, err := CloudForest.LoadAFM(*fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	forestfile, err := os.Open(*rf) // For read access.
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer forestfile.Close()
+	forestreader := CloudForest.NewForestReader(forestfile)
+	forest, err := forestreader.ReadForest()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names := strings.Split(*features, ",")
+	if *features == "" || len(names) > 2 {
+		log.Fatal("Specify one or two features to sweep with -features.")
+	}
+	featureis := make([]int, 0, len(names))
+	grids := make([][]string, 0, len(names))
+	for _, name := range names {
+		fi, ok := data.Map[name]
+		if !ok {
+			log.Fatalf("Feature %v not found in data.", name)
+		}
+		featureis = append(featureis, fi)
+		grids = append(grids, CloudForest.NewGrid(data.Data[fi], nGrid))
+	}
+
+	categorical := cat || !(num || strings.HasPrefix(forest.Target, "N"))
+
+	pd := CloudForest.PartialDependence(forest, data, featureis, grids, categorical, *icefn != "")
+	fmt.Printf("Swept %v over %v grid points.\n", *features, len(pd.Grid))
+
+	fmt.Printf("Outputting partial dependence to %v\n", *pdfn)
+	pdfile, err := os.Create(*pdfn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer pdfile.Close()
+	if err = pd.WritePD(pdfile); err != nil {
+		log.Fatal(err)
+	}
+
+	if *icefn != "" {
+		fmt.Printf("Outputting individual conditional expectation curves to %v\n", *icefn)
+		icefile, err := os.Create(*icefn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer icefile.Close()
+		if err = pd.WriteICE(icefile, data.CaseLabels); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package CloudForest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPartialDependence(t *testing.T) {
+	fm := ParseAFM(strings.NewReader(fm))
+	candidates := []int{2, 3, 4}
+
+	numtarget := fm.Data[0]
+	forest := GrowRandomForest(fm, numtarget.(Feature), candidates, fm.Data[0].Length(), 3, 10, 1, false, false, false, false, nil)
+
+	grid := NewGrid(fm.Data[4], 5)
+	if len(grid) != 5 {
+		t.Errorf("Numerical grid has %v points not 5.", len(grid))
+	}
+	catgrid := NewGrid(fm.Data[2], 5)
+	if len(catgrid) != 5 {
+		t.Errorf("Categorical grid has %v points not one per category (5).", len(catgrid))
+	}
+
+	pd := PartialDependence(forest, fm, []int{4}, [][]string{grid}, false, true)
+	if len(pd.PD) != 5 || len(pd.PD[0]) != 1 {
+		t.Errorf("Numerical partial dependence has shape %v by %v not 5 by 1.", len(pd.PD), len(pd.PD[0]))
+	}
+	if len(pd.ICE) != 5 || len(pd.ICE[0]) != fm.Data[0].Length() {
+		t.Errorf("ICE curves have shape %v by %v not 5 by %v.", len(pd.ICE), len(pd.ICE[0]), fm.Data[0].Length())
+	}
+	if fm.Data[4].GetStr(4) != "0.2" {
+		t.Errorf("Partial dependence modified the swept feature.")
+	}
+
+	pd = PartialDependence(forest, fm, []int{4, 2}, [][]string{grid, catgrid}, false, false)
+	if len(pd.Grid) != 25 || len(pd.PD) != 25 || len(pd.Grid[0]) != 2 {
+		t.Errorf("Two way partial dependence has %v grid points not 25.", len(pd.PD))
+	}
+
+	cattarget := fm.Data[1]
+	forest = GrowRandomForest(fm, cattarget.(Feature), candidates, fm.Data[0].Length(), 3, 10, 1, false, false, false, false, nil)
+	pd = PartialDependence(forest, fm, []int{2}, [][]string{catgrid}, true, true)
+	for _, row := range pd.PD {
+		total := 0.0
+		for _, v := range row {
+			total += v
+		}
+		if total < 0.999 || total > 1.001 {
+			t.Errorf("Categorical partial dependence fractions sum to %v not 1.", total)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := pd.WriteICE(&out, fm.CaseLabels); err != nil {
+		t.Errorf("Error writing ICE curves: %v", err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 1+5*fm.Data[0].Length() {
+		t.Errorf("ICE output has %v lines not %v.", lines, 1+5*fm.Data[0].Length())
+	}
+}
@@ -0,0 +1,204 @@
+package CloudForest
+
+import (
+	"sort"
+)
+
+/*
+PresortedIndex holds the cases of each numerical feature of a FeatureMatrix sorted by value
+with missing cases last. It is built once per FeatureMatrix and can be shared (read only)
+by the PresortedCases of all of the go routines growing trees.
+*/
+type PresortedIndex struct {
+	Features []int
+	Orders   [][]int
+}
+
+//NewPresortedIndex sorts the cases of each of the specified features that is a
+//DenseNumFeature. Other features are skipped.
+func NewPresortedIndex(fm *FeatureMatrix, features []int) (pi *PresortedIndex) {
+	pi = &PresortedIndex{make([]int, 0, len(features)), make([][]int, 0, len(features))}
+	for _, fi := range features {
+		f, ok := fm.Data[fi].(*DenseNumFeature)
+		if !ok {
+			continue
+		}
+		order := make([]int, len(f.NumData))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool {
+			i, j := order[a], order[b]
+			if f.Missing[i] || f.Missing[j] {
+				return !f.Missing[i] && f.Missing[j]
+			}
+			return f.NumData[i] < f.NumData[j]
+		})
+		pi.Features = append(pi.Features, fi)
+		pi.Orders = append(pi.Orders, order)
+	}
+	return
+}
+
+/*
+PresortedCases maintains, for each feature of a PresortedIndex, the cases a tree is being
+grown on sorted by the feature's value so DenseNumFeature.BestSplit can search numerical
+splits without sorting a node's cases.
+
+The lists are aligned with the positions of the tree's cases: the cases of a node that
+occupies cases[a:b] are held in sorted order in list[a:b] of each feature. Load builds
+the lists for a tree in linear time from the PresortedIndex and Partition stably
+partitions them in the same way the node's cases are partitioned after it is split, so
+the invariant holds for the children as CodedRecurse descends. Nodes are identified by
+the last element of the backing array of their slice of cases and their offset from it.
+
+Keeping the lists partitioned costs a pass over each feature's list at every split, so
+presorting pays off when sorting the cases of each node for each candidate feature is more
+expensive, ie with large numbers of cases and mTry close to the number of features.
+
+A PresortedCases should be set as the Presort field of a BestSplitAllocs and, like the rest
+of BestSplitAllocs, used by a single go routine.
+*/
+type PresortedCases struct {
+	Index     *PresortedIndex
+	byFeature map[*DenseNumFeature]int
+	lists     [][]int
+	root      []int
+	counts    []int
+	sides     []int
+	buf       []int
+}
+
+//NewPresortedCases creates the per go routine lists for presorted split searching on fm
+//using the shared index.
+func NewPresortedCases(fm *FeatureMatrix, index *PresortedIndex) (pc *PresortedCases) {
+	ncases := fm.Data[0].Length()
+	pc = &PresortedCases{index,
+		make(map[*DenseNumFeature]int, len(index.Features)),
+		make([][]int, len(index.Features)),
+		nil,
+		make([]int, ncases),
+		make([]int, ncases),
+		nil}
+	for k, fi := range index.Features {
+		pc.byFeature[fm.Data[fi].(*DenseNumFeature)] = k
+	}
+	return
+}
+
+/*
+Load builds the sorted lists for a tree grown on cases (which may contain repeats as when
+bagging) by walking each feature's presorted order and emitting each case as many times
+as it occurs in cases.
+*/
+func (pc *PresortedCases) Load(cases []int) {
+	pc.root = cases
+	for _, c := range cases {
+		pc.counts[c]++
+	}
+	for k, order := range pc.Index.Orders {
+		list := pc.lists[k][:0]
+		for _, c := range order {
+			for j := 0; j < pc.counts[c]; j++ {
+				list = append(list, c)
+			}
+		}
+		pc.lists[k] = list
+	}
+	for _, c := range cases {
+		pc.counts[c] = 0
+	}
+}
+
+//offset returns the position of cases in the cases of the loaded tree or -1 if cases isn't
+//part of them.
+func (pc *PresortedCases) offset(cases []int) int {
+	if cap(pc.root) == 0 || cap(cases) == 0 {
+		return -1
+	}
+	if &pc.root[:cap(pc.root)][cap(pc.root)-1] != &cases[:cap(cases)][cap(cases)-1] {
+		return -1
+	}
+	off := cap(pc.root) - cap(cases)
+	if off < 0 || off+len(cases) > len(pc.root) {
+		return -1
+	}
+	return off
+}
+
+//Sorted returns the cases sorted by f's values (with missing cases last) if f is presorted
+//and cases belong to the loaded tree and nil otherwise. It is safe to call on a nil
+//PresortedCases.
+func (pc *PresortedCases) Sorted(f *DenseNumFeature, cases []int) []int {
+	if pc == nil {
+		return nil
+	}
+	k, ok := pc.byFeature[f]
+	if !ok {
+		return nil
+	}
+	off := pc.offset(cases)
+	if off < 0 {
+		return nil
+	}
+	return pc.lists[k][off : off+len(cases)]
+}
+
+/*
+Partition partitions cases by the coded split of f with SplitPoints, as CodedRecurse will
+after the node is split, and stably partitions the node's sorted lists into left, missing
+and right cases (or the branches of a multiway split followed by missing cases) to match.
+*/
+func (pc *PresortedCases) Partition(f Feature, codedSplit interface{}, cases *[]int) {
+	//the bounds of the groups of cases in the order they are laid out
+	var bounds []int
+	if multiway, ok := codedSplit.(*MultiwaySplit); ok {
+		branches, _ := f.(*DenseCatFeature).SplitMultiway(multiway, *cases)
+		bounds = append(bounds, 0)
+		for _, b := range branches {
+			bounds = append(bounds, bounds[len(bounds)-1]+len(b))
+		}
+	} else {
+		li, ri := f.SplitPoints(codedSplit, cases)
+		bounds = []int{0, li, ri}
+	}
+	pc.partition(append(bounds, len(*cases)), cases)
+}
+
+//PartitionOblique partitions cases and the node's sorted lists like Partition for an
+//oblique splitter.
+func (pc *PresortedCases) PartitionOblique(fm *FeatureMatrix, s *Splitter, cases *[]int) {
+	l, _, m := s.Split(fm, *cases)
+	pc.partition([]int{0, len(l), len(l) + len(m), len(*cases)}, cases)
+}
+
+//partition stably partitions the node's sorted lists into the groups of cases, which
+//have been laid out in order, with the specified bounds.
+func (pc *PresortedCases) partition(bounds []int, cases *[]int) {
+	off := pc.offset(*cases)
+	if off < 0 {
+		return
+	}
+	ngroups := len(bounds) - 1
+	for g := 0; g < ngroups; g++ {
+		for _, c := range (*cases)[bounds[g]:bounds[g+1]] {
+			pc.sides[c] = g
+		}
+	}
+
+	if cap(pc.buf) < len(*cases) {
+		pc.buf = make([]int, len(*cases))
+	}
+	buf := pc.buf[:len(*cases)]
+	next := make([]int, ngroups)
+	for k := range pc.lists {
+		list := pc.lists[k][off : off+len(*cases)]
+		copy(next, bounds[:ngroups])
+		for _, c := range list {
+			g := pc.sides[c]
+			buf[next[g]] = c
+			next[g]++
+		}
+		copy(list, buf)
+	}
+}
@@ -0,0 +1,97 @@
+package CloudForest
+
+import (
+	"math"
+	"testing"
+)
+
+//checkPresorted checks that the presorted lists of the cases occupying cases[off:off+len(cases)]
+//are sorted with missing cases last and hold the same cases.
+func checkPresorted(t *testing.T, fm *FeatureMatrix, pc *PresortedCases, cases []int) {
+	for _, fi := range pc.Index.Features {
+		f := fm.Data[fi].(*DenseNumFeature)
+		sorted := pc.Sorted(f, cases)
+		if len(sorted) != len(cases) {
+			t.Fatalf("%v has %v presorted cases not %v.", f.Name, len(sorted), len(cases))
+		}
+		counts := make(map[int]int)
+		for _, c := range cases {
+			counts[c]++
+		}
+		for i, c := range sorted {
+			counts[c]--
+			if i > 0 && !f.Missing[c] && (f.Missing[sorted[i-1]] || f.NumData[c] < f.NumData[sorted[i-1]]) {
+				t.Fatalf("%v presorted cases aren't sorted at %v.", f.Name, i)
+			}
+		}
+		for c, count := range counts {
+			if count != 0 {
+				t.Fatalf("%v presorted cases don't match the cases for case %v.", f.Name, c)
+			}
+		}
+	}
+}
+
+func TestPresortedCases(t *testing.T) {
+	n := 1000
+	fm := binningTestData(n)
+	target := fm.Data[0].(Target)
+	x := fm.Data[2].(*DenseNumFeature)
+	z := fm.Data[3].(*DenseNumFeature)
+
+	index := NewPresortedIndex(fm, []int{1, 2, 3})
+	if len(index.Features) != 2 {
+		t.Fatalf("Presorted %v features not the 2 numerical ones.", len(index.Features))
+	}
+
+	cases := SampleWithReplacment(n, n)
+	allocs := NewBestSplitAllocs(n, target)
+	allocs.Presort = NewPresortedCases(fm, index)
+	allocs.Presort.Load(cases)
+	sortAllocs := NewBestSplitAllocs(n, target)
+	checkPresorted(t, fm, allocs.Presort, cases)
+
+	compare := func(cases []int) {
+		parentImp := target.Impurity(&cases, allocs.Counter)
+		for _, f := range []*DenseNumFeature{x, z} {
+			split, imp, _ := f.BestSplit(target, &cases, parentImp, 5, allocs)
+			ssplit, simp, _ := f.BestSplit(target, &cases, parentImp, 5, sortAllocs)
+			if split.(float64) != ssplit.(float64) || math.Abs(imp-simp) > 1e-9 {
+				t.Errorf("Presorted split of %v %v with decrease %v doesn't match %v with %v.", f.Name, split, imp, ssplit, simp)
+			}
+		}
+	}
+	compare(cases)
+
+	//split on the feature with missing values so the children are in three ranges
+	parentImp := target.Impurity(&cases, allocs.Counter)
+	split, _, _ := x.BestSplit(target, &cases, parentImp, 5, allocs)
+	allocs.Presort.Partition(x, split, &cases)
+	li, ri := x.SplitPoints(split, &cases)
+	if li == 0 || li == ri || ri == len(cases) {
+		t.Fatalf("Split %v didn't give left, missing and right cases: %v %v.", split, li, ri)
+	}
+	for _, child := range [][]int{cases[:li], cases[li:ri], cases[ri:]} {
+		checkPresorted(t, fm, allocs.Presort, child)
+	}
+	compare(cases[:li])
+	compare(cases[ri:])
+
+	//cases that aren't the tree's aren't presorted
+	other := append([]int(nil), cases...)
+	if allocs.Presort.Sorted(x, other) != nil {
+		t.Error("Cases not loaded were presorted.")
+	}
+
+	//grown trees should fit about as well as ones grown by sorting
+	mse := func(allocs *BestSplitAllocs) float64 {
+		tree := NewTree()
+		tree.Grow(fm, target, cases, []int{2, 3}, nil, 2, 5, false, false, false, false, nil, nil, allocs)
+		bb := NewNumBallotBox(n)
+		tree.Vote(fm, bb)
+		return bb.TallySquaredError(fm.Data[0])
+	}
+	if p, s := mse(allocs), mse(sortAllocs); p > 1.2*s {
+		t.Errorf("Presorted tree error %v is worse than sorted tree error %v.", p, s)
+	}
+}
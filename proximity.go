@@ -0,0 +1,318 @@
+package CloudForest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+LeafIndex records the leaf reached by each case of a FeatureMatrix in each tree of a
+forest. Leaves are numbered within each tree in the order they are visited by Climb
+so LeafIndexes built from different data with the same forest can be compared.
+
+Leaves[t][i] is the leaf case i reaches in tree t (-1 if it reaches none) and
+Members[t][l] lists the cases that reach leaf l of tree t.
+*/
+type LeafIndex struct {
+	NCases  int
+	Leaves  [][]int
+	Members [][][]int
+}
+
+//NewLeafIndex drops every case in fm down every tree in forest.
+func NewLeafIndex(forest *Forest, fm *FeatureMatrix) (li *LeafIndex) {
+	ncases := fm.Data[0].Length()
+	li = &LeafIndex{ncases,
+		make([][]int, 0, len(forest.Trees)),
+		make([][][]int, 0, len(forest.Trees))}
+	cases := make([]int, 0, ncases)
+	for i := 0; i < ncases; i++ {
+		cases = append(cases, i)
+	}
+	for _, tree := range forest.Trees {
+		ids := make(map[*Node]int)
+		tree.Root.Climb(func(n *Node) {
+			if n.IsLeaf() {
+				ids[n] = len(ids)
+			}
+		})
+		leaves := make([]int, ncases)
+		for i := range leaves {
+			leaves[i] = -1
+		}
+		members := make([][]int, len(ids))
+		tree.Root.Recurse(func(n *Node, cases []int, depth int) {
+			if id, ok := ids[n]; ok {
+				members[id] = append(members[id], cases...)
+				for _, c := range cases {
+					leaves[c] = id
+				}
+			}
+		}, fm, cases, 0)
+		li.Leaves = append(li.Leaves, leaves)
+		li.Members = append(li.Members, members)
+	}
+	return
+}
+
+/*
+SparseProximity is a proximity matrix stored in compressed sparse row (CSR) format.
+The nonzero entries of row i are in Cols[RowPtr[i]:RowPtr[i+1]] (sorted by column)
+and the corresponding Vals. Rows lists the case each row corresponds to (so that
+proximities can be calculated for a subset of cases) and NCols is the number of
+columns.
+*/
+type SparseProximity struct {
+	Rows   []int
+	NCols  int
+	RowPtr []int
+	Cols   []int
+	Vals   []float64
+}
+
+//Row returns the nonzero columns and values of the kth row.
+func (p *SparseProximity) Row(k int) (cols []int, vals []float64) {
+	return p.Cols[p.RowPtr[k]:p.RowPtr[k+1]], p.Vals[p.RowPtr[k]:p.RowPtr[k+1]]
+}
+
+//Get returns the proximity in the kth row and jth column.
+func (p *SparseProximity) Get(k int, j int) float64 {
+	cols, vals := p.Row(k)
+	lo, hi := 0, len(cols)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch {
+		case cols[mid] == j:
+			return vals[mid]
+		case cols[mid] < j:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0.0
+}
+
+//NNZ returns the number of nonzero entries.
+func (p *SparseProximity) NNZ() int {
+	return len(p.Cols)
+}
+
+/*
+Proximity calculates the random forest proximity between the cases in rows and the
+cases in cols: the fraction of trees in which the two cases reach the same leaf. Both
+LeafIndexes must be built with the same forest; to calculate proximities between training
+cases pass the same LeafIndex as both.
+
+If rowInBag and/or colInBag (indexed [tree][case], see ReadInBag) are not nil only
+trees in which the cases are out of bag are counted and proximities are normalized by
+the number of trees in which both cases are out of bag instead of the total number of
+trees. subset lists the row cases to calculate (nil for all). Rows are calculated in
+nCores goroutines, each of which accumulates its own rows so no locking is needed.
+*/
+func Proximity(rows *LeafIndex, cols *LeafIndex, rowInBag [][]bool, colInBag [][]bool, subset []int, nCores int) (p *SparseProximity) {
+	if subset == nil {
+		subset = make([]int, 0, rows.NCases)
+		for i := 0; i < rows.NCases; i++ {
+			subset = append(subset, i)
+		}
+	}
+	if nCores < 1 {
+		nCores = 1
+	}
+	nTrees := len(rows.Leaves)
+	rowOOB := oobBits(rowInBag, rows.NCases)
+	colOOB := oobBits(colInBag, cols.NCases)
+	restricted := rowOOB != nil || colOOB != nil
+
+	rowCols := make([][]int, len(subset))
+	rowVals := make([][]float64, len(subset))
+
+	var wg sync.WaitGroup
+	for core := 0; core < nCores; core++ {
+		wg.Add(1)
+		go func(core int) {
+			defer wg.Done()
+			counts := make([]float64, cols.NCases)
+			touched := make([]bool, cols.NCases)
+			nz := make([]int, 0)
+			for k := core; k < len(subset); k += nCores {
+				i := subset[k]
+				nz = nz[0:0]
+				for t := 0; t < nTrees; t++ {
+					leaf := rows.Leaves[t][i]
+					if leaf < 0 || (rowInBag != nil && rowInBag[t][i]) {
+						continue
+					}
+					for _, j := range cols.Members[t][leaf] {
+						if colInBag != nil && colInBag[t][j] {
+							continue
+						}
+						if !touched[j] {
+							touched[j] = true
+							nz = append(nz, j)
+						}
+						counts[j]++
+					}
+				}
+				sort.Ints(nz)
+				rc := make([]int, 0, len(nz))
+				rv := make([]float64, 0, len(nz))
+				for _, j := range nz {
+					denom := float64(nTrees)
+					if restricted {
+						denom = float64(bothOOB(rowOOB, i, colOOB, j))
+					}
+					rc = append(rc, j)
+					rv = append(rv, counts[j]/denom)
+					counts[j] = 0.0
+					touched[j] = false
+				}
+				rowCols[k] = rc
+				rowVals[k] = rv
+			}
+		}(core)
+	}
+	wg.Wait()
+
+	p = &SparseProximity{subset, cols.NCases, make([]int, 1, len(subset)+1), nil, nil}
+	nnz := 0
+	for k := range subset {
+		nnz += len(rowCols[k])
+		p.RowPtr = append(p.RowPtr, nnz)
+	}
+	p.Cols = make([]int, 0, nnz)
+	p.Vals = make([]float64, 0, nnz)
+	for k := range subset {
+		p.Cols = append(p.Cols, rowCols[k]...)
+		p.Vals = append(p.Vals, rowVals[k]...)
+	}
+	return
+}
+
+//oobBits packs the out of bag status of each case in each tree into bit sets
+//indexed [case][tree/64]. It returns nil if inbag is nil.
+func oobBits(inbag [][]bool, ncases int) (oob [][]uint64) {
+	if inbag == nil {
+		return nil
+	}
+	words := (len(inbag) + 63) / 64
+	oob = make([][]uint64, ncases)
+	for i := range oob {
+		oob[i] = make([]uint64, words)
+		for t := range inbag {
+			if !inbag[t][i] {
+				oob[i][t/64] |= 1 << uint(t%64)
+			}
+		}
+	}
+	return
+}
+
+//bothOOB counts the trees in which case i (of the rows) and case j (of the columns)
+//are both out of bag. A nil set of bits means all cases are out of bag in all trees.
+func bothOOB(rowOOB [][]uint64, i int, colOOB [][]uint64, j int) (n int) {
+	switch {
+	case rowOOB == nil:
+		for _, w := range colOOB[j] {
+			n += bits.OnesCount64(w)
+		}
+	case colOOB == nil:
+		for _, w := range rowOOB[i] {
+			n += bits.OnesCount64(w)
+		}
+	default:
+		for k, w := range rowOOB[i] {
+			n += bits.OnesCount64(w & colOOB[j][k])
+		}
+	}
+	return
+}
+
+/*
+WriteSparse writes the nonzero proximities as a tsv with one row per entry:
+
+	RowCase	ColCase	Proximity
+
+using the case labels provided.
+*/
+func (p *SparseProximity) WriteSparse(w io.Writer, rowLabels []string, colLabels []string) (err error) {
+	for k, i := range p.Rows {
+		cols, vals := p.Row(k)
+		for n, j := range cols {
+			if _, err = fmt.Fprintf(w, "%v\t%v\t%v\n", rowLabels[i], colLabels[j], vals[n]); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+//WriteDense writes the proximities as a tsv matrix with a header row of column case
+//labels and the row case label as the first column.
+func (p *SparseProximity) WriteDense(w io.Writer, rowLabels []string, colLabels []string) (err error) {
+	bw := bufio.NewWriter(w)
+	if _, err = bw.WriteString(".\t" + strings.Join(colLabels, "\t") + "\n"); err != nil {
+		return
+	}
+	row := make([]string, p.NCols)
+	for k, i := range p.Rows {
+		for j := range row {
+			row[j] = "0"
+		}
+		cols, vals := p.Row(k)
+		for n, j := range cols {
+			row[j] = strconv.FormatFloat(vals[n], 'g', -1, 64)
+		}
+		if _, err = bw.WriteString(rowLabels[i] + "\t" + strings.Join(row, "\t") + "\n"); err != nil {
+			return
+		}
+	}
+	return bw.Flush()
+}
+
+//WriteInBag writes the number of times each case was sampled for a tree as a single
+//tab separated line.
+func WriteInBag(w io.Writer, cases []int, ncases int) (err error) {
+	counts := make([]string, ncases)
+	n := make([]int, ncases)
+	for _, c := range cases {
+		n[c]++
+	}
+	for i, v := range n {
+		counts[i] = strconv.Itoa(v)
+	}
+	_, err = fmt.Fprintln(w, strings.Join(counts, "\t"))
+	return
+}
+
+//ReadInBag reads in bag counts written by WriteInBag (one line per tree) and returns
+//weather each case was in bag for each tree indexed [tree][case].
+func ReadInBag(r io.Reader) (inbag [][]bool, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		bag := make([]bool, len(fields))
+		for i, f := range fields {
+			v, e := strconv.Atoi(f)
+			if e != nil {
+				return nil, e
+			}
+			bag[i] = v > 0
+		}
+		inbag = append(inbag, bag)
+	}
+	err = scanner.Err()
+	return
+}
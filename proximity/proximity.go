@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ryanbressler/CloudForest"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+)
+
+func main() {
+	fm := flag.String("fm",
+		"featurematrix.afm", "AFM formated feature matrix containing the training data.")
+	rf := flag.String("rfpred",
+		"rface.sf", "A predictor forest.")
+	newfm := flag.String("test",
+		"", "AFM formated feature matrix of new cases to calculate proximities to the training cases for.")
+	inbag := flag.String("inbag",
+		"", "In bag counts written by growforest -inbag. If provided only out of bag pairs are counted.")
+	outf := flag.String("out",
+		"proximity.tsv", "The name of a file to write the proximities into.")
+	cases := flag.String("cases",
+		"", "A comma seperated list of case labels to calculate proximity rows for. Defaults to all cases.")
+	var dense bool
+	flag.BoolVar(&dense, "dense", false, "Write a dense matrix instead of a sparse list of case pairs.")
+	var nCores int
+	flag.IntVar(&nCores, "nCores", 1, "The number of cores to use.")
+
+	flag.Parse()
+
+	runtime.GOMAXPROCS(nCores)
+
+	//Parse Data
+	data, err := CloudForest.LoadAFM(*fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	forestfile, err := os.Open(*rf) // For read access.
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer forestfile.Close()
+	forestreader := CloudForest.NewForestReader(forestfile)
+	forest, err := forestreader.ReadForest()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var bags [][]bool
+	if *inbag != "" {
+		bagfile, err := os.Open(*inbag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer bagfile.Close()
+		bags, err = CloudForest.ReadInBag(bagfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(bags) != len(forest.Trees) {
+			log.Fatalf("In bag file has %v trees, forest has %v.", len(bags), len(forest.Trees))
+		}
+	}
+
+	trainLeaves := CloudForest.NewLeafIndex(forest, data)
+	rowLeaves := trainLeaves
+	rowLabels := data.CaseLabels
+	rowBags := bags
+	if *newfm != "" {
+		testdata, err := CloudForest.LoadAFM(*newfm)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rowLeaves = CloudForest.NewLeafIndex(forest, testdata)
+		rowLabels = testdata.CaseLabels
+		rowBags = nil
+	}
+
+	var subset []int
+	if *cases != "" {
+		index := make(map[string]int)
+		for i, label := range rowLabels {
+			index[label] = i
+		}
+		for _, label := range strings.Split(*cases, ",") {
+			i, ok := index[label]
+			if !ok {
+				log.Fatalf("Case %v not found.", label)
+			}
+			subset = append(subset, i)
+		}
+	}
+
+	prox := CloudForest.Proximity(rowLeaves, trainLeaves, rowBags, bags, subset, nCores)
+	fmt.Printf("Calculated %v proximity rows with %v nonzero entries.\n", len(prox.Rows), prox.NNZ())
+
+	fmt.Printf("Outputting proximities to %v\n", *outf)
+	outfile, err := os.Create(*outf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer outfile.Close()
+	if dense {
+		err = prox.WriteDense(outfile, rowLabels, data.CaseLabels)
+	} else {
+		err = prox.WriteSparse(outfile, rowLabels, data.CaseLabels)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
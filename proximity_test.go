@@ -0,0 +1,208 @@
+package CloudForest
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestProximity(t *testing.T) {
+	fm := ParseAFM(strings.NewReader(fm))
+	candidates := []int{2, 3, 4}
+	nCases := fm.Data[0].Length()
+	forest := GrowRandomForest(fm, fm.Data[1].(Feature), candidates, nCases, 3, 20, 1, false, false, false, false, nil)
+
+	var bagtext bytes.Buffer
+	for range forest.Trees {
+		if err := WriteInBag(&bagtext, SampleWithReplacment(nCases, nCases), nCases); err != nil {
+			t.Fatalf("Error writing in bag counts: %v", err)
+		}
+	}
+	inbag, err := ReadInBag(&bagtext)
+	if err != nil || len(inbag) != len(forest.Trees) || len(inbag[0]) != nCases {
+		t.Fatalf("Error reading in bag counts: %v", err)
+	}
+
+	leaves := NewLeafIndex(forest, fm)
+	for _, bags := range [][][]bool{nil, inbag} {
+		prox := Proximity(leaves, leaves, bags, bags, nil, 3)
+		for i := 0; i < nCases; i++ {
+			for j := 0; j < nCases; j++ {
+				same, total := 0, 0
+				for tr := range forest.Trees {
+					if bags != nil && (bags[tr][i] || bags[tr][j]) {
+						continue
+					}
+					total++
+					if leaves.Leaves[tr][i] == leaves.Leaves[tr][j] {
+						same++
+					}
+				}
+				expected := 0.0
+				if same > 0 {
+					expected = float64(same) / float64(total)
+				}
+				if p := prox.Get(i, j); math.Abs(p-expected) > 1e-9 {
+					t.Errorf("Proximity of %v and %v is %v not %v.", i, j, p, expected)
+				}
+			}
+		}
+	}
+
+	subset := Proximity(leaves, leaves, nil, nil, []int{4, 1}, 1)
+	if len(subset.Rows) != 2 || subset.Get(0, 4) != 1.0 {
+		t.Errorf("Subset proximity rows not calculated correctly.")
+	}
+
+	var out bytes.Buffer
+	if err := subset.WriteDense(&out, fm.CaseLabels, fm.CaseLabels); err != nil {
+		t.Errorf("Error writing dense proximities: %v", err)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 3 {
+		t.Errorf("Dense proximities have %v lines not 3.", lines)
+	}
+}
+
+func TestOutlyingness(t *testing.T) {
+	//cases 0-3 are close to each other, case 4 is only weakly close to cases 0 and 3
+	prox := &SparseProximity{[]int{0, 1, 2, 3, 4},
+		5,
+		[]int{0, 4, 8, 12, 16, 19},
+		[]int{0, 1, 2, 4, 0, 1, 2, 3, 0, 1, 2, 3, 1, 2, 3, 4, 0, 3, 4},
+		[]float64{1, .8, .8, .1, .8, 1, .8, .8, .8, .8, 1, .8, .8, .8, 1, .2, .1, .2, 1}}
+	scores, raw := Outlyingness(prox, nil)
+	for k := 0; k < 4; k++ {
+		if scores[k] >= scores[4] {
+			t.Errorf("Case %v has outlyingness %v not less then outlier's %v.", k, scores[k], scores[4])
+		}
+	}
+	if math.Abs(raw[4]-100.0) > 1e-9 {
+		t.Errorf("Raw outlyingness of outlier is %v not 100.", raw[4])
+	}
+
+	scores, _ = Outlyingness(prox, []string{"a", "a", "a", "b", "b"})
+	if scores[3] != scores[4] {
+		t.Errorf("Cases alone in their class don't have equal outlyingness.")
+	}
+
+	//cases 3-5 have no proximity to other cases so half of the raw measures are infinite,
+	//which shouldn't change the scores of cases 0-2
+	rows := []int{0, 3, 6, 9}
+	cols := []int{0, 1, 2, 0, 1, 2, 0, 1, 2}
+	vals := []float64{1, .8, .5, .8, 1, .4, .5, .4, 1}
+	withInf, infRaw := Outlyingness(&SparseProximity{[]int{0, 1, 2, 3, 4, 5}, 6, append(rows, 10, 11, 12),
+		append(cols, 3, 4, 5), append(vals, 1, 1, 1)}, nil)
+	without, _ := Outlyingness(&SparseProximity{[]int{0, 1, 2}, 6, rows, cols, vals}, nil)
+	for k, s := range withInf {
+		if math.IsNaN(s) {
+			t.Errorf("Case %v with raw outlyingness %v has a NaN score.", k, infRaw[k])
+		}
+	}
+	for k := 3; k < 6; k++ {
+		if !math.IsInf(withInf[k], 1) {
+			t.Errorf("Case %v without proximity to its class has score %v not +Inf.", k, withInf[k])
+		}
+	}
+	for k := range without {
+		if math.Abs(withInf[k]-without[k]) > 1e-9 {
+			t.Errorf("Score %v of case %v changed to %v with infinite outliers in its class.", without[k], k, withInf[k])
+		}
+	}
+
+	var out bytes.Buffer
+	if err := WriteOutliers(&out, prox, scores, raw, []string{"0", "1", "2", "3", "4"}, nil); err != nil {
+		t.Errorf("Error writing outliers: %v", err)
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	//three blocks of four cases with high proximity within blocks
+	n := 12
+	prox := make([][]float64, n)
+	for i := range prox {
+		prox[i] = make([]float64, n)
+		for j := range prox[i] {
+			switch {
+			case i == j:
+				prox[i][j] = 1.0
+			case i/4 == j/4:
+				prox[i][j] = 0.8 + 0.01*float64((i+j)%3)
+			default:
+				prox[i][j] = 0.05 * float64((i+j)%2)
+			}
+		}
+	}
+
+	values, vectors := TopEigen([][]float64{{2, 1, 0}, {1, 2, 0}, {0, 0, -5}}, 2)
+	if math.Abs(values[0]-3.0) > 1e-8 || math.Abs(values[1]-1.0) > 1e-8 {
+		t.Errorf("Top eigenvalues are %v not [3 1].", values)
+	}
+	if math.Abs(math.Abs(vectors[0][0])-math.Sqrt(0.5)) > 1e-6 || math.Abs(vectors[0][2]) > 1e-6 {
+		t.Errorf("Top eigenvector is %v.", vectors[0])
+	}
+
+	dist := ProximityDistances(prox)
+	sameBlocks := func(name string, labels []int) {
+		for i := range labels {
+			for j := range labels {
+				if (labels[i] == labels[j]) != (i/4 == j/4) {
+					t.Errorf("%v clusters %v don't match blocks.", name, labels)
+					return
+				}
+			}
+		}
+	}
+	linkage, err := AverageLinkage(dist, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameBlocks("Average linkage", linkage)
+	labels, medoids, err := PAM(dist, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameBlocks("PAM", labels)
+	if len(medoids) != 3 {
+		t.Errorf("PAM returned %v medoids not 3.", len(medoids))
+	}
+
+	//cluster and dimension counts outside of the number of cases are errors
+	for _, k := range []int{0, -1, n + 1} {
+		if _, err := AverageLinkage(dist, k); err == nil {
+			t.Errorf("No error for average linkage with %v clusters.", k)
+		}
+		if _, _, err := PAM(dist, k); err == nil {
+			t.Errorf("No error for PAM with %v clusters.", k)
+		}
+		if _, _, err := ClassicalMDS(prox, k); err == nil {
+			t.Errorf("No error for MDS in %v dimensions.", k)
+		}
+	}
+	if _, err := SpectralEmbedding(prox, n); err == nil {
+		t.Errorf("No error for spectral embedding in %v dimensions.", n)
+	}
+
+	for _, method := range []string{"mds", "spectral"} {
+		var coords [][]float64
+		if method == "mds" {
+			coords, _, err = ClassicalMDS(prox, 2)
+		} else {
+			coords, err = SpectralEmbedding(prox, 2)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := func(i, j int) float64 {
+			return math.Hypot(coords[i][0]-coords[j][0], coords[i][1]-coords[j][1])
+		}
+		if !(d(0, 1) < d(0, 4) && d(4, 5) < d(4, 8) && d(8, 9) < d(8, 0)) {
+			t.Errorf("%v embedding doesn't separate blocks: %v", method, coords)
+		}
+	}
+
+	labelsRead, dense, err := ReadProximityPairs(strings.NewReader("a\tb\t4\nb\tc\t2\na\ta\t8\n"))
+	if err != nil || len(labelsRead) != 3 || dense[0][1] != 0.5 || dense[2][1] != 0.25 || dense[1][1] != 1.0 {
+		t.Errorf("Proximity pairs read incorrectly: %v %v %v", labelsRead, dense, err)
+	}
+}
@@ -0,0 +1,83 @@
+package CloudForest
+
+import (
+	"fmt"
+)
+
+/*
+QuantileTarget wraps a numerical feature as a target for use in quantile regression.
+Impurity is the mean quantile (pinball) loss:
+
+	Alpha*r if r >= 0 else (Alpha-1)*r with r = y - predicted
+
+and nodes predict the Alpha quantile of their cases which minimizes it. An Alpha of
+0.5 gives median regression. Gradient boosting a QuantileTarget uses the matching
+QuantileLoss (see TargetLoss).
+*/
+type QuantileTarget struct {
+	NumFeature
+	Alpha float64
+}
+
+/*
+QuantileTarget.SplitImpurity is a quantile loss version of SplitImpurity.
+*/
+func (target *QuantileTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	nl := float64(len(*l))
+	nr := float64(len(*r))
+	nm := 0.0
+
+	impurityDecrease = nl * target.Impurity(l, nil)
+	impurityDecrease += nr * target.Impurity(r, nil)
+	if m != nil && len(*m) > 0 {
+		nm = float64(len(*m))
+		impurityDecrease += nm * target.Impurity(m, nil)
+	}
+
+	impurityDecrease /= nl + nr + nm
+	return
+}
+
+//UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l as in learning from numerical variables.
+//Here it just wraps SplitImpurity but it can be implemented to provide further optimization.
+func (target *QuantileTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	return target.SplitImpurity(l, r, m, allocs)
+}
+
+//QuantileTarget.Impurity returns the mean quantile loss of the cases vs their Alpha quantile.
+func (target *QuantileTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	e = target.Error(cases, target.Predicted(cases))
+	return
+}
+
+//QuantileTarget.Predicted returns the Alpha quantile of the non missing cases (the smallest
+//value with at least Alpha of the cases at or below it).
+func (target *QuantileTarget) Predicted(cases *[]int) float64 {
+	return quantile(nonMissingValues(target, *cases), target.Alpha)
+}
+
+//QuantileTarget.Error returns the mean quantile loss of the cases specified vs the predicted
+//value. Only non missing cases are considered.
+func (target *QuantileTarget) Error(cases *[]int, predicted float64) (e float64) {
+	n := 0
+	for _, i := range *cases {
+		if !target.IsMissing(i) {
+			r := target.Get(i) - predicted
+			if r >= 0.0 {
+				e += target.Alpha * r
+			} else {
+				e += (target.Alpha - 1.0) * r
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		e /= float64(n)
+	}
+	return
+}
+
+//QuantileTarget.FindPredicted returns the Alpha quantile of the cases as a string.
+func (target *QuantileTarget) FindPredicted(cases []int) (pred string) {
+	return fmt.Sprintf("%v", target.Predicted(&cases))
+}
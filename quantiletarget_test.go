@@ -0,0 +1,47 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestQuantileTarget(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fm := ParseAFM(strings.NewReader(".\ta\tb\tc\td\te\nN:Y\t5\t1\t4\t2\t3\n"))
+	cases := []int{0, 1, 2, 3, 4}
+	target := &QuantileTarget{fm.Data[0].(NumFeature), 0.8}
+	if p := target.Predicted(&cases); p != 4.0 {
+		t.Errorf("0.8 quantile %v not 4.", p)
+	}
+	//residuals 1,-3,0,-2,-1
+	if e := target.Impurity(&cases, nil); math.Abs(e-(0.8+0.2*6.0)/5.0) > 1e-12 {
+		t.Errorf("Quantile impurity %v not %v.", e, (0.8+0.2*6.0)/5.0)
+	}
+
+	//y = x + uniform noise so the 0.9 quantile is x + 0.9
+	n := 500
+	x := make([]string, 0, n)
+	y := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		v := rng.Float64()
+		x = append(x, fmt.Sprintf("%v", v))
+		y = append(y, fmt.Sprintf("%v", v+rng.Float64()))
+	}
+	fm = parseRows([]string{"N:Y", "N:X"}, [][]string{y, x})
+	target = &QuantileTarget{fm.Data[0].(NumFeature), 0.9}
+	forest := GrowRandomForest(fm, target, []int{1}, n, 1, 20, 20, false, false, false, false, nil)
+	bb := NewNumBallotBox(n)
+	for _, tree := range forest.Trees {
+		tree.Vote(fm, bb)
+	}
+	e := 0.0
+	for i := 0; i < n; i++ {
+		e += math.Abs(bb.TallyNum(i) - (fm.Data[1].(NumFeature).Get(i) + 0.9))
+	}
+	if e /= float64(n); e > 0.15 {
+		t.Errorf("Quantile forest mean absolute error vs the true 0.9 quantile is %v.", e)
+	}
+}
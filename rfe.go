@@ -0,0 +1,287 @@
+package CloudForest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+//RFEStep records the features used and the error measured at one step of recursive
+//feature elimination.
+type RFEStep struct {
+	Features []int
+	Error    float64
+	SE       float64
+}
+
+/*
+RFE implements recursive feature elimination. At each step forests are grown using
+the features that are not blacklisted, the error is measured and the features are
+ranked by importance. The least important DropFraction of them (at least one) are then
+added to the blacklist and the process repeats until MinFeatures remain.
+
+If NFolds is <= 1 error is measured out of bag, otherwise by NFolds fold cross
+validation. Either way the measurement is repeated NReps times with independent forests.
+MTry is recalculated as the square root of the number of features at each step if it is
+<= 0.
+
+With cross validation the error of each fold is measured on its own. Error is the mean
+over all folds and repetitions. SE is the standard deviation of the fold errors divided
+by the square root of NFolds, the standard error of a cross validated estimate used by
+the one standard error rule (see Best). Repetitions give more fold errors to estimate
+the standard deviation from but don't shrink SE.
+
+Out of bag, SE is the standard error of the mean over the NReps forests. It only reflects
+the variation between forests grown on the same cases, not the sampling variation of the
+cases, so it is usually small. Best then rarely selects fewer features than the best step.
+*/
+type RFE struct {
+	Target       int
+	NTrees       int
+	MTry         int
+	LeafSize     int
+	DropFraction float64
+	MinFeatures  int
+	NFolds       int
+	NReps        int
+	Steps        []*RFEStep
+}
+
+/*
+Run performs recursive feature elimination on fm. blacklist should be parallel to
+fm.Data and have the features to exclude from the start marked; eliminated features are
+marked in it as they are dropped.
+*/
+func (r *RFE) Run(fm *FeatureMatrix, blacklist []bool) {
+	for {
+		features := make([]int, 0, len(fm.Data))
+		for i := range fm.Data {
+			if i != r.Target && !blacklist[i] {
+				features = append(features, i)
+			}
+		}
+		if len(features) == 0 {
+			return
+		}
+
+		e, se, importance := r.Evaluate(fm, features)
+		r.Steps = append(r.Steps, &RFEStep{features, e, se})
+		if len(features) <= r.MinFeatures || len(features) == 1 {
+			return
+		}
+
+		sort.Sort(&featuresByImportance{features, importance})
+		nDrop := int(r.DropFraction * float64(len(features)))
+		if nDrop < 1 {
+			nDrop = 1
+		}
+		if len(features)-nDrop < r.MinFeatures {
+			nDrop = len(features) - r.MinFeatures
+		}
+		for _, fi := range features[:nDrop] {
+			blacklist[fi] = true
+		}
+	}
+}
+
+/*
+Evaluate grows NReps forests (or sets of NFolds forests) using the candidate features
+and returns the mean error, its standard error (as described for RFE) and the importance
+of every feature in fm (mean impurity decrease per tree) averaged over all forests.
+*/
+func (r *RFE) Evaluate(fm *FeatureMatrix, candidates []int) (e float64, se float64, importance []float64) {
+	targetf := fm.Data[r.Target]
+	nCases := targetf.Length()
+	nonMissing := make([]int, 0, nCases)
+	for i := 0; i < nCases; i++ {
+		if !targetf.IsMissing(i) {
+			nonMissing = append(nonMissing, i)
+		}
+	}
+
+	mTry := r.MTry
+	if mTry <= 0 {
+		mTry = int(math.Ceil(math.Sqrt(float64(len(candidates)))))
+	}
+
+	newBallotBox := func() VoteTallyer {
+		if targetf.NCats() == 0 {
+			return NewNumBallotBox(nCases)
+		}
+		return NewCatBallotBox(nCases)
+	}
+
+	imppnt := NewRunningMeans(len(fm.Data))
+	//the error of each forest out of bag or of each fold
+	errors := make([]float64, 0, r.NReps)
+	nForests := 0
+	var foldTarget Feature
+	for rep := 0; rep < r.NReps; rep++ {
+		if r.NFolds <= 1 {
+			bb := newBallotBox()
+			r.growAndVote(fm, candidates, mTry, nonMissing, nil, bb, imppnt)
+			nForests++
+			errors = append(errors, bb.TallyError(targetf))
+			continue
+		}
+
+		shuffled := make([]int, len(nonMissing))
+		for i, j := range rand.Perm(len(nonMissing)) {
+			shuffled[i] = nonMissing[j]
+		}
+		for fold := 0; fold < r.NFolds; fold++ {
+			train := make([]int, 0, len(shuffled))
+			test := make([]int, 0, len(shuffled)/r.NFolds+1)
+			for i, c := range shuffled {
+				if i%r.NFolds == fold {
+					test = append(test, c)
+				} else {
+					train = append(train, c)
+				}
+			}
+			bb := newBallotBox()
+			r.growAndVote(fm, candidates, mTry, train, test, bb, imppnt)
+			nForests++
+
+			//tally against a copy of the target with the cases outside of the fold missing
+			if foldTarget == nil {
+				foldTarget = targetf.Copy()
+			}
+			targetf.CopyInTo(foldTarget)
+			for _, c := range train {
+				foldTarget.PutMissing(c)
+			}
+			errors = append(errors, bb.TallyError(foldTarget))
+		}
+	}
+
+	for _, v := range errors {
+		e += v
+	}
+	e /= float64(len(errors))
+	if len(errors) > 1 {
+		for _, v := range errors {
+			se += (v - e) * (v - e)
+		}
+		n := len(errors)
+		if r.NFolds > 1 {
+			n = r.NFolds
+		}
+		se = math.Sqrt(se/float64(len(errors)-1)) / math.Sqrt(float64(n))
+	}
+
+	importance = make([]float64, len(fm.Data))
+	for i, rm := range *imppnt {
+		mean, count := rm.Read()
+		importance[i] = mean * count / float64(r.NTrees*nForests)
+	}
+	return
+}
+
+//growAndVote grows a forest by bagging from train and votes test (or each tree's
+//out of bag cases if test is nil) into bb.
+func (r *RFE) growAndVote(fm *FeatureMatrix, candidates []int, mTry int, train []int, test []int, bb VoteTallyer, importance *[]*RunningMean) {
+	target := fm.Data[r.Target]
+	nCases := target.Length()
+	allocs := NewBestSplitAllocs(len(train), target)
+	cases := make([]int, 0, len(train))
+	inbag := make([]bool, nCases)
+	oobcases := make([]int, 0, len(train))
+	for t := 0; t < r.NTrees; t++ {
+		cases = cases[0:0]
+		for j := 0; j < len(train); j++ {
+			cases = append(cases, train[rand.Intn(len(train))])
+		}
+		tree := NewTree()
+		tree.Grow(fm, target, cases, candidates, nil, mTry, r.LeafSize, false, false, false, false, importance, nil, allocs)
+
+		if test != nil {
+			tree.VoteCases(fm, bb, test)
+			continue
+		}
+		for _, c := range cases {
+			inbag[c] = true
+		}
+		oobcases = oobcases[0:0]
+		for _, c := range train {
+			if !inbag[c] {
+				oobcases = append(oobcases, c)
+			}
+		}
+		for _, c := range cases {
+			inbag[c] = false
+		}
+		tree.VoteCases(fm, bb, oobcases)
+	}
+}
+
+/*
+Best returns the index of the step with the lowest error and the index of the step with
+the fewest features whose error is within one standard error of the lowest. SE is only a
+meaningful cross validation standard error if NFolds > 1; see RFE.
+*/
+func (r *RFE) Best() (best int, oneSE int) {
+	for i, step := range r.Steps {
+		if step.Error < r.Steps[best].Error {
+			best = i
+		}
+	}
+	oneSE = best
+	limit := r.Steps[best].Error + r.Steps[best].SE
+	for i, step := range r.Steps {
+		if step.Error <= limit && len(step.Features) < len(r.Steps[oneSE].Features) {
+			oneSE = i
+		}
+	}
+	return
+}
+
+/*
+WriteSteps writes the error at each step as a tsv with a header row and columns:
+
+	Step	NFeatures	Error	SE	Dropped
+
+where Dropped is a comma separated list of the features eliminated after the step.
+*/
+func (r *RFE) WriteSteps(w io.Writer, fm *FeatureMatrix) (err error) {
+	if _, err = fmt.Fprintln(w, "Step\tNFeatures\tError\tSE\tDropped"); err != nil {
+		return
+	}
+	for i, step := range r.Steps {
+		dropped := ""
+		if i+1 < len(r.Steps) {
+			kept := make(map[int]bool)
+			for _, fi := range r.Steps[i+1].Features {
+				kept[fi] = true
+			}
+			for _, fi := range step.Features {
+				if !kept[fi] {
+					if dropped != "" {
+						dropped += ","
+					}
+					dropped += fm.Data[fi].GetName()
+				}
+			}
+		}
+		if _, err = fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", i, len(step.Features), step.Error, step.SE, dropped); err != nil {
+			return
+		}
+	}
+	return
+}
+
+//featuresByImportance sorts features by increasing importance.
+type featuresByImportance struct {
+	Features   []int
+	Importance []float64
+}
+
+func (s *featuresByImportance) Len() int { return len(s.Features) }
+func (s *featuresByImportance) Swap(i, j int) {
+	s.Features[i], s.Features[j] = s.Features[j], s.Features[i]
+}
+func (s *featuresByImportance) Less(i, j int) bool {
+	return s.Importance[s.Features[i]] < s.Importance[s.Features[j]]
+}
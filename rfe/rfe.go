@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ryanbressler/CloudForest"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+)
+
+func main() {
+	fm := flag.String("train",
+		"featurematrix.afm", "AFM formated feature matrix containing training data.")
+	targetname := flag.String("target",
+		"", "The row header of the target in the feature matrix.")
+	blacklist := flag.String("blacklist",
+		"", "A list of feature id's to exclude from the set of predictors.")
+	outf := flag.String("out",
+		"rfe.tsv", "The name of a file to write the error at each step into.")
+	selectedf := flag.String("selected",
+		"", "The name of a file to write the smallest feature set within one standard error of the best into.")
+	blackout := flag.String("blacklistout",
+		"", "The name of a file to write a blacklist excluding all features not in the selected set into.")
+	var dropFraction float64
+	flag.Float64Var(&dropFraction, "drop", 0.2, "Fraction of the remaining features to drop at each step (at least one).")
+	var minFeatures int
+	flag.IntVar(&minFeatures, "min", 1, "Minimum number of features to keep.")
+	var nTrees int
+	flag.IntVar(&nTrees, "nTrees", 100, "Number of trees to grow in each forest.")
+	var mTry int
+	flag.IntVar(&mTry, "mTry", 0, "Number of candidate features for each split. Ceil(sqrt(nFeatures)) if <=0.")
+	var leafSize int
+	flag.IntVar(&leafSize, "leafSize", 0, "The minimum number of cases on a leaf node. If <=0 will be inferred to 1 for classification 4 for regression.")
+	var nFolds int
+	flag.IntVar(&nFolds, "nfold", 0, "Measure error with n fold cross validation instead of out of bag if > 1.")
+	var nReps int
+	flag.IntVar(&nReps, "reps", 3, "Number of times to repeat the error measurement at each step.")
+
+	flag.Parse()
+
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	//Parse Data
+	fmt.Printf("Loading data from: %v\n", *fm)
+	data, err := CloudForest.LoadAFM(*fm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Target : %v\n", *targetname)
+	targeti, ok := data.Map[*targetname]
+	if !ok {
+		log.Fatal("Target not found in data.")
+	}
+
+	blacklistis := make([]bool, len(data.Data))
+	if *blacklist != "" {
+		fmt.Printf("Loading blacklist from: %v\n", *blacklist)
+		if _, err = CloudForest.LoadBlacklist(*blacklist, data, blacklistis); err != nil {
+			log.Fatal(err)
+		}
+	}
+	initial := make([]bool, len(blacklistis))
+	copy(initial, blacklistis)
+
+	if leafSize <= 0 {
+		if data.Data[targeti].NCats() == 0 {
+			//regression
+			leafSize = 4
+		} else {
+			//classification
+			leafSize = 1
+		}
+	}
+
+	rfe := &CloudForest.RFE{Target: targeti,
+		NTrees:       nTrees,
+		MTry:         mTry,
+		LeafSize:     leafSize,
+		DropFraction: dropFraction,
+		MinFeatures:  minFeatures,
+		NFolds:       nFolds,
+		NReps:        nReps}
+	rfe.Run(data, blacklistis)
+	for i, step := range rfe.Steps {
+		fmt.Printf("Step %v : %v features, error %v (se %v)\n", i, len(step.Features), step.Error, step.SE)
+	}
+
+	best, oneSE := rfe.Best()
+	fmt.Printf("Lowest error with %v features, smallest set within one standard error has %v features.\n",
+		len(rfe.Steps[best].Features), len(rfe.Steps[oneSE].Features))
+
+	fmt.Printf("Outputting error by step to %v\n", *outf)
+	outfile, err := os.Create(*outf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer outfile.Close()
+	if err = rfe.WriteSteps(outfile, data); err != nil {
+		log.Fatal(err)
+	}
+
+	selected := make(map[int]bool)
+	for _, fi := range rfe.Steps[oneSE].Features {
+		selected[fi] = true
+	}
+
+	if *selectedf != "" {
+		fmt.Printf("Outputting selected features to %v\n", *selectedf)
+		selectedfile, err := os.Create(*selectedf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer selectedfile.Close()
+		for i, f := range data.Data {
+			if selected[i] {
+				fmt.Fprintln(selectedfile, f.GetName())
+			}
+		}
+	}
+
+	if *blackout != "" {
+		fmt.Printf("Outputting blacklist to %v\n", *blackout)
+		blackfile, err := os.Create(*blackout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer blackfile.Close()
+		for i, f := range data.Data {
+			if i != targeti && !selected[i] && !initial[i] {
+				fmt.Fprintln(blackfile, f.GetName())
+			}
+		}
+	}
+}
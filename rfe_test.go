@@ -0,0 +1,50 @@
+package CloudForest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRFE(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping recursive feature elimination on iris data set.")
+	}
+	fm := ParseLibSVM(strings.NewReader(irislibsvm))
+	fm.AddContrasts(2)
+
+	for _, nFolds := range []int{0, 3} {
+		blacklist := make([]bool, len(fm.Data))
+		rfe := &RFE{0, 20, 0, 1, 0.5, 1, nFolds, 2, nil}
+		rfe.Run(fm, blacklist)
+
+		nFeatures := []int{6, 3, 2, 1}
+		if len(rfe.Steps) != len(nFeatures) {
+			t.Fatalf("RFE took %v steps not %v.", len(rfe.Steps), len(nFeatures))
+		}
+		for i, step := range rfe.Steps {
+			if len(step.Features) != nFeatures[i] {
+				t.Errorf("RFE step %v used %v features not %v.", i, len(step.Features), nFeatures[i])
+			}
+		}
+		for _, fi := range rfe.Steps[2].Features {
+			if strings.HasSuffix(fm.Data[fi].GetName(), ":SHUFFLED") {
+				t.Errorf("Shuffled contrast %v survived to the last two features.", fm.Data[fi].GetName())
+			}
+		}
+
+		if nFolds > 1 && rfe.Steps[0].SE == 0.0 {
+			t.Errorf("Standard error over %v folds was 0.", nFolds)
+		}
+
+		best, oneSE := rfe.Best()
+		if len(rfe.Steps[oneSE].Features) > len(rfe.Steps[best].Features) || rfe.Steps[oneSE].Error > rfe.Steps[best].Error+rfe.Steps[best].SE {
+			t.Errorf("One standard error step %v not consistent with best step %v.", oneSE, best)
+		}
+
+		var out bytes.Buffer
+		if err := rfe.WriteSteps(&out, fm); err != nil {
+			t.Errorf("Error writing RFE steps: %v", err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package CloudForest
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+SoftmaxBallotBox tallies the votes of gradient boosted classification forests (see
+GradBoostClassTarget) whose trees vote "class:score". The weighted scores for each
+class are summed and converted into class probabilities with the softmax function.
+Voting is thread safe.
+*/
+type SoftmaxBallotBox struct {
+	*CatMap
+	box   [][]float64
+	mutex sync.Mutex
+}
+
+//NewSoftmaxBallotBox builds a new ballot box for the number of cases specified by "size".
+func NewSoftmaxBallotBox(size int) *SoftmaxBallotBox {
+	return &SoftmaxBallotBox{&CatMap{make(map[string]int), make([]string, 0, 0)},
+		make([][]float64, size),
+		sync.Mutex{}}
+}
+
+//Vote parses the "class:score" pred and adds the score times weight to the class's sum for
+//case casei.
+func (bb *SoftmaxBallotBox) Vote(casei int, pred string, weight float64) {
+	sep := strings.LastIndex(pred, ":")
+	if sep < 0 {
+		return
+	}
+	score, err := strconv.ParseFloat(pred[sep+1:], 64)
+	if err != nil {
+		return
+	}
+	bb.mutex.Lock()
+	k := bb.CatToNum(pred[:sep])
+	for len(bb.box[casei]) <= k {
+		bb.box[casei] = append(bb.box[casei], 0.0)
+	}
+	bb.box[casei][k] += weight * score
+	bb.mutex.Unlock()
+}
+
+//TallyProbabilities returns the probability of each class (in the order of Back) for
+//case i or nil if no votes were cast.
+func (bb *SoftmaxBallotBox) TallyProbabilities(i int) []float64 {
+	bb.mutex.Lock()
+	defer bb.mutex.Unlock()
+	if len(bb.box[i]) == 0 {
+		return nil
+	}
+	scores := make([]float64, len(bb.Back))
+	copy(scores, bb.box[i])
+	return softmax(scores)
+}
+
+//TallyProbability returns the probability that case i is of class cat.
+func (bb *SoftmaxBallotBox) TallyProbability(i int, cat string) float64 {
+	probs := bb.TallyProbabilities(i)
+	bb.mutex.Lock()
+	k, ok := bb.Map[cat]
+	bb.mutex.Unlock()
+	if probs == nil || !ok {
+		return 0.0
+	}
+	return probs[k]
+}
+
+//Tally returns the most probable class for case i or "NA" if no votes were cast.
+func (bb *SoftmaxBallotBox) Tally(i int) (predicted string) {
+	probs := bb.TallyProbabilities(i)
+	if probs == nil {
+		return "NA"
+	}
+	best := 0
+	for k, p := range probs {
+		if p > probs[best] {
+			best = k
+		}
+	}
+	bb.mutex.Lock()
+	predicted = bb.Back[best]
+	bb.mutex.Unlock()
+	return
+}
+
+//TallyError returns the balanced classification error (one minus the mean of the
+//accuracy of each class) as in CatBallotBox.
+func (bb *SoftmaxBallotBox) TallyError(feature Feature) (e float64) {
+	catfeature := feature.(CatFeature)
+	ncats := catfeature.NCats()
+	correct := make([]int, ncats)
+	total := make([]int, ncats)
+
+	for i := 0; i < feature.Length(); i++ {
+		if !feature.IsMissing(i) {
+			value := catfeature.Geti(i)
+			total[value]++
+			if catfeature.NumToCat(value) == bb.Tally(i) {
+				correct[value]++
+			}
+		}
+	}
+
+	for i, ncorrect := range correct {
+		e += float64(ncorrect) / float64(total[i])
+	}
+	e = 1.0 - e/float64(ncats)
+	return
+}
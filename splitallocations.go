@@ -27,6 +27,14 @@ type BestSplitAllocs struct {
 	SortVals       []float64
 	Sorter         *SortableFeature //for learning from numerical features
 	ContrastTarget Target
+	Sub            []*BestSplitAllocs   //allocations for each component of a MultiTarget
+	Histograms     *HistogramCache      //histograms for binned split searching
+	Presort        *PresortedCases      //presorted cases for numerical split searching or nil
+	ExtraTrees     bool                 //draw random splits instead of searching as in extremely randomized trees
+	Oblique        *ObliqueSplits       //oblique split searching or nil
+	Multiway       int                  //split categorical features with at most this many categories multiway
+	Monotone       *MonotoneConstraints //monotone constraints on numerical features or nil
+	Survival       *SurvivalCounts      //log-rank counts for incremental survival splitting
 }
 
 //NewBestSplitAllocs initializes all of the reusable allocations for split
@@ -61,6 +69,28 @@ func NewBestSplitAllocs(nTotalCases int, target Target) (bsa *BestSplitAllocs) {
 		make([]float64, nTotalCases, nTotalCases),
 		&SortableFeature{make([]float64, nTotalCases, nTotalCases),
 			nil},
-		target.(Feature).Copy().(Target)}
+		target.(Feature).Copy().(Target),
+		nil,
+		NewHistogramCache(),
+		nil,
+		false,
+		nil,
+		0,
+		nil,
+		nil}
+	if mt, ok := target.(*MultiTarget); ok {
+		for _, t := range mt.Targets {
+			bsa.Sub = append(bsa.Sub, NewBestSplitAllocs(nTotalCases, t))
+		}
+	}
 	return
 }
+
+//Impurity returns the impurity of target over cases using the class counter in allocs
+//or, for a MultiTarget, the counters of its components in allocs.Sub.
+func (allocs *BestSplitAllocs) Impurity(target Target, cases *[]int) float64 {
+	if mt, ok := target.(*MultiTarget); ok && len(allocs.Sub) == len(mt.Targets) {
+		return mt.ImpurityFromAllocs(cases, allocs)
+	}
+	return target.Impurity(cases, allocs.Counter)
+}
@@ -1,20 +1,63 @@
 package CloudForest
 
 import (
-//"fmt"
+	//"fmt"
+	"strings"
 )
 
 //Splitter contains fields that can be used to cases by a single feature. The split
 //can be either numerical in which case it is defined by the Value field or
 //categorical in which case it is defined by the Left and Right fields.
+//
+//Oblique splitters (see ObliqueSplits) have Features and Weights set and send cases
+//for which the weighted sum of the numerical Features is <= Value left. Their Feature
+//is the names of the Features joined by commas.
+//
+//Multiway splitters (see MultiwaySplit) have Levels set and send cases with each
+//category in Levels down the node's corresponding branch and cases with other
+//categories down the Fallback branch.
 type Splitter struct {
 	Feature   string
 	Numerical bool
 	Value     float64
 	Left      map[string]bool
+	Features  []string
+	Weights   []float64
+	Levels    []string
+	Fallback  int
 }
 
-//func
+//NewObliqueSplitter returns a numerical splitter that sends cases for which the weighted
+//sum of the specified features is <= value left.
+func NewObliqueSplitter(features []string, weights []float64, value float64) *Splitter {
+	return &Splitter{Feature: strings.Join(features, ","), Numerical: true, Value: value, Features: features, Weights: weights}
+}
+
+//Oblique checks if the splitter splits on a linear combination of features.
+func (s *Splitter) Oblique() bool {
+	return s.Weights != nil
+}
+
+//obliqueFeatures looks up the features of an oblique splitter.
+func (s *Splitter) obliqueFeatures(fm *FeatureMatrix) (fs []NumFeature) {
+	fs = make([]NumFeature, 0, len(s.Features))
+	for _, name := range s.Features {
+		fs = append(fs, fm.Data[fm.Map[name]].(NumFeature))
+	}
+	return
+}
+
+//obliqueValue returns the weighted sum of the features for case i and false if any
+//of them are missing.
+func (s *Splitter) obliqueValue(fs []NumFeature, i int) (v float64, ok bool) {
+	for k, f := range fs {
+		if f.IsMissing(i) {
+			return 0.0, false
+		}
+		v += s.Weights[k] * f.Get(i)
+	}
+	return v, true
+}
 
 /*
 Split splits a slice of cases into left, right and missing slices without allocating
@@ -28,16 +71,32 @@ func (s *Splitter) Split(fm *FeatureMatrix, cases []int) (l []int, r []int, m []
 	lastright := length
 	swaper := 0
 
-	f := fm.Data[fm.Map[s.Feature]]
+	var goesLeft func(i int) (left bool, missing bool)
+	if s.Oblique() {
+		fs := s.obliqueFeatures(fm)
+		goesLeft = func(i int) (bool, bool) {
+			v, ok := s.obliqueValue(fs, i)
+			return v <= s.Value, !ok
+		}
+	} else {
+		f := fm.Data[fm.Map[s.Feature]]
+		hasmissing := f.MissingVals()
+		goesLeft = func(i int) (bool, bool) {
+			if hasmissing && f.IsMissing(i) {
+				return false, true
+			}
+			return f.GoesLeft(i, s), false
+		}
+	}
 
 	//Move left cases to the start and right cases to the end so that missing cases end up
 	//in between.
-	hasmissing := f.MissingVals()
 	for i := 0; i < lastright; i++ {
-		if hasmissing && f.IsMissing(cases[i]) {
+		left, missing := goesLeft(cases[i])
+		if missing {
 			continue
 		}
-		if f.GoesLeft(cases[i], s) {
+		if left {
 			lastleft++
 			if i != lastleft {
 
@@ -66,3 +125,14 @@ func (s *Splitter) Split(fm *FeatureMatrix, cases []int) (l []int, r []int, m []
 
 	return
 }
+
+//splitPoints partitions cases in place into left, missing and right cases by either a
+//coded split of the fi'th feature or, for oblique splits, a *Splitter and returns the
+//index of the first missing and the first right case.
+func splitPoints(fm *FeatureMatrix, fi int, codedSplit interface{}, cases *[]int) (li int, ri int) {
+	if s, ok := codedSplit.(*Splitter); ok {
+		l, _, m := s.Split(fm, *cases)
+		return len(l), len(l) + len(m)
+	}
+	return fm.Data[fi].SplitPoints(codedSplit, cases)
+}
@@ -1,6 +1,6 @@
 /*
-Package stats currentelly only implements a welch's t-test for importance score analysis
-in CloudForest.
+Package stats currentelly implements a welch's t-test and a binomial test for importance
+score analysis in CloudForest.
 */
 package stats
 
@@ -110,3 +110,21 @@ func lgamma(x float64) float64 {
 func beta(a, b float64) float64 {
 	return (math.Exp(lgamma(a) + lgamma(b) - lgamma(a+b)))
 }
+
+/*
+BinomialTest returns the one sided p-values for observing k or more (upper) and k or
+fewer (lower) successes in n independent trials with success probability p.
+*/
+func BinomialTest(k, n int, p float64) (upper, lower float64) {
+	for i := 0; i <= n; i++ {
+		pmf := math.Exp(lgamma(float64(n+1)) - lgamma(float64(i+1)) - lgamma(float64(n-i+1)) +
+			float64(i)*math.Log(p) + float64(n-i)*math.Log(1-p))
+		if i >= k {
+			upper += pmf
+		}
+		if i <= k {
+			lower += pmf
+		}
+	}
+	return
+}
@@ -69,3 +69,16 @@ func TestTTest(t *testing.T) {
 		t.Errorf("Bad degrees freedom from TTest. %v not close to 17.925", df)
 	}
 }
+
+func TestBinomialTest(t *testing.T) {
+	/* From R:
+	> binom.test(7,10,alternative="greater")$p.value
+	[1] 0.171875
+	> binom.test(7,10,alternative="less")$p.value
+	[1] 0.9453125
+	*/
+	upper, lower := BinomialTest(7, 10, 0.5)
+	if notE(upper, 0.171875) || notE(lower, 0.9453125) {
+		t.Errorf("Bad p values from BinomialTest %v, %v not close to 0.171875, 0.9453125", upper, lower)
+	}
+}
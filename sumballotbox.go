@@ -0,0 +1,93 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+/*
+SumBallotBox tallies the votes of additive forests like those grown by gradient boosting
+where the prediction for a case is the Intercept plus the weighted sum of the votes of
+all trees instead of their mean.
+Voting is thread safe.
+*/
+type SumBallotBox struct {
+	Intercept float64
+	box       []float64
+	counts    []int
+	mutex     sync.Mutex
+}
+
+//NewSumBallotBox builds a new ballot box for the number of cases specified by "size".
+func NewSumBallotBox(size int, intercept float64) *SumBallotBox {
+	return &SumBallotBox{intercept, make([]float64, size), make([]int, size), sync.Mutex{}}
+}
+
+//Vote parses the float in the string and adds it times weight to the case's sum.
+func (bb *SumBallotBox) Vote(casei int, pred string, weight float64) {
+	v, err := strconv.ParseFloat(pred, 64)
+	if err == nil {
+		bb.mutex.Lock()
+		bb.box[casei] += weight * v
+		bb.counts[casei]++
+		bb.mutex.Unlock()
+	}
+}
+
+//TallyNum returns the intercept plus the sum of the votes for case i.
+func (bb *SumBallotBox) TallyNum(i int) (predicted float64) {
+	bb.mutex.Lock()
+	predicted = bb.Intercept + bb.box[i]
+	bb.mutex.Unlock()
+	return
+}
+
+//Tally returns the prediction for case i as a string or "NA" if no votes were cast.
+func (bb *SumBallotBox) Tally(i int) (predicted string) {
+	bb.mutex.Lock()
+	count := bb.counts[i]
+	bb.mutex.Unlock()
+	if count == 0 {
+		return "NA"
+	}
+	return fmt.Sprintf("%v", bb.TallyNum(i))
+}
+
+//TallyError returns the squared error of the predictions divided by the variance of the
+//feature (the unexplained variance) as in NumBallotBox. Missing values and cases without
+//votes are ignored.
+func (bb *SumBallotBox) TallyError(feature Feature) (e float64) {
+	nf := feature.(NumFeature)
+	mean := 0.0
+	total := 0
+	for i := 0; i < nf.Length(); i++ {
+		if !nf.IsMissing(i) {
+			mean += nf.Get(i)
+			total++
+		}
+	}
+	mean /= float64(total)
+
+	r2 := 0.0
+	se := 0.0
+	c := 0
+	for i := 0; i < nf.Length(); i++ {
+		if nf.IsMissing(i) {
+			continue
+		}
+		d := nf.Get(i) - mean
+		r2 += d * d
+		if bb.counts[i] > 0 {
+			d = nf.Get(i) - bb.TallyNum(i)
+			se += d * d
+			c++
+		}
+	}
+	if c == 0 {
+		return math.NaN()
+	}
+	e = (se / float64(c)) / (r2 / float64(total))
+	return
+}
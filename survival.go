@@ -0,0 +1,528 @@
+package CloudForest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+SurvivalTarget wraps a numerical time to event feature and an event indicator for use
+in random survival forests (Ishwaran et al.). Cases where the event indicator is 0 or
+false are right censored at their time.
+
+Splits are chosen to maximize the two sample log-rank statistic. Since the log-rank
+statistic measures the separation of a split rather than the purity of its branches
+Impurity returns 1 for any set of cases containing an event (and 0 otherwise) and
+SplitImpurity returns 1 minus the log-rank chi-square statistic so that the impurity
+decrease of a split is its log-rank statistic.
+
+Leaves predict the Nelson-Aalen estimate of the cumulative hazard function (see
+FindPredicted) which is averaged across trees by SurvivalBallotBox.
+*/
+type SurvivalTarget struct {
+	NumFeature
+	Events       []bool
+	EventMissing []bool
+}
+
+/*
+NewSurvivalTarget creates a SurvivalTarget from the time to event feature and the
+event indicator, which can be numerical or categorical and is parsed with
+ParseEvent.
+*/
+func NewSurvivalTarget(time NumFeature, event Feature) *SurvivalTarget {
+	events, missing := ParseEvents(event)
+	return &SurvivalTarget{time, events, missing}
+}
+
+//ParseEvents parses each value of an event indicator feature with ParseEvent and returns
+//the events and which values are missing or can't be parsed.
+func ParseEvents(event Feature) (events []bool, missing []bool) {
+	n := event.Length()
+	events = make([]bool, n)
+	missing = make([]bool, n)
+	for i := 0; i < n; i++ {
+		if event.IsMissing(i) {
+			missing[i] = true
+			continue
+		}
+		e, err := ParseEvent(event.GetStr(i))
+		events[i] = e
+		missing[i] = err != nil
+	}
+	return
+}
+
+//ParseEvent parses an event indicator value: numbers are events if they aren't zero and
+//strings are parsed by strconv.ParseBool.
+func ParseEvent(v string) (event bool, err error) {
+	if f, e := strconv.ParseFloat(v, 64); e == nil {
+		return f != 0.0, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+//IsMissing returns true if either the time or event indicator of case i is missing.
+func (target *SurvivalTarget) IsMissing(i int) bool {
+	return target.NumFeature.IsMissing(i) || target.EventMissing[i]
+}
+
+//Impurity returns 1 if any of the cases has an event and 0 otherwise.
+func (target *SurvivalTarget) Impurity(cases *[]int, counter *[]int) (e float64) {
+	for _, i := range *cases {
+		if !target.IsMissing(i) && target.Events[i] {
+			return 1.0
+		}
+	}
+	return 0.0
+}
+
+/*
+SplitImpurity returns 1 minus the log-rank statistic comparing the survival of the left
+and right cases. Missing cases (when splitting missing values to a third branch) are
+not compared but reduce the statistic in proportion to their number as missing cases
+are weighted in the impurity of other targets.
+
+The counts of cases and events at each time are kept in allocs.Survival so
+UpdateSImpFromAllocs can update them as cases are moved from right to left.
+*/
+func (target *SurvivalTarget) SplitImpurity(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs) (impurityDecrease float64) {
+	var stat float64
+	if allocs == nil {
+		stat = target.LogRank(l, r)
+	} else {
+		if allocs.Survival == nil {
+			allocs.Survival = new(SurvivalCounts)
+		}
+		allocs.Survival.Load(target, l, r)
+		stat = allocs.Survival.LogRank()
+	}
+	return 1.0 - target.missingAdjusted(stat, l, r, m)
+}
+
+//missingAdjusted reduces a log-rank statistic in proportion to the number of missing cases.
+func (target *SurvivalTarget) missingAdjusted(stat float64, l *[]int, r *[]int, m *[]int) float64 {
+	if m != nil && len(*m) > 0 {
+		nl := float64(len(*l))
+		nr := float64(len(*r))
+		stat *= (nl + nr) / (nl + nr + float64(len(*m)))
+	}
+	return stat
+}
+
+/*
+UpdateSImpFromAllocs willl be called when splits are being built by moving cases from r to l
+as in learning from numerical variables. It moves the cases in movedRtoL to the left counts
+loaded by the last call to SplitImpurity and recalculates the log-rank statistic in time
+proportional to the number of distinct times.
+*/
+func (target *SurvivalTarget) UpdateSImpFromAllocs(l *[]int, r *[]int, m *[]int, allocs *BestSplitAllocs, movedRtoL *[]int) (impurityDecrease float64) {
+	if allocs == nil || allocs.Survival == nil || allocs.Survival.ncases != len(*l)+len(*r) {
+		return target.SplitImpurity(l, r, m, allocs)
+	}
+	allocs.Survival.Move(target, *movedRtoL)
+	return 1.0 - target.missingAdjusted(allocs.Survival.LogRank(), l, r, m)
+}
+
+/*
+SurvivalCounts holds the number of cases leaving the risk set and the number of events at
+each distinct time of a set of cases, in total and for the cases on the left of a split,
+so the log-rank statistic can be updated as cases are moved from right to left while
+searching for the best split of a numerical feature.
+*/
+type SurvivalCounts struct {
+	timeIndex []int
+	ncases    int
+	n         []float64
+	d         []float64
+	nl        []float64
+	dl        []float64
+}
+
+//Load sets the counts to those of the non missing cases in l and r (if not nil).
+func (c *SurvivalCounts) Load(target *SurvivalTarget, l *[]int, r *[]int) {
+	if len(c.timeIndex) < target.Length() {
+		c.timeIndex = make([]int, target.Length())
+	}
+	c.ncases = len(*l)
+	if r != nil {
+		c.ncases += len(*r)
+	}
+	c.n, c.d, c.nl, c.dl = c.n[:0], c.d[:0], c.nl[:0], c.dl[:0]
+
+	cases := make([]int, 0, c.ncases)
+	for _, i := range *l {
+		if !target.IsMissing(i) {
+			cases = append(cases, i)
+		}
+	}
+	nleft := len(cases)
+	if r != nil {
+		for _, i := range *r {
+			if !target.IsMissing(i) {
+				cases = append(cases, i)
+			}
+		}
+	}
+	times := make([]float64, len(cases))
+	for k, i := range cases {
+		times[k] = target.Get(i)
+	}
+	sort.Float64s(times)
+	distinct := times[:0]
+	for k, t := range times {
+		if k == 0 || t != times[k-1] {
+			distinct = append(distinct, t)
+		}
+	}
+	for range distinct {
+		c.n = append(c.n, 0.0)
+		c.d = append(c.d, 0.0)
+		c.nl = append(c.nl, 0.0)
+		c.dl = append(c.dl, 0.0)
+	}
+	for k, i := range cases {
+		ti := sort.SearchFloat64s(distinct, target.Get(i))
+		c.timeIndex[i] = ti
+		c.n[ti]++
+		if target.Events[i] {
+			c.d[ti]++
+		}
+		if k < nleft {
+			c.nl[ti]++
+			if target.Events[i] {
+				c.dl[ti]++
+			}
+		}
+	}
+}
+
+//Move moves the non missing cases in moved, which must have been loaded on the right, to
+//the left.
+func (c *SurvivalCounts) Move(target *SurvivalTarget, moved []int) {
+	for _, i := range moved {
+		if target.IsMissing(i) {
+			continue
+		}
+		ti := c.timeIndex[i]
+		c.nl[ti]++
+		if target.Events[i] {
+			c.dl[ti]++
+		}
+	}
+}
+
+//LogRank returns the log-rank statistic of the counts as SurvivalTarget.LogRank would.
+func (c *SurvivalCounts) LogRank() float64 {
+	n, nl := 0.0, 0.0
+	for k := range c.n {
+		n += c.n[k]
+		nl += c.nl[k]
+	}
+	diff, variance := 0.0, 0.0
+	for k := range c.n {
+		if d := c.d[k]; d > 0.0 {
+			p := nl / n
+			diff += c.dl[k] - d*p
+			if n > 1.0 {
+				variance += d * p * (1.0 - p) * (n - d) / (n - 1.0)
+			}
+		}
+		n -= c.n[k]
+		nl -= c.nl[k]
+	}
+	if variance <= 0.0 {
+		return 0.0
+	}
+	return diff * diff / variance
+}
+
+//survivalCase is used to sort cases by time while calculating hazard functions.
+type survivalCase struct {
+	Time  float64
+	Event bool
+}
+
+//sortedCases returns the non missing cases sorted by time.
+func (target *SurvivalTarget) sortedCases(cases []int) (sorted []survivalCase) {
+	sorted = make([]survivalCase, 0, len(cases))
+	for _, i := range cases {
+		if !target.IsMissing(i) {
+			sorted = append(sorted, survivalCase{target.Get(i), target.Events[i]})
+		}
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Time < sorted[b].Time })
+	return
+}
+
+/*
+LogRank returns the two sample log-rank chi-square statistic comparing the survival of
+cases in l and r:
+
+	(sum(dl - d*nl/n))^2 / sum(d*(nl/n)*(1-nl/n)*(n-d)/(n-1))
+
+where the sums are over distinct event times, d and dl are the number of events in both
+groups and the left group at that time and n and nl are the number of cases at risk.
+It returns 0 if there are no events.
+*/
+func (target *SurvivalTarget) LogRank(l *[]int, r *[]int) float64 {
+	counts := new(SurvivalCounts)
+	counts.Load(target, l, r)
+	return counts.LogRank()
+}
+
+/*
+CumulativeHazard returns the Nelson-Aalen estimate of the cumulative hazard function of
+the cases as the distinct event times and the cumulative hazard at each of them.
+*/
+func (target *SurvivalTarget) CumulativeHazard(cases []int) (times []float64, chf []float64) {
+	sorted := target.sortedCases(cases)
+	n := float64(len(sorted))
+	h := 0.0
+	for k := 0; k < len(sorted); {
+		d, leaving := 0.0, 0.0
+		t := sorted[k].Time
+		for ; k < len(sorted) && sorted[k].Time == t; k++ {
+			if sorted[k].Event {
+				d++
+			}
+			leaving++
+		}
+		if d > 0.0 {
+			h += d / n
+			times = append(times, t)
+			chf = append(chf, h)
+		}
+		n -= leaving
+	}
+	return
+}
+
+/*
+FindPredicted returns the Nelson-Aalen cumulative hazard function of the cases formated
+by FormatHazard. Sets of cases without events predict a cumulative hazard of 0 from
+the earliest time.
+*/
+func (target *SurvivalTarget) FindPredicted(cases []int) (pred string) {
+	times, chf := target.CumulativeHazard(cases)
+	if len(times) == 0 {
+		min := math.Inf(1)
+		for _, i := range cases {
+			if !target.IsMissing(i) {
+				min = math.Min(min, target.Get(i))
+			}
+		}
+		if math.IsInf(min, 1) {
+			min = 0.0
+		}
+		times, chf = []float64{min}, []float64{0.0}
+	}
+	return FormatHazard(times, chf)
+}
+
+//FormatHazard formats a step function as semicolon separated time:value pairs.
+func FormatHazard(times []float64, chf []float64) string {
+	pairs := make([]string, 0, len(times))
+	for k := range times {
+		pairs = append(pairs, strconv.FormatFloat(times[k], 'g', -1, 64)+":"+strconv.FormatFloat(chf[k], 'g', -1, 64))
+	}
+	return strings.Join(pairs, ";")
+}
+
+//ParseHazard parses a step function formated by FormatHazard.
+func ParseHazard(pred string) (times []float64, chf []float64, err error) {
+	for _, pair := range strings.Split(pred, ";") {
+		tv := strings.Split(pair, ":")
+		if len(tv) != 2 {
+			return nil, nil, fmt.Errorf("Can't parse cumulative hazard %v.", pred)
+		}
+		t, err := strconv.ParseFloat(tv[0], 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		v, err := strconv.ParseFloat(tv[1], 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		times = append(times, t)
+		chf = append(chf, v)
+	}
+	return
+}
+
+/*
+SurvivalTargetName returns the name recorded as the target of survival forests so that
+both the time and event features can be found when the forest is applied.
+*/
+func SurvivalTargetName(time string, event string) string {
+	return "SURVIVAL(" + time + "," + event + ")"
+}
+
+//ParseSurvivalTargetName parses a name returned by SurvivalTargetName.
+func ParseSurvivalTargetName(name string) (time string, event string, ok bool) {
+	if !strings.HasPrefix(name, "SURVIVAL(") || !strings.HasSuffix(name, ")") {
+		return
+	}
+	parts := strings.Split(name[len("SURVIVAL("):len(name)-1], ",")
+	if len(parts) != 2 {
+		return
+	}
+	return parts[0], parts[1], true
+}
+
+/*
+SurvivalBallotBox averages the cumulative hazard functions predicted by the trees of a
+survival forest. It stores the weighted sum of the jumps in each case's predicted
+functions so the ensemble cumulative hazard at a time is the sum of the jumps up to
+that time divided by the total weight. Voting is thread safe.
+
+Events holds the event indicator of each case for use in TallyError.
+*/
+type SurvivalBallotBox struct {
+	Events  []bool
+	box     []map[float64]float64
+	weights []float64
+	mutex   sync.Mutex
+}
+
+//NewSurvivalBallotBox creates a SurvivalBallotBox for cases with the provided event
+//indicators.
+func NewSurvivalBallotBox(events []bool) *SurvivalBallotBox {
+	bb := &SurvivalBallotBox{events, make([]map[float64]float64, len(events)), make([]float64, len(events)), sync.Mutex{}}
+	for i := range bb.box {
+		bb.box[i] = make(map[float64]float64)
+	}
+	return bb
+}
+
+//Vote parses a cumulative hazard function formated by FormatHazard and adds it to the
+//votes for case i.
+func (bb *SurvivalBallotBox) Vote(casei int, pred string, weight float64) {
+	times, chf, err := ParseHazard(pred)
+	if err != nil {
+		return
+	}
+	bb.mutex.Lock()
+	last := 0.0
+	for k, t := range times {
+		bb.box[casei][t] += weight * (chf[k] - last)
+		last = chf[k]
+	}
+	bb.weights[casei] += weight
+	bb.mutex.Unlock()
+}
+
+//CumulativeHazard returns the ensemble cumulative hazard function of case i as the times
+//of its jumps and its value at each.
+func (bb *SurvivalBallotBox) CumulativeHazard(i int) (times []float64, chf []float64) {
+	if bb.weights[i] == 0.0 {
+		return
+	}
+	for t := range bb.box[i] {
+		times = append(times, t)
+	}
+	sort.Float64s(times)
+	h := 0.0
+	for _, t := range times {
+		h += bb.box[i][t] / bb.weights[i]
+		chf = append(chf, h)
+	}
+	return
+}
+
+//Tally returns the ensemble cumulative hazard function of case i formated by FormatHazard
+//or NA if the case has no votes.
+func (bb *SurvivalBallotBox) Tally(i int) string {
+	times, chf := bb.CumulativeHazard(i)
+	if times == nil {
+		return "NA"
+	}
+	return FormatHazard(times, chf)
+}
+
+/*
+Mortality returns the ensemble mortality of each case as defined by Ishwaran et al.:
+the sum of its cumulative hazard at each time any case's predicted hazard jumps (the
+event times of the training data). Cases without votes are NaN.
+*/
+func (bb *SurvivalBallotBox) Mortality() (mortality []float64) {
+	grid := make(map[float64]bool)
+	for _, box := range bb.box {
+		for t := range box {
+			grid[t] = true
+		}
+	}
+	times := make([]float64, 0, len(grid))
+	for t := range grid {
+		times = append(times, t)
+	}
+	sort.Float64s(times)
+
+	mortality = make([]float64, len(bb.box))
+	for i := range mortality {
+		if bb.weights[i] == 0.0 {
+			mortality[i] = math.NaN()
+			continue
+		}
+		//each jump counts once for every grid time at or after it
+		for t, jump := range bb.box[i] {
+			after := len(times) - sort.SearchFloat64s(times, t)
+			mortality[i] += float64(after) * jump / bb.weights[i]
+		}
+	}
+	return
+}
+
+//TallyError returns 1 minus Harrell's concordance index of the ensemble mortality
+//vs the times in feature and the ballot box's events.
+func (bb *SurvivalBallotBox) TallyError(feature Feature) (e float64) {
+	times := make([]float64, feature.Length())
+	missing := make([]bool, feature.Length())
+	for i := range times {
+		missing[i] = feature.IsMissing(i)
+		if !missing[i] {
+			times[i], _ = strconv.ParseFloat(feature.GetStr(i), 64)
+		}
+	}
+	c, err := HarrellsC(times, bb.Events, missing, bb.Mortality())
+	if err != nil {
+		return math.NaN()
+	}
+	return 1.0 - c
+}
+
+/*
+HarrellsC returns Harrell's concordance index of risk scores vs right censored
+survival times: the fraction of comparable pairs, in which the case with the shorter
+time had an event, where the case with the shorter time has the higher risk. Tied
+risks count as half concordant. Cases that are missing or have NaN risks are ignored.
+*/
+func HarrellsC(times []float64, events []bool, missing []bool, risk []float64) (c float64, err error) {
+	concordant, comparable := 0.0, 0.0
+	for i := range times {
+		if missing[i] || !events[i] || math.IsNaN(risk[i]) {
+			continue
+		}
+		for j := range times {
+			if missing[j] || math.IsNaN(risk[j]) || !(times[i] < times[j]) {
+				continue
+			}
+			comparable++
+			switch {
+			case risk[i] > risk[j]:
+				concordant++
+			case risk[i] == risk[j]:
+				concordant += 0.5
+			}
+		}
+	}
+	if comparable == 0.0 {
+		return math.NaN(), errors.New("No comparable pairs for concordance.")
+	}
+	return concordant / comparable, nil
+}
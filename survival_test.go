@@ -0,0 +1,163 @@
+package CloudForest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLogRank(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fm := ParseAFM(strings.NewReader("." +
+		"\tc1\tc2\tc3\tc4\tc5\tc6\n" +
+		"N:Time\t1\t2\t3\t4\t5\t6\n" +
+		"N:Event\t1\t1\t1\t1\t1\t1\n"))
+	target := NewSurvivalTarget(fm.Data[0].(NumFeature), fm.Data[1])
+	l, r := []int{0, 1, 2}, []int{3, 4, 5}
+	if stat := target.LogRank(&l, &r); math.Abs(stat-5.0516605) > 1e-6 {
+		t.Errorf("Log-rank statistic is %v not 5.0516605.", stat)
+	}
+	if dec := 1.0 - target.SplitImpurity(&l, &r, nil, nil); math.Abs(dec-target.LogRank(&l, &r)) > 1e-12 {
+		t.Errorf("Split impurity decrease %v isn't the log-rank statistic.", dec)
+	}
+
+	//incremental updates as cases move right to left should match recalculating
+	n := 200
+	stimes := make([]string, 0, n)
+	sevents := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		stimes = append(stimes, fmt.Sprintf("%v", rng.Intn(30)))
+		sevents = append(sevents, fmt.Sprintf("%v", rng.Intn(2)))
+	}
+	stimes[3] = "NA"
+	fm = parseRows([]string{"N:Time", "N:Event"}, [][]string{stimes, sevents})
+	target = NewSurvivalTarget(fm.Data[0].(NumFeature), fm.Data[1])
+	cases := rng.Perm(n)
+	allocs := NewBestSplitAllocs(n, target)
+	ll, rr := cases[:10], cases[10:]
+	target.SplitImpurity(&ll, &rr, nil, allocs)
+	for i := 10; i < n-10; i += 7 {
+		moved := cases[len(ll):i]
+		ll, rr = cases[:i], cases[i:]
+		updated := target.UpdateSImpFromAllocs(&ll, &rr, nil, allocs, &moved)
+		if full := target.SplitImpurity(&ll, &rr, nil, nil); math.Abs(updated-full) > 1e-9 {
+			t.Fatalf("Updated split impurity %v doesn't match %v with %v cases left.", updated, full, i)
+		}
+	}
+
+	fm = ParseAFM(strings.NewReader("." +
+		"\tc1\tc2\tc3\tc4\n" +
+		"N:Time\t1\t2\t2\t3\n" +
+		"B:Event\ttrue\ttrue\tfalse\ttrue\n"))
+	target = NewSurvivalTarget(fm.Data[0].(NumFeature), fm.Data[1])
+	times, chf := target.CumulativeHazard([]int{0, 1, 2, 3})
+	expected := []float64{0.25, 0.25 + 1.0/3.0, 1.25 + 1.0/3.0}
+	if len(times) != 3 || times[2] != 3.0 {
+		t.Fatalf("Cumulative hazard times %v not [1 2 3].", times)
+	}
+	for k := range expected {
+		if math.Abs(chf[k]-expected[k]) > 1e-12 {
+			t.Errorf("Cumulative hazard %v not %v.", chf, expected)
+		}
+	}
+
+	pred := target.FindPredicted([]int{0, 1, 2, 3})
+	ptimes, pchf, err := ParseHazard(pred)
+	if err != nil || len(ptimes) != 3 || pchf[2] != chf[2] {
+		t.Errorf("Prediction %v parsed as %v %v %v.", pred, ptimes, pchf, err)
+	}
+	if pred := target.FindPredicted([]int{2}); pred != "2:0" {
+		t.Errorf("Prediction without events is %v not 2:0.", pred)
+	}
+
+	c, err := HarrellsC([]float64{1, 2, 3}, []bool{true, true, false}, []bool{false, false, false}, []float64{3, 1, 2})
+	if err != nil || math.Abs(c-2.0/3.0) > 1e-12 {
+		t.Errorf("Harrell's C is %v not 2/3: %v", c, err)
+	}
+}
+
+func TestSurvivalForest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	//times decrease with risk and about a quarter of cases are censored
+	n := 200
+	var afm bytes.Buffer
+	labels := make([]string, 0, n)
+	risk := make([]string, 0, n)
+	noise := make([]string, 0, n)
+	times := make([]string, 0, n)
+	events := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		x := rng.Float64()
+		labels = append(labels, fmt.Sprintf("c%v", i))
+		risk = append(risk, fmt.Sprintf("%v", x))
+		noise = append(noise, fmt.Sprintf("%v", rng.Float64()))
+		times = append(times, fmt.Sprintf("%v", rng.ExpFloat64()/math.Exp(5.0*x)))
+		events = append(events, fmt.Sprintf("%v", rng.Intn(4) > 0))
+	}
+	fmt.Fprintf(&afm, ".\t%v\n", strings.Join(labels, "\t"))
+	fmt.Fprintf(&afm, "N:Time\t%v\n", strings.Join(times, "\t"))
+	fmt.Fprintf(&afm, "B:Event\t%v\n", strings.Join(events, "\t"))
+	fmt.Fprintf(&afm, "N:Risk\t%v\n", strings.Join(risk, "\t"))
+	fmt.Fprintf(&afm, "N:Noise\t%v\n", strings.Join(noise, "\t"))
+	fm := ParseAFM(&afm)
+
+	target := NewSurvivalTarget(fm.Data[0].(NumFeature), fm.Data[1])
+	bb := NewSurvivalBallotBox(target.Events)
+	allocs := NewBestSplitAllocs(n, target)
+	importance := NewRunningMeans(len(fm.Data))
+	var sf bytes.Buffer
+	fw := NewForestWriter(&sf)
+	for k := 0; k < 50; k++ {
+		cases := SampleWithReplacment(n, n)
+		inbag := make([]bool, n)
+		for _, c := range cases {
+			inbag[c] = true
+		}
+		oob := make([]int, 0, n)
+		for i, in := range inbag {
+			if !in {
+				oob = append(oob, i)
+			}
+		}
+		tree := NewTree()
+		tree.Target = SurvivalTargetName("N:Time", "B:Event")
+		tree.Grow(fm, target, cases, []int{2, 3}, nil, 1, 5, false, false, false, false, importance, nil, allocs)
+		tree.VoteCases(fm, bb, oob)
+		fw.WriteTree(tree, k)
+	}
+
+	//compare to the concordance of the true risk
+	truerisk := make([]float64, n)
+	missing := make([]bool, n)
+	for i := range truerisk {
+		truerisk[i] = fm.Data[2].(NumFeature).Get(i)
+	}
+	best, _ := HarrellsC(fm.Data[0].(NumFeature).(*DenseNumFeature).NumData, target.Events, missing, truerisk)
+	if c := 1.0 - bb.TallyError(fm.Data[0]); !(c > best-0.1) {
+		t.Errorf("OOB concordance %v not within 0.1 of the true risk's %v.", c, best)
+	}
+	riskImp, _ := (*importance)[2].Read()
+	noiseImp, _ := (*importance)[3].Read()
+	if !(riskImp > noiseImp) {
+		t.Errorf("Risk log-rank importance %v not greater then noise's %v.", riskImp, noiseImp)
+	}
+
+	forest, err := NewForestReader(&sf).ReadForest()
+	if err != nil {
+		t.Fatalf("Error reading survival forest: %v", err)
+	}
+	timename, eventname, ok := ParseSurvivalTargetName(forest.Target)
+	if !ok || timename != "N:Time" || eventname != "B:Event" {
+		t.Errorf("Survival target name %v parsed as %v %v.", forest.Target, timename, eventname)
+	}
+	applied := NewSurvivalBallotBox(target.Events)
+	for _, tree := range forest.Trees {
+		tree.Vote(fm, applied)
+	}
+	if applied.Tally(0) == "NA" || applied.TallyError(fm.Data[0]) > bb.TallyError(fm.Data[0]) {
+		t.Errorf("In bag concordance of read forest worse then oob concordance.")
+	}
+}
@@ -1,6 +1,9 @@
 package CloudForest
 
-import ()
+import (
+	"math"
+	"strconv"
+)
 
 //Tree represents a single decision tree.
 type Tree struct {
@@ -17,9 +20,10 @@ func NewTree() *Tree {
 
 //AddNode adds a node a the specified path with the specified pred value and/or
 //Splitter. Paths are specified in the same format as in rf-aces sf files, as a
-//string of 'L' and 'R'. Nodes must be added from the root up as the case where
-//the path specifies a node whose parent does not already exist in the tree is
-//not handled well.
+//string of 'L' and 'R' (and 'M' for missing branches) with 'B' followed by the
+//index of the branch for the branches of multiway splits. Nodes must be added from
+//the root up as the case where the path specifies a node whose parent does not
+//already exist in the tree is not handled well.
 func (t *Tree) AddNode(path string, pred string, splitter *Splitter) {
 	n := new(Node)
 	n.Pred = pred
@@ -46,6 +50,21 @@ func (t *Tree) AddNode(path string, pred string, splitter *Splitter) {
 					loc.Missing = n
 				}
 				loc = loc.Missing
+
+			case "B":
+				j := i + 1
+				for j < len(path) && path[j] >= '0' && path[j] <= '9' {
+					j++
+				}
+				b, _ := strconv.Atoi(path[i+1 : j])
+				for len(loc.Branches) <= b {
+					loc.Branches = append(loc.Branches, nil)
+				}
+				if loc.Branches[b] == nil {
+					loc.Branches[b] = n
+				}
+				loc = loc.Branches[b]
+				i = j - 1
 			}
 
 		}
@@ -83,6 +102,9 @@ leafSize specifies the minimum number of cases at a leafNode.
 splitmissing indicates if missing values should be split onto a third branch
 
 vet indicates if splits should be penalized against a randomized version of them selves
+
+If allocs.Presort is set numerical features are split using cases presorted once per
+FeatureMatrix instead of sorting the cases at each node (see PresortedCases).
 */
 func (t *Tree) Grow(fm *FeatureMatrix,
 	target Target,
@@ -99,6 +121,92 @@ func (t *Tree) Grow(fm *FeatureMatrix,
 	depthUsed *[]int,
 	allocs *BestSplitAllocs) {
 
+	t.grow(fm, target, cases, candidates, oob, mTry, leafSize, splitmissing, force, vet, evaloob, importance, depthUsed, allocs, nil)
+}
+
+/*
+GrowLevelSampled grows the receiver tree as Grow but samples the candidate features used
+at each depth of the tree (as in xgboost's colsample_bylevel) before sampling the features
+for each node. levelSample is the fraction of candidates sampled for each depth and nodeSample
+is the fraction of those evaluated at each node (in place of mTry).
+*/
+func (t *Tree) GrowLevelSampled(fm *FeatureMatrix,
+	target Target,
+	cases []int,
+	candidates []int,
+	oob []int,
+	levelSample float64,
+	nodeSample float64,
+	leafSize int,
+	splitmissing bool,
+	force bool,
+	vet bool,
+	evaloob bool,
+	importance *[]*RunningMean,
+	depthUsed *[]int,
+	allocs *BestSplitAllocs) {
+	levels := &levelSampler{candidates, levelSample, nodeSample, nil}
+	t.grow(fm, target, cases, candidates, oob, 0, leafSize, splitmissing, force, vet, evaloob, importance, depthUsed, allocs, levels)
+}
+
+//levelSampler samples and remembers the candidate features for each depth of a tree.
+type levelSampler struct {
+	candidates  []int
+	levelSample float64
+	nodeSample  float64
+	levels      [][]int
+}
+
+//sample returns the candidates sampled for the depth and the number of them to try at
+//each node.
+func (s *levelSampler) sample(depth int) (candidates *[]int, mTry int) {
+	for len(s.levels) <= depth {
+		level := append([]int(nil), s.candidates...)
+		n := sampleCount(s.levelSample, len(level))
+		SampleFirstN(&level, nil, n, 0)
+		s.levels = append(s.levels, level[:n])
+	}
+	return &s.levels[depth], sampleCount(s.nodeSample, len(s.levels[depth]))
+}
+
+//sampleCount returns ceil(fraction*n) bounded by 1 and n or n if fraction isn't in (0,1).
+func sampleCount(fraction float64, n int) int {
+	if fraction <= 0.0 || fraction >= 1.0 {
+		return n
+	}
+	count := int(math.Ceil(fraction * float64(n)))
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+//grow implements Grow and GrowLevelSampled. If levels isn't nil the candidates and mTry for
+//each node are taken from it.
+func (t *Tree) grow(fm *FeatureMatrix,
+	target Target,
+	cases []int,
+	candidates []int,
+	oob []int,
+	mTry int,
+	leafSize int,
+	splitmissing bool,
+	force bool,
+	vet bool,
+	evaloob bool,
+	importance *[]*RunningMean,
+	depthUsed *[]int,
+	allocs *BestSplitAllocs,
+	levels *levelSampler) {
+
+	//cached histograms are identified by the position of their cases
+	allocs.Histograms.Reset()
+	if allocs.Presort != nil {
+		allocs.Presort.Load(cases)
+	}
+	//the root of a reused tree may have been bounded by monotone constraints
+	t.Root.Bounds = nil
+
 	//var innercanidates []int
 	var impDec float64
 	// for i := 0; i < len(allocs.Weights); i++ {
@@ -115,11 +223,22 @@ func (t *Tree) Grow(fm *FeatureMatrix,
 
 		nconstants = nconstantsbefore
 
+		if allocs.Monotone != nil {
+			allocs.Monotone.Bounds = n.Bounds
+		}
+
 		if (2 * leafSize) <= len(*innercases) {
 			//SampleFirstN(&candidates, &innercanidates, mTry, 0)
 			//innercanidates = candidates[:mTry]
 
-			fi, split, impDec, nconstants = fm.BestSplitter(target, innercases, &candidates, mTry, &oob, leafSize, force, vet, evaloob, allocs, nconstantsbefore)
+			if levels != nil {
+				//constants are only tracked within a depth's candidates so children start over
+				levelcans, levelmTry := levels.sample(depth)
+				fi, split, impDec, _ = fm.BestSplitter(target, innercases, levelcans, levelmTry, &oob, leafSize, force, vet, evaloob, allocs, 0)
+				nconstants = 0
+			} else {
+				fi, split, impDec, nconstants = fm.BestSplitter(target, innercases, &candidates, mTry, &oob, leafSize, force, vet, evaloob, allocs, nconstantsbefore)
+			}
 
 			// for i := mTry; i < len(candidates)-1 && impDec == minImp; i++ {
 			// 	randi := i + rand.Intn(len(candidates)-i)
@@ -127,27 +246,77 @@ func (t *Tree) Grow(fm *FeatureMatrix,
 			// 	innercanidates = candidates[i : i+1]
 			// 	fi, split, impDec, nconstants = fm.BestSplitter(target, innercases, &innercanidates, &oob, leafSize, vet, evaloob, allocs, nconstantsbefore)
 			// }
+			var oblique *Splitter
+			if allocs.Oblique != nil {
+				parentImp := allocs.Impurity(target, innercases)
+				var obliqueImp float64
+				oblique, obliqueImp = allocs.Oblique.BestSplit(fm, target, innercases, parentImp, leafSize, allocs)
+				if oblique != nil && (split == nil || obliqueImp > impDec) {
+					//oblique splits are passed to CodedRecurse as their splitter
+					fi, split, impDec = -1, oblique, obliqueImp
+				} else {
+					oblique = nil
+				}
+			}
 			if split != nil {
-				if importance != nil {
-					(*importance)[fi].Add(impDec)
+				used := []int{fi}
+				if oblique != nil {
+					used = used[:0]
+					for _, name := range oblique.Features {
+						used = append(used, fm.Map[name])
+					}
 				}
-				if depthUsed != nil && ((*depthUsed)[fi] == 0 || depth < (*depthUsed)[fi]) {
-					(*depthUsed)[fi] = depth
+				for _, ui := range used {
+					if importance != nil {
+						//oblique splits credit their features equally
+						(*importance)[ui].Add(impDec / float64(len(used)))
+					}
+					if depthUsed != nil && ((*depthUsed)[ui] == 0 || depth < (*depthUsed)[ui]) {
+						(*depthUsed)[ui] = depth
+					}
 				}
 				//not a leaf node so define the splitter and left and right nodes
 				//so recursion will continue
-				n.CodedSplit = split
-				n.Featurei = fi
-				n.Splitter = fm.Data[fi].DecodeSplit(split)
-				n.Pred = ""
-				//is this check needed? is it safe to reuse?
-				if n.Left == nil || n.Right == nil {
-					n.Left = new(Node)
-					n.Right = new(Node)
+				if oblique != nil {
+					n.CodedSplit = nil
+					n.Featurei = fi
+					n.Splitter = oblique
+				} else {
+					n.CodedSplit = split
+					n.Featurei = fi
+					n.Splitter = fm.Data[fi].DecodeSplit(split)
+				}
+				//interior predictions are kept for decision path analysis
+				n.Pred = n.Bounds.ClipPred(target.FindPredicted(*innercases))
+				if multiway, ok := split.(*MultiwaySplit); ok {
+					n.Left = nil
+					n.Right = nil
+					n.Branches = make([]*Node, len(multiway.Cats))
+					for i := range n.Branches {
+						n.Branches[i] = new(Node)
+					}
+				} else {
+					n.Branches = nil
+					//is this check needed? is it safe to reuse?
+					if n.Left == nil || n.Right == nil {
+						n.Left = new(Node)
+						n.Right = new(Node)
+					}
 				}
 				if splitmissing {
 					n.Missing = new(Node)
 				}
+				if allocs.Monotone != nil {
+					allocs.Monotone.BoundChildren(n, fm, target, fi, split, innercases)
+				}
+				if allocs.Presort != nil {
+					//keep the presorted cases partitioned like the cases will be
+					if oblique != nil {
+						allocs.Presort.PartitionOblique(fm, oblique, innercases)
+					} else {
+						allocs.Presort.Partition(fm.Data[fi], split, innercases)
+					}
+				}
 				return
 			}
 
@@ -158,7 +327,12 @@ func (t *Tree) Grow(fm *FeatureMatrix,
 		split = nil
 		n.CodedSplit = nil
 		n.Splitter = nil
-		n.Pred = target.FindPredicted(*innercases)
+		//drop children left over from reusing the tree so the node is seen as a leaf
+		n.Left = nil
+		n.Right = nil
+		n.Branches = nil
+		n.Missing = nil
+		n.Pred = n.Bounds.ClipPred(target.FindPredicted(*innercases))
 		return
 
 	}, fm, &cases, 0, 0)
@@ -177,12 +351,18 @@ func (t *Tree) GetLeaves(fm *FeatureMatrix, fbycase *SparseCounter) []Leaf {
 	}
 
 	t.Root.Recurse(func(n *Node, cases []int, depth int) {
-		if n.Left == nil && n.Right == nil { // I'm in a leaf node
+		if n.IsLeaf() { // I'm in a leaf node
 			leaves = append(leaves, Leaf{cases, n.Pred})
 		}
 		if fbycase != nil && n.Splitter != nil { //I'm not in a leaf node?
+			features := []string{n.Splitter.Feature}
+			if n.Splitter.Oblique() {
+				features = n.Splitter.Features
+			}
 			for _, c := range cases {
-				fbycase.Add(c, fm.Map[n.Splitter.Feature], 1)
+				for _, name := range features {
+					fbycase.Add(c, fm.Map[name], 1)
+				}
 			}
 		}
 
@@ -201,7 +381,7 @@ func (t *Tree) Partition(fm *FeatureMatrix) *[][]int {
 	}
 
 	t.Root.Recurse(func(n *Node, cases []int, depth int) {
-		if n.Left == nil && n.Right == nil { // I'm in a leaf node
+		if n.IsLeaf() { // I'm in a leaf node
 			leaves = append(leaves, cases)
 		}
 
@@ -241,7 +421,7 @@ func (t *Tree) VoteCases(fm *FeatureMatrix, bb VoteTallyer, cases []int) {
 	}
 
 	t.Root.Recurse(func(n *Node, cases []int, depth int) {
-		if n.Left == nil && n.Right == nil {
+		if n.IsLeaf() {
 			// I'm in a leaf node
 			for i := 0; i < len(cases); i++ {
 				bb.Vote(cases[i], n.Pred, weight)
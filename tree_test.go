@@ -0,0 +1,57 @@
+package CloudForest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestGrowLevelSampled(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	n := 200
+	names := make([]string, 0, 11)
+	values := make([][]string, 0, 11)
+	candidates := make([]int, 0, 10)
+	for j := 0; j <= 10; j++ {
+		v := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			v = append(v, fmt.Sprintf("%v", rng.Float64()))
+		}
+		if j == 0 {
+			names = append(names, "N:Y")
+		} else {
+			names = append(names, fmt.Sprintf("N:X%v", j))
+			candidates = append(candidates, j)
+		}
+		values = append(values, v)
+	}
+	fm := parseRows(names, values)
+	target := fm.Data[0].(NumFeature)
+	cases := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cases = append(cases, i)
+	}
+
+	//all nodes at a depth should split on the 3 features sampled for it
+	for k := 0; k < 10; k++ {
+		tree := NewTree()
+		tree.GrowLevelSampled(fm, target, cases, candidates, nil, 0.25, 0.0, 5, false, false, false, false, nil, nil, NewBestSplitAllocs(n, target))
+		used := make(map[int]map[string]bool)
+		tree.Root.Recurse(func(node *Node, cases []int, depth int) {
+			if node.Splitter != nil {
+				if used[depth] == nil {
+					used[depth] = make(map[string]bool)
+				}
+				used[depth][node.Splitter.Feature] = true
+			}
+		}, fm, cases, 0)
+		if len(used) == 0 {
+			t.Fatal("Level sampled tree didn't split.")
+		}
+		for depth, features := range used {
+			if len(features) > 3 {
+				t.Errorf("Nodes at depth %v split on %v features not at most 3.", depth, len(features))
+			}
+		}
+	}
+}
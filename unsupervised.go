@@ -0,0 +1,55 @@
+package CloudForest
+
+import (
+	"math/rand"
+)
+
+//UnsupervisedTarget is the name of the real vs synthetic target added by SyntheticContrast.
+const UnsupervisedTarget = "C:UNSUPERVISED"
+
+/*
+SyntheticContrast returns a feature matrix for Breiman's unsupervised random forest. It
+contains copies of the specified features for the real cases followed by the same number
+of synthetic cases drawn from the product of the features' marginal distributions by
+sampling each feature's values independently (with replacement, so missing values are
+sampled at their observed rate). A categorical target named UnsupervisedTarget labels
+cases "real" or "synthetic".
+
+A classifier grown to predict the target must learn the dependence between features
+and the resulting proximities between real cases can be used for clustering. Synthetic
+cases are labeled with the label of the real case in the same position plus
+":SYNTHETIC".
+*/
+func (fm *FeatureMatrix) SyntheticContrast(features []int) (combined *FeatureMatrix) {
+	nCases := fm.Data[0].Length()
+	combined = &FeatureMatrix{make([]Feature, 0, len(features)+1),
+		make(map[string]int),
+		make([]string, 0, 2*nCases)}
+
+	for _, fi := range features {
+		f := fm.Data[fi].Copy()
+		for i := 0; i < nCases; i++ {
+			f.Append(fm.Data[fi].GetStr(rand.Intn(nCases)))
+		}
+		combined.Map[f.GetName()] = len(combined.Data)
+		combined.Data = append(combined.Data, f)
+	}
+
+	record := make([]string, 0, 2*nCases+1)
+	record = append(record, UnsupervisedTarget)
+	for i := 0; i < nCases; i++ {
+		record = append(record, "real")
+	}
+	for i := 0; i < nCases; i++ {
+		record = append(record, "synthetic")
+	}
+	target := ParseFeature(record)
+	combined.Map[target.GetName()] = len(combined.Data)
+	combined.Data = append(combined.Data, target)
+
+	combined.CaseLabels = append(combined.CaseLabels, fm.CaseLabels...)
+	for _, label := range fm.CaseLabels {
+		combined.CaseLabels = append(combined.CaseLabels, label+":SYNTHETIC")
+	}
+	return
+}